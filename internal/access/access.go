@@ -0,0 +1,142 @@
+package access
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	cookiePrefix = "snipr_access_"
+
+	defaultCookieTTL   = 10 * time.Minute
+	defaultMaxAttempts = 5
+	defaultLockout     = 15 * time.Minute
+)
+
+// Guard checks passwords of protected links and issues signed cookies that
+// let a visitor through without typing the password again.
+type Guard struct {
+	secret    []byte
+	cookieTTL time.Duration
+	throttle  *Throttle
+	now       func() time.Time
+}
+
+// NewGuard builds a Guard from conf. Without a configured cookie secret a
+// random one is generated, so cookies do not survive a restart and are
+// not accepted by other instances.
+func NewGuard(conf *config.PasswordConfig) (*Guard, error) {
+	guardConf := config.PasswordConfig{
+		CookieTTL:   defaultCookieTTL,
+		MaxAttempts: defaultMaxAttempts,
+		Lockout:     defaultLockout,
+	}
+	if conf != nil {
+		guardConf.CookieSecret = conf.CookieSecret
+		guardConf.CookieTTL = conf.CookieTTL
+		if conf.MaxAttempts > 0 {
+			guardConf.MaxAttempts = conf.MaxAttempts
+		}
+		if conf.Lockout > 0 {
+			guardConf.Lockout = conf.Lockout
+		}
+	}
+
+	secret := []byte(guardConf.CookieSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate cookie secret: %w", err)
+		}
+	}
+
+	return &Guard{
+		secret:    secret,
+		cookieTTL: guardConf.CookieTTL,
+		throttle:  NewThrottle(guardConf.MaxAttempts, guardConf.Lockout),
+		now:       time.Now,
+	}, nil
+}
+
+// CookieName is the name of the access cookie for the link with code.
+func CookieName(code string) string {
+	return cookiePrefix + code
+}
+
+// Allowed reports whether r carries a valid access cookie for the link with
+// code protected by passwordHash. Cookies stop working when the password
+// changes.
+func (g *Guard) Allowed(r *http.Request, code string, passwordHash string) bool {
+	cookie, err := r.Cookie(CookieName(code))
+	if err != nil {
+		return false
+	}
+
+	expiry, mac, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || g.now().Unix() >= expiresAt {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, g.sign(code, passwordHash, expiresAt))
+}
+
+// Unlock verifies password for the link with code on behalf of client. It
+// returns a ThrottledError while the client is locked out. The attempt is
+// counted before the password is checked, so parallel guesses are held to
+// the same limit as sequential ones.
+func (g *Guard) Unlock(client string, code string, passwordHash string, password string) error {
+	key := client + "|" + code
+	if wait := g.throttle.Reserve(key); wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+
+	g.throttle.Reset(key)
+	return nil
+}
+
+// Cookie returns a signed access cookie for the link with code, or nil if
+// cookies are disabled. secure marks it HTTPS only, and should be set
+// whenever the visitor reached snipr over HTTPS.
+func (g *Guard) Cookie(code string, passwordHash string, secure bool) *http.Cookie {
+	if g.cookieTTL <= 0 {
+		return nil
+	}
+
+	expiresAt := g.now().Add(g.cookieTTL).Unix()
+	return &http.Cookie{
+		Name:     CookieName(code),
+		Value:    strconv.FormatInt(expiresAt, 10) + "." + base64.RawURLEncoding.EncodeToString(g.sign(code, passwordHash, expiresAt)),
+		Path:     "/" + code,
+		MaxAge:   int(g.cookieTTL / time.Second),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (g *Guard) sign(code string, passwordHash string, expiresAt int64) []byte {
+	mac := hmac.New(sha256.New, g.secret)
+	fmt.Fprintf(mac, "%s\x00%s\x00%d", code, passwordHash, expiresAt)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/access"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func hash(t *testing.T, password string) string {
+	settings := models.LinkSettings{Password: password}
+	require.Nil(t, settings.HashPassword())
+	require.Equal(t, "", settings.Password)
+	return settings.PasswordHash
+}
+
+func TestUnlock(t *testing.T) {
+	guard, err := access.NewGuard(&config.PasswordConfig{MaxAttempts: 2, Lockout: time.Second})
+	require.Nil(t, err)
+	passwordHash := hash(t, "correct horse")
+
+	require.Nil(t, guard.Unlock("203.0.113.9", "abc", passwordHash, "correct horse"))
+
+	require.ErrorIs(t, guard.Unlock("203.0.113.9", "abc", passwordHash, "wrong"), access.ErrWrongPassword)
+	require.ErrorIs(t, guard.Unlock("203.0.113.9", "abc", passwordHash, "wrong"), access.ErrWrongPassword)
+
+	// Locked out, even with the right password
+	err = guard.Unlock("203.0.113.9", "abc", passwordHash, "correct horse")
+	var throttled *access.ThrottledError
+	require.True(t, errors.As(err, &throttled))
+	require.Greater(t, throttled.RetryAfter, time.Duration(0))
+
+	// Other clients and other links are not affected
+	require.Nil(t, guard.Unlock("198.51.100.1", "abc", passwordHash, "correct horse"))
+	require.ErrorIs(t, guard.Unlock("203.0.113.9", "xyz", passwordHash, "wrong"), access.ErrWrongPassword)
+
+	time.Sleep(1100 * time.Millisecond)
+	require.Nil(t, guard.Unlock("203.0.113.9", "abc", passwordHash, "correct horse"))
+}
+
+func TestUnlockConcurrentGuesses(t *testing.T) {
+	const limit = 3
+	guard, err := access.NewGuard(&config.PasswordConfig{MaxAttempts: limit, Lockout: time.Minute})
+	require.Nil(t, err)
+	passwordHash := hash(t, "correct horse")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- guard.Unlock("203.0.113.9", "abc", passwordHash, "wrong")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Only guesses that got past the throttle are checked against the hash
+	checked, throttled := 0, 0
+	for err := range errs {
+		var throttledErr *access.ThrottledError
+		switch {
+		case errors.Is(err, access.ErrWrongPassword):
+			checked++
+		case errors.As(err, &throttledErr):
+			throttled++
+		}
+	}
+	require.Equal(t, limit, checked)
+	require.Equal(t, 20-limit, throttled)
+}
+
+func TestCookie(t *testing.T) {
+	guard, err := access.NewGuard(&config.PasswordConfig{CookieSecret: "secret", CookieTTL: time.Minute})
+	require.Nil(t, err)
+	passwordHash := hash(t, "correct horse")
+
+	cookie := guard.Cookie("abc", passwordHash, true)
+	require.NotNil(t, cookie)
+	require.Equal(t, access.CookieName("abc"), cookie.Name)
+	require.Equal(t, "/abc", cookie.Path)
+	require.True(t, cookie.Secure)
+	require.False(t, guard.Cookie("abc", passwordHash, false).Secure)
+
+	req := httptest.NewRequest("GET", "/abc", nil)
+	require.False(t, guard.Allowed(req, "abc", passwordHash))
+	req.AddCookie(cookie)
+	require.True(t, guard.Allowed(req, "abc", passwordHash))
+
+	// Changing the password invalidates the cookie
+	require.False(t, guard.Allowed(req, "abc", hash(t, "battery staple")))
+
+	// So does a different secret
+	other, err := access.NewGuard(&config.PasswordConfig{CookieSecret: "other", CookieTTL: time.Minute})
+	require.Nil(t, err)
+	require.False(t, other.Allowed(req, "abc", passwordHash))
+
+	// Tampered cookies are rejected
+	tampered := httptest.NewRequest("GET", "/abc", nil)
+	cookie.Value = "9999999999" + cookie.Value[len("9999999999"):]
+	tampered.AddCookie(cookie)
+	require.False(t, guard.Allowed(tampered, "abc", passwordHash))
+
+	noCookies, err := access.NewGuard(&config.PasswordConfig{CookieTTL: 0})
+	require.Nil(t, err)
+	require.Nil(t, noCookies.Cookie("abc", passwordHash, true))
+}
+
+func TestPasswordValidation(t *testing.T) {
+	for _, password := range []string{"short", string(make([]byte, 73))} {
+		settings := models.LinkSettings{Password: password}
+		require.ErrorIs(t, settings.Validate(), models.ErrInvalidLinkSettings)
+	}
+}
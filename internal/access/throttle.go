@@ -0,0 +1,93 @@
+package access
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrWrongPassword = errors.New("wrong password")
+
+// ThrottledError is returned while a client is locked out after too many
+// wrong passwords.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many attempts, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+type attempts struct {
+	failures int
+	since    time.Time
+}
+
+// Throttle counts attempts per key. Once maxAttempts attempts that were not
+// reset happen within lockout the key is refused until lockout has passed
+// since the first one. State is kept in memory, so every instance throttles
+// on its own and a client spreading guesses over N instances gets N times
+// maxAttempts.
+type Throttle struct {
+	maxAttempts int
+	lockout     time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]*attempts
+	swept    time.Time
+}
+
+func NewThrottle(maxAttempts int, lockout time.Duration) *Throttle {
+	return &Throttle{
+		maxAttempts: maxAttempts,
+		lockout:     lockout,
+		now:         time.Now,
+		attempts:    map[string]*attempts{},
+	}
+}
+
+// Reserve counts an attempt for key before it is made, so concurrent
+// attempts can not all slip through before the first failure is recorded.
+// It returns how long key still has to wait, zero if the attempt may go
+// ahead. Attempts that succeed should be refunded with Reset.
+func (t *Throttle) Reserve(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.sweep(now)
+
+	a, ok := t.attempts[key]
+	if !ok || now.Sub(a.since) >= t.lockout {
+		a = &attempts{since: now}
+		t.attempts[key] = a
+	}
+	if a.failures >= t.maxAttempts {
+		return a.since.Add(t.lockout).Sub(now)
+	}
+	a.failures++
+	return 0
+}
+
+// Reset forgets the attempts of key.
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.attempts, key)
+}
+
+// sweep drops expired entries at most once per lockout period so the map
+// does not grow without bound.
+func (t *Throttle) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.lockout {
+		return
+	}
+	t.swept = now
+	for key, a := range t.attempts {
+		if now.Sub(a.since) >= t.lockout {
+			delete(t.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+//go:generate mockgen -source=analytics.go -destination analytics_mock.go -package analytics
+package analytics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+const (
+	defaultBufferSize    = 10000
+	defaultBatchSize     = 500
+	defaultFlushInterval = time.Second
+)
+
+var ErrRecorderStopped = errors.New("click recorder is not running")
+
+// Recorder collects clicks off the request path.
+type Recorder interface {
+	// Record queues click without blocking. Clicks are dropped when the
+	// queue is full.
+	Record(click *models.Click)
+}
+
+// AsyncRecorder writes queued clicks to storage in batches from a single
+// background goroutine.
+type AsyncRecorder struct {
+	storage storage.ClickStorage
+	conf    config.AnalyticsConfig
+	logger  *slog.Logger
+
+	clicks  chan *models.Click
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	running atomic.Bool
+	dropped atomic.Int64
+}
+
+func NewAsyncRecorder(storage storage.ClickStorage, conf *config.AnalyticsConfig, logger *slog.Logger) *AsyncRecorder {
+	recorderConf := config.AnalyticsConfig{}
+	if conf != nil {
+		recorderConf = *conf
+	}
+	if recorderConf.BufferSize <= 0 {
+		recorderConf.BufferSize = defaultBufferSize
+	}
+	if recorderConf.BatchSize <= 0 {
+		recorderConf.BatchSize = defaultBatchSize
+	}
+	if recorderConf.FlushInterval <= 0 {
+		recorderConf.FlushInterval = defaultFlushInterval
+	}
+
+	return &AsyncRecorder{
+		storage: storage,
+		conf:    recorderConf,
+		logger:  logger,
+		clicks:  make(chan *models.Click, recorderConf.BufferSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start launches the writer goroutine.
+func (a *AsyncRecorder) Start() {
+	a.running.Store(true)
+	go a.run()
+}
+
+func (a *AsyncRecorder) Record(click *models.Click) {
+	select {
+	case a.clicks <- click:
+	default:
+		if a.dropped.Add(1) == 1 {
+			a.logger.Warn("Click queue full, dropping clicks")
+		}
+	}
+}
+
+// Check reports whether the writer goroutine is running. It can be
+// registered as a health check.
+func (a *AsyncRecorder) Check(context.Context) error {
+	if !a.running.Load() {
+		return ErrRecorderStopped
+	}
+	return nil
+}
+
+// Close stops accepting new batches, flushes what is queued and waits for
+// the writer to finish or ctx to expire.
+func (a *AsyncRecorder) Close(ctx context.Context) error {
+	a.once.Do(func() { close(a.stop) })
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *AsyncRecorder) run() {
+	defer close(a.done)
+	defer a.running.Store(false)
+
+	ticker := time.NewTicker(a.conf.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.Click, 0, a.conf.BatchSize)
+	for {
+		select {
+		case click := <-a.clicks:
+			batch = append(batch, click)
+			if len(batch) >= a.conf.BatchSize {
+				batch = a.flush(batch)
+			}
+		case <-ticker.C:
+			batch = a.flush(batch)
+		case <-a.stop:
+			for {
+				select {
+				case click := <-a.clicks:
+					batch = append(batch, click)
+					if len(batch) >= a.conf.BatchSize {
+						batch = a.flush(batch)
+					}
+				default:
+					a.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (a *AsyncRecorder) flush(batch []*models.Click) []*models.Click {
+	if dropped := a.dropped.Swap(0); dropped > 0 {
+		a.logger.Warn("Dropped clicks", slog.Int64("count", dropped))
+	}
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.storage.StoreClicks(ctx, batch); err != nil {
+		a.logger.Error("Failed to store clicks", slog.Int("count", len(batch)), slog.Any("error", err))
+	}
+	return batch[:0]
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/analytics"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderBatchesAndFlushesOnClose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var (
+		mu      sync.Mutex
+		batches []int
+		stored  []string
+	)
+	clickStorage := storage.NewMockClickStorage(ctrl)
+	clickStorage.EXPECT().StoreClicks(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, clicks []*models.Click) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, len(clicks))
+		for _, click := range clicks {
+			stored = append(stored, click.Country)
+		}
+		return nil
+	}).AnyTimes()
+
+	recorder := analytics.NewAsyncRecorder(clickStorage, &config.AnalyticsConfig{
+		BufferSize:    10,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	}, slog.Default())
+	require.ErrorIs(t, recorder.Check(context.Background()), analytics.ErrRecorderStopped)
+
+	recorder.Start()
+	require.Nil(t, recorder.Check(context.Background()))
+
+	for _, country := range []string{"DE", "FR", "JP"} {
+		recorder.Record(&models.Click{ShortURL: "https://snipr.com/abc", Country: country})
+	}
+	require.Nil(t, recorder.Close(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []int{2, 1}, batches)
+	require.Equal(t, []string{"DE", "FR", "JP"}, stored)
+	require.ErrorIs(t, recorder.Check(context.Background()), analytics.ErrRecorderStopped)
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clickStorage := storage.NewMockClickStorage(ctrl)
+	clickStorage.EXPECT().StoreClicks(gomock.Any(), gomock.Len(1)).Return(nil)
+
+	// Not started, so nothing drains the queue.
+	recorder := analytics.NewAsyncRecorder(clickStorage, &config.AnalyticsConfig{BufferSize: 1}, slog.Default())
+	recorder.Record(&models.Click{})
+	recorder.Record(&models.Click{})
+
+	recorder.Start()
+	require.Nil(t, recorder.Close(context.Background()))
+}
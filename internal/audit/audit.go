@@ -0,0 +1,253 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/auth"
+	"github.com/sri-shubham/snipr/internal/clientip"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/logging"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+// Audited actions.
+const (
+	ActionCreate        = "link.create"
+	ActionCreateCustom  = "link.create_custom"
+	ActionUpdate        = "link.update"
+	ActionRollback      = "link.rollback"
+	ActionDelete        = "link.delete"
+	ActionWebhookCreate = "webhook.create"
+	ActionWebhookDelete = "webhook.delete"
+)
+
+// Sink names accepted in config.
+const (
+	SinkPostgres = "postgres"
+	SinkFile     = "file"
+	SinkStdout   = "stdout"
+)
+
+const anonymousActor = "anonymous"
+
+// Sink stores audit events.
+type Sink interface {
+	Write(ctx context.Context, event *models.AuditEvent) error
+}
+
+// Log records audit events to every configured sink. A nil *Log records
+// nothing.
+type Log struct {
+	sinks    []Sink
+	clientIP *clientip.Resolver
+	logger   *slog.Logger
+	closers  []io.Closer
+}
+
+func New(clientIP *clientip.Resolver, logger *slog.Logger, sinks ...Sink) *Log {
+	return &Log{
+		sinks:    sinks,
+		clientIP: clientIP,
+		logger:   logger,
+	}
+}
+
+// Open sets up the sinks named in conf. Without conf events go to storage
+// only.
+func Open(conf *config.AuditConfig, storage storage.AuditStorage, clientIP *clientip.Resolver, logger *slog.Logger) (*Log, error) {
+	names := []string{SinkPostgres}
+	path := ""
+	if conf != nil {
+		names = conf.Sinks
+		path = conf.File
+	}
+
+	log := New(clientIP, logger)
+	for _, name := range names {
+		switch name {
+		case SinkPostgres:
+			log.sinks = append(log.sinks, NewStorageSink(storage))
+		case SinkStdout:
+			log.sinks = append(log.sinks, NewJSONLinesSink(os.Stdout))
+		case SinkFile:
+			if path == "" {
+				log.Close(context.Background())
+				return nil, errors.New("audit file sink needs a file")
+			}
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+			if err != nil {
+				log.Close(context.Background())
+				return nil, err
+			}
+			log.sinks = append(log.sinks, NewJSONLinesSink(file))
+			log.closers = append(log.closers, file)
+		default:
+			log.Close(context.Background())
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+
+	return log, nil
+}
+
+// Record writes an event for action on the link shortURL made by r. old
+// and new are the link before and after the change, nil for created and
+// deleted links. Callers record changes in the transaction making them, so
+// events in storage commit with the change; a failing sink returns an
+// error so the change can be undone rather than go unrecorded.
+func (l *Log) Record(ctx context.Context, r *http.Request, action string, shortURL string, old any, new any) error {
+	if l == nil {
+		return nil
+	}
+
+	diff, err := Diff(old, new)
+	if err != nil {
+		return fmt.Errorf("diff audit event: %w", err)
+	}
+
+	event := &models.AuditEvent{
+		Time:      time.Now().UTC(),
+		Action:    action,
+		Actor:     Actor(ctx),
+		RequestID: logging.RequestID(ctx),
+		ShortURL:  shortURL,
+		Diff:      diff,
+	}
+	if l.clientIP != nil {
+		if ip := l.clientIP.IP(r); ip.IsValid() {
+			event.SourceIP = ip.String()
+		}
+	}
+
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Write(ctx, event); err != nil {
+			l.logger.ErrorContext(ctx, "Failed to write audit event",
+				slog.String("action", action),
+				slog.String("short_url", shortURL),
+				slog.Any("error", err))
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("write audit event: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Close closes files opened for the sinks.
+func (l *Log) Close(context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, closer := range l.closers {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Actor returns the authenticated name of whoever made the request ctx
+// belongs to, or "anonymous".
+func Actor(ctx context.Context) string {
+	if actor, ok := auth.Identity(ctx); ok {
+		return actor
+	}
+	return anonymousActor
+}
+
+// Diff compares the top level JSON fields of old and new and returns those
+// that differ.
+func Diff(old any, new any) (map[string]*models.AuditChange, error) {
+	oldFields, err := fields(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := fields(new)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]*models.AuditChange{}
+	for name, oldValue := range oldFields {
+		newValue, ok := newFields[name]
+		if !ok {
+			diff[name] = &models.AuditChange{Old: oldValue, New: json.RawMessage("null")}
+		} else if !bytes.Equal(oldValue, newValue) {
+			diff[name] = &models.AuditChange{Old: oldValue, New: newValue}
+		}
+	}
+	for name, newValue := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			diff[name] = &models.AuditChange{Old: json.RawMessage("null"), New: newValue}
+		}
+	}
+	return diff, nil
+}
+
+func fields(value any) (map[string]json.RawMessage, error) {
+	out := map[string]json.RawMessage{}
+	if value == nil {
+		return out, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &out); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = map[string]json.RawMessage{}
+	}
+	return out, nil
+}
+
+type storageSink struct {
+	storage storage.AuditStorage
+}
+
+// NewStorageSink writes events to storage.
+func NewStorageSink(storage storage.AuditStorage) Sink {
+	return &storageSink{storage: storage}
+}
+
+func (s *storageSink) Write(ctx context.Context, event *models.AuditEvent) error {
+	return s.storage.StoreAuditEvent(ctx, event)
+}
+
+type jsonLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesSink writes events to w as one JSON object per line.
+func NewJSONLinesSink(w io.Writer) Sink {
+	return &jsonLinesSink{w: w}
+}
+
+func (s *jsonLinesSink) Write(_ context.Context, event *models.AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/audit"
+	"github.com/sri-shubham/snipr/internal/auth"
+	"github.com/sri-shubham/snipr/internal/clientip"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/logging"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	old := map[string]any{"url": "https://a.com", "redirect_type": 302, "note": "gone"}
+	new := map[string]any{"url": "https://b.com", "redirect_type": 302, "tag": "added"}
+
+	diff, err := audit.Diff(old, new)
+	require.Nil(t, err)
+	require.Len(t, diff, 3)
+	require.JSONEq(t, `"https://a.com"`, string(diff["url"].Old))
+	require.JSONEq(t, `"https://b.com"`, string(diff["url"].New))
+	require.JSONEq(t, `"gone"`, string(diff["note"].Old))
+	require.JSONEq(t, `null`, string(diff["note"].New))
+	require.JSONEq(t, `null`, string(diff["tag"].Old))
+	require.JSONEq(t, `"added"`, string(diff["tag"].New))
+
+	diff, err = audit.Diff(nil, new)
+	require.Nil(t, err)
+	require.Len(t, diff, 3)
+}
+
+func TestRecord(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resolver, err := clientip.New([]string{"10.0.0.0/8"})
+	require.Nil(t, err)
+	storageMock := storage.NewMockAuditStorage(ctrl)
+	auditLog := audit.New(resolver, slog.Default(), audit.NewStorageSink(storageMock))
+
+	req := httptest.NewRequest("PATCH", "/api/links/sniper", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	// Who made the change comes from authentication, not from headers
+	req.Header.Set("X-Actor", "mallory")
+	req = req.WithContext(logging.WithRequestID(auth.WithIdentity(req.Context(), "alice"), "req-1"))
+
+	storageMock.EXPECT().StoreAuditEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event *models.AuditEvent) error {
+		require.Equal(t, audit.ActionUpdate, event.Action)
+		require.Equal(t, "alice", event.Actor)
+		require.Equal(t, "203.0.113.7", event.SourceIP)
+		require.Equal(t, "req-1", event.RequestID)
+		require.Equal(t, "https://snipr.com/sniper", event.ShortURL)
+		require.False(t, event.Time.IsZero())
+		require.Len(t, event.Diff, 1)
+		require.JSONEq(t, `308`, string(event.Diff["redirect_type"].New))
+		return nil
+	})
+	err = auditLog.Record(req.Context(), req, audit.ActionUpdate, "https://snipr.com/sniper",
+		models.LinkState{URL: "https://a.com", LinkSettings: models.LinkSettings{RedirectType: 302}},
+		models.LinkState{URL: "https://a.com", LinkSettings: models.LinkSettings{RedirectType: 308}})
+	require.Nil(t, err)
+
+	// A nil log records nothing
+	var noLog *audit.Log
+	require.Nil(t, noLog.Record(req.Context(), req, audit.ActionUpdate, "https://snipr.com/sniper", nil, nil))
+	require.Nil(t, noLog.Close(context.Background()))
+}
+
+func TestRecordFailsWithItsSink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockAuditStorage(ctrl)
+	auditLog := audit.New(nil, slog.Default(), audit.NewStorageSink(storageMock))
+
+	req := httptest.NewRequest("DELETE", "/api/links/sniper", nil)
+	storageMock.EXPECT().StoreAuditEvent(gomock.Any(), gomock.Any()).Return(errors.New("connection reset"))
+	err := auditLog.Record(req.Context(), req, audit.ActionDelete, "https://snipr.com/sniper", nil, nil)
+	require.NotNil(t, err)
+}
+
+func TestOpenFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(&config.AuditConfig{Sinks: []string{audit.SinkFile}, File: path}, nil, nil, slog.Default())
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/shorten", nil)
+	require.Nil(t, auditLog.Record(req.Context(), req, audit.ActionCreate, "https://snipr.com/a", nil, map[string]string{"url": "https://a.com"}))
+	require.Nil(t, auditLog.Record(req.Context(), req, audit.ActionDelete, "https://snipr.com/a", map[string]string{"url": "https://a.com"}, nil))
+	require.Nil(t, auditLog.Close(context.Background()))
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+
+	event := &models.AuditEvent{}
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), event))
+	require.Equal(t, audit.ActionCreate, event.Action)
+	require.Equal(t, "anonymous", event.Actor)
+	require.JSONEq(t, `"https://a.com"`, string(event.Diff["url"].New))
+}
+
+func TestOpenInvalidSinks(t *testing.T) {
+	_, err := audit.Open(&config.AuditConfig{Sinks: []string{"kafka"}}, nil, nil, slog.Default())
+	require.NotNil(t, err)
+
+	_, err = audit.Open(&config.AuditConfig{Sinks: []string{audit.SinkFile}}, nil, nil, slog.Default())
+	require.NotNil(t, err)
+}
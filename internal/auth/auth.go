@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sri-shubham/snipr/internal/config"
+)
+
+// minTokenLength keeps guessable tokens out of config.
+const minTokenLength = 16
+
+// ErrUnauthenticated is returned for requests without a valid API token.
+var ErrUnauthenticated = errors.New("missing or invalid API token")
+
+type identityKey struct{}
+
+// Authenticator identifies API clients by the bearer token they send. Only
+// hashes of the tokens are kept in memory.
+type Authenticator struct {
+	tokens map[[sha256.Size]byte]string
+}
+
+// New builds an Authenticator accepting the tokens in conf. Without conf
+// no request is authenticated.
+func New(conf *config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{tokens: map[[sha256.Size]byte]string{}}
+	if conf == nil {
+		return a, nil
+	}
+
+	for _, token := range conf.Tokens {
+		if token.Name == "" {
+			return nil, errors.New("api token without a name")
+		}
+		if len(token.Token) < minTokenLength {
+			return nil, fmt.Errorf("api token of %q is shorter than %d characters", token.Name, minTokenLength)
+		}
+		sum := sha256.Sum256([]byte(token.Token))
+		if _, ok := a.tokens[sum]; ok {
+			return nil, fmt.Errorf("api token of %q is already used", token.Name)
+		}
+		a.tokens[sum] = token.Name
+	}
+	return a, nil
+}
+
+// Enabled reports whether any token is accepted.
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0
+}
+
+// Identify returns the name of the token r sends in its Authorization
+// header.
+func (a *Authenticator) Identify(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	name, ok := a.tokens[sha256.Sum256([]byte(strings.TrimSpace(token)))]
+	return name, ok
+}
+
+// Middleware adds the identity of requests sending a valid token to their
+// context. Other requests pass on anonymously; handlers that need an
+// identity check for one with Identity.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name, ok := a.Identify(r); ok {
+			r = r.WithContext(WithIdentity(r.Context(), name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithIdentity returns a copy of ctx carrying the authenticated name.
+func WithIdentity(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, identityKey{}, name)
+}
+
+// Identity returns the authenticated name ctx carries.
+func Identity(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(identityKey{}).(string)
+	return name, ok
+}
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/auth"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticator(t *testing.T) {
+	authenticator, err := auth.New(&config.AuthConfig{Tokens: []config.APIToken{
+		{Name: "alice", Token: "alice-0123456789abcdef"},
+		{Name: "deploy-bot", Token: "bot-0123456789abcdef"},
+	}})
+	require.Nil(t, err)
+	require.True(t, authenticator.Enabled())
+
+	for header, expected := range map[string]string{
+		"Bearer alice-0123456789abcdef": "alice",
+		"bearer bot-0123456789abcdef":   "deploy-bot",
+		"Bearer alice-0123456789abcdeX": "",
+		"Basic alice-0123456789abcdef":  "",
+		"alice-0123456789abcdef":        "",
+		"":                              "",
+	} {
+		var identity string
+		var identified bool
+		handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, identified = auth.Identity(r.Context())
+		}))
+
+		req := httptest.NewRequest("GET", "/api/audit", nil)
+		req.Header.Set("Authorization", header)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		require.Equal(t, expected, identity, header)
+		require.Equal(t, expected != "", identified, header)
+	}
+}
+
+func TestAuthenticatorRejectsBadTokens(t *testing.T) {
+	for name, tokens := range map[string][]config.APIToken{
+		"no name":   {{Token: "0123456789abcdef"}},
+		"too short": {{Name: "alice", Token: "secret"}},
+		"reused":    {{Name: "alice", Token: "0123456789abcdef"}, {Name: "bob", Token: "0123456789abcdef"}},
+	} {
+		_, err := auth.New(&config.AuthConfig{Tokens: tokens})
+		require.NotNil(t, err, name)
+	}
+
+	authenticator, err := auth.New(nil)
+	require.Nil(t, err)
+	require.False(t, authenticator.Enabled())
+}
@@ -0,0 +1,118 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DefaultHTTPPort is where the plain HTTP listener runs unless configured
+// otherwise. ACME CAs send http-01 challenges to port 80 only.
+const DefaultHTTPPort = 80
+
+// Setup builds the server TLS configuration described by conf. The returned
+// handler is meant for the plain HTTP listener: it answers ACME http-01
+// challenges and, with RedirectHTTP, redirects everything else to HTTPS on
+// httpsPort. It is nil when no plain HTTP listener is needed. Certificate
+// file watching stops when ctx is cancelled.
+func Setup(ctx context.Context, conf *config.TLSConfig, httpsPort int, defaultDomain string, logger *slog.Logger) (*tls.Config, http.Handler, error) {
+	var fallback http.Handler
+	if conf.RedirectHTTP {
+		fallback = RedirectHandler(httpsPort)
+	}
+
+	if conf.ACME != nil && conf.ACME.Enabled {
+		domains := conf.ACME.Domains
+		if len(domains) == 0 {
+			domains = []string{defaultDomain}
+		}
+
+		manager, err := newACMEManager(conf.ACME, domains)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fallback == nil {
+			fallback = http.NotFoundHandler()
+		}
+		logger.Info("Using ACME certificates", slog.Any("domains", domains))
+		return manager.TLSConfig(), manager.HTTPHandler(fallback), nil
+	}
+
+	if conf.CertFile == "" || conf.KeyFile == "" {
+		return nil, nil, errors.New("tls enabled without certFile/keyFile or acme")
+	}
+
+	reloader, err := NewReloader(conf.CertFile, conf.KeyFile, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := reloader.Watch(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to watch certificate files: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	return tlsConfig, fallback, nil
+}
+
+// RedirectHandler permanently redirects every request to the same host and
+// path over HTTPS.
+func RedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 0 && httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func newACMEManager(conf *config.ACMEConfig, domains []string) (*autocert.Manager, error) {
+	client := &acme.Client{DirectoryURL: conf.DirectoryURL}
+	if conf.CACertFile != "" {
+		pem, err := os.ReadFile(conf.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read acme ca cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", conf.CACertFile)
+		}
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		}
+	}
+
+	manager := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		HostPolicy:  autocert.HostWhitelist(domains...),
+		Email:       conf.Email,
+		RenewBefore: conf.RenewBefore,
+		Client:      client,
+	}
+	if conf.CacheDir != "" {
+		manager.Cache = autocert.DirCache(conf.CacheDir)
+	}
+
+	return manager, nil
+}
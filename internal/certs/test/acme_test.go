@@ -0,0 +1,259 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/certs"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// acmeServer is a minimal ACME CA issuing one certificate for domain. It
+// validates the http-01 challenge against challenges, the handler snipr
+// serves on its plain HTTP listener.
+type acmeServer struct {
+	*httptest.Server
+
+	t          *testing.T
+	domain     string
+	caKey      *ecdsa.PrivateKey
+	caCert     *x509.Certificate
+	challenges http.Handler
+
+	mu        sync.Mutex
+	validated bool
+	issued    []byte
+}
+
+const challengeToken = "token"
+
+func newACMEServer(t *testing.T, domain string) *acmeServer {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	require.Nil(t, err)
+	caCert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+
+	s := &acmeServer{t: t, domain: domain, caKey: caKey, caCert: caCert}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *acmeServer) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", base64.RawURLEncoding.EncodeToString([]byte(time.Now().String())))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	url := s.URL
+	switch r.URL.Path {
+	case "/dir":
+		writeJSON(w, http.StatusOK, map[string]string{
+			"newNonce":   url + "/nonce",
+			"newAccount": url + "/account",
+			"newOrder":   url + "/order",
+			"revokeCert": url + "/revoke",
+			"keyChange":  url + "/key-change",
+		})
+	case "/account":
+		w.Header().Set("Location", url+"/account/1")
+		writeJSON(w, http.StatusCreated, map[string]string{"status": "valid"})
+	case "/order":
+		w.Header().Set("Location", url+"/order/1")
+		writeJSON(w, http.StatusCreated, s.order())
+	case "/order/1":
+		writeJSON(w, http.StatusOK, s.order())
+	case "/authz/1":
+		writeJSON(w, http.StatusOK, s.authorization())
+	case "/challenge/1":
+		s.validate()
+		writeJSON(w, http.StatusOK, s.challenge())
+	case "/finalize/1":
+		s.finalize(r)
+		writeJSON(w, http.StatusOK, s.order())
+	case "/cert/1":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_, _ = w.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.issued}))
+		_, _ = w.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.caCert.Raw}))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *acmeServer) status() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.issued != nil:
+		return "valid"
+	case s.validated:
+		return "ready"
+	default:
+		return "pending"
+	}
+}
+
+func (s *acmeServer) order() map[string]any {
+	order := map[string]any{
+		"status":         s.status(),
+		"identifiers":    []map[string]string{{"type": "dns", "value": s.domain}},
+		"authorizations": []string{s.URL + "/authz/1"},
+		"finalize":       s.URL + "/finalize/1",
+	}
+	if order["status"] == "valid" {
+		order["certificate"] = s.URL + "/cert/1"
+	}
+	return order
+}
+
+func (s *acmeServer) authorization() map[string]any {
+	status := "pending"
+	if s.status() != "pending" {
+		status = "valid"
+	}
+	return map[string]any{
+		"status":     status,
+		"identifier": map[string]string{"type": "dns", "value": s.domain},
+		"challenges": []any{s.challenge()},
+	}
+}
+
+func (s *acmeServer) challenge() map[string]any {
+	status := "pending"
+	if s.status() != "pending" {
+		status = "valid"
+	}
+	return map[string]any{
+		"type":   "http-01",
+		"url":    s.URL + "/challenge/1",
+		"token":  challengeToken,
+		"status": status,
+	}
+}
+
+// validate fetches the key authorization the way a CA would, from port 80
+// of the domain, which here is the challenge handler itself.
+func (s *acmeServer) validate() {
+	req := httptest.NewRequest(http.MethodGet, "http://"+s.domain+"/.well-known/acme-challenge/"+challengeToken, nil)
+	rec := httptest.NewRecorder()
+	s.challenges.ServeHTTP(rec, req)
+	require.Equal(s.t, http.StatusOK, rec.Code)
+	require.True(s.t, strings.HasPrefix(rec.Body.String(), challengeToken+"."), rec.Body.String())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validated = true
+}
+
+func (s *acmeServer) finalize(r *http.Request) {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	require.Nil(s.t, json.NewDecoder(r.Body).Decode(&jws))
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	require.Nil(s.t, err)
+	var body struct {
+		CSR string `json:"csr"`
+	}
+	require.Nil(s.t, json.Unmarshal(payload, &body))
+	csrDER, err := base64.RawURLEncoding.DecodeString(body.CSR)
+	require.Nil(s.t, err)
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	require.Nil(s.t, err)
+	require.Equal(s.t, []string{s.domain}, csr.DNSNames)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: s.domain},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.caCert, csr.PublicKey, s.caKey)
+	require.Nil(s.t, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issued = der
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestACMEIssuesCertificate(t *testing.T) {
+	const domain = "snipr.test"
+	ca := newACMEServer(t, domain)
+	defer ca.Close()
+
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.Nil(t, os.WriteFile(caCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate().Raw}), 0o600))
+
+	tlsConfig, handler, err := certs.Setup(context.Background(), &config.TLSConfig{
+		Enabled:      true,
+		RedirectHTTP: true,
+		ACME: &config.ACMEConfig{
+			Enabled:      true,
+			DirectoryURL: ca.URL + "/dir",
+			CACertFile:   caCertFile,
+			Domains:      []string{domain},
+			CacheDir:     t.TempDir(),
+		},
+	}, 443, "localhost", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.Nil(t, err)
+	require.NotNil(t, handler)
+	ca.challenges = handler
+
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+	require.Nil(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.Nil(t, err)
+	require.Equal(t, []string{domain}, leaf.DNSNames)
+	require.Nil(t, leaf.CheckSignatureFrom(ca.caCert))
+
+	// Other hosts are not issued for
+	_, err = tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.test"})
+	require.NotNil(t, err)
+
+	// Everything but challenges is redirected to HTTPS
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://"+domain+"/abc123", nil))
+	require.Equal(t, http.StatusPermanentRedirect, rec.Code)
+	require.Equal(t, "https://"+domain+"/abc123", rec.Header().Get("Location"))
+}
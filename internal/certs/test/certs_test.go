@@ -0,0 +1,102 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/certs"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyPair(t *testing.T, dir string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	// Write the key first so the reload triggered by the cert write sees a
+	// matching pair.
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "tls.key.tmp"), keyPEM, 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "tls.crt.tmp"), certPEM, 0o600))
+	require.Nil(t, os.Rename(filepath.Join(dir, "tls.key.tmp"), filepath.Join(dir, "tls.key")))
+	require.Nil(t, os.Rename(filepath.Join(dir, "tls.crt.tmp"), filepath.Join(dir, "tls.crt")))
+}
+
+func servedSerial(t *testing.T, reloader *certs.Reloader) int64 {
+	cert, err := reloader.GetCertificate(nil)
+	require.Nil(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.Nil(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestReloaderPicksUpNewCertificate(t *testing.T) {
+	dir := t.TempDir()
+	writeKeyPair(t, dir, 1)
+
+	reloader, err := certs.NewReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), slog.Default())
+	require.Nil(t, err)
+	require.Equal(t, int64(1), servedSerial(t, reloader))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.Nil(t, reloader.Watch(ctx))
+
+	writeKeyPair(t, dir, 2)
+	require.Eventually(t, func() bool {
+		return servedSerial(t, reloader) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestReloaderKeepsCertificateOnBadReload(t *testing.T) {
+	dir := t.TempDir()
+	writeKeyPair(t, dir, 1)
+
+	reloader, err := certs.NewReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), slog.Default())
+	require.Nil(t, err)
+
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "tls.crt"), []byte("garbage"), 0o600))
+	require.NotNil(t, reloader.Reload())
+	require.Equal(t, int64(1), servedSerial(t, reloader))
+}
+
+func TestRedirectHandler(t *testing.T) {
+	handler := certs.RedirectHandler(8443)
+
+	req := httptest.NewRequest("GET", "http://snipr.local:8081/abc?x=1", nil)
+	respWriter := httptest.NewRecorder()
+	handler.ServeHTTP(respWriter, req)
+	require.Equal(t, http.StatusPermanentRedirect, respWriter.Result().StatusCode)
+	require.Equal(t, "https://snipr.local:8443/abc?x=1", respWriter.Header().Get("Location"))
+
+	handler = certs.RedirectHandler(443)
+	respWriter = httptest.NewRecorder()
+	handler.ServeHTTP(respWriter, req)
+	require.Equal(t, "https://snipr.local/abc?x=1", respWriter.Header().Get("Location"))
+}
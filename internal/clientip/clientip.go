@@ -0,0 +1,121 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolver finds the address of the client behind a request. The
+// X-Forwarded-For header is only believed when the connection comes from a
+// trusted proxy, so clients can not spoof their address.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// New builds a Resolver trusting the given IPs and CIDRs.
+func New(trustedProxies []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, proxy := range trustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		r.trusted = append(r.trusted, prefix)
+	}
+	return r, nil
+}
+
+// IP returns the client address of req. X-Forwarded-For is walked from the
+// right, skipping trusted proxies, and the first untrusted hop is the
+// client. The zero Addr is returned if the peer address can not be parsed.
+func (r *Resolver) IP(req *http.Request) netip.Addr {
+	peer := remoteAddr(req.RemoteAddr)
+	if r == nil || !r.isTrusted(peer) {
+		return peer
+	}
+
+	hops := forwardedFor(req.Header.Values("X-Forwarded-For"))
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// A malformed hop is not trustworthy either; stop at the last
+			// address we can vouch for.
+			return client
+		}
+		client = hop.Unmap()
+		if !r.isTrusted(client) {
+			return client
+		}
+	}
+	return client
+}
+
+// Secure reports whether the client reached us over HTTPS, either directly
+// or, when the connection comes from a trusted proxy, as told by the last
+// X-Forwarded-Proto header.
+func (r *Resolver) Secure(req *http.Request) bool {
+	if req.TLS != nil {
+		return true
+	}
+	if r == nil || !r.isTrusted(remoteAddr(req.RemoteAddr)) {
+		return false
+	}
+
+	protos := forwardedFor(req.Header.Values("X-Forwarded-Proto"))
+	return len(protos) > 0 && strings.EqualFold(protos[len(protos)-1], "https")
+}
+
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func remoteAddr(remote string) netip.Addr {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// forwardedFor flattens every X-Forwarded-For (or X-Forwarded-Proto) header
+// into a list of hops, leftmost (original client) first.
+func forwardedFor(headers []string) []string {
+	var hops []string
+	for _, header := range headers {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
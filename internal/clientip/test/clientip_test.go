@@ -0,0 +1,77 @@
+package test
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/clientip"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := clientip.New([]string{"10.0.0.0/8", "192.0.2.1"})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name         string
+		remote       string
+		forwardedFor []string
+		expected     string
+	}{
+		{"no proxy", "203.0.113.7:4321", nil, "203.0.113.7"},
+		{"untrusted peer is not believed", "203.0.113.7:4321", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted peer", "10.1.2.3:4321", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed leftmost hop is skipped", "10.1.2.3:4321", []string{"1.1.1.1, 198.51.100.1, 10.9.9.9"}, "198.51.100.1"},
+		{"multiple headers", "192.0.2.1:80", []string{"198.51.100.1", "10.0.0.2"}, "198.51.100.1"},
+		{"only proxies", "10.1.2.3:4321", []string{"10.0.0.5"}, "10.0.0.5"},
+		{"malformed hop", "10.1.2.3:4321", []string{"198.51.100.1, nonsense"}, "10.1.2.3"},
+		{"ipv6", "[2001:db8::1]:443", nil, "2001:db8::1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remote
+		for _, header := range test.forwardedFor {
+			req.Header.Add("X-Forwarded-For", header)
+		}
+		require.Equal(t, test.expected, resolver.IP(req).String(), test.name)
+	}
+}
+
+func TestSecure(t *testing.T) {
+	resolver, err := clientip.New([]string{"10.0.0.0/8"})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name           string
+		remote         string
+		tls            bool
+		forwardedProto []string
+		expected       bool
+	}{
+		{"plain http", "203.0.113.7:4321", false, nil, false},
+		{"direct https", "203.0.113.7:4321", true, nil, true},
+		{"untrusted peer is not believed", "203.0.113.7:4321", false, []string{"https"}, false},
+		{"trusted proxy", "10.1.2.3:4321", false, []string{"https"}, true},
+		{"trusted proxy over http", "10.1.2.3:4321", false, []string{"http"}, false},
+		{"last proxy wins", "10.1.2.3:4321", false, []string{"https, http"}, false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remote
+		if test.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		for _, header := range test.forwardedProto {
+			req.Header.Add("X-Forwarded-Proto", header)
+		}
+		require.Equal(t, test.expected, resolver.Secure(req), test.name)
+	}
+}
+
+func TestClientIPInvalidProxy(t *testing.T) {
+	_, err := clientip.New([]string{"10.0.0.0/33"})
+	require.NotNil(t, err)
+}
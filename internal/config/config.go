@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -14,6 +17,23 @@ type AppConfig struct {
 	Redis     *RedisConfig     `mapstructure:"redis"`
 	Postgres  *PostgresConfig  `mapstructure:"postgres"`
 	Shortener *ShortenerConfig `mapstructure:"shortener"`
+	Telemetry *TelemetryConfig `mapstructure:"telemetry"`
+	Log       *LogConfig       `mapstructure:"log"`
+	Server    *ServerConfig    `mapstructure:"server"`
+	TLS       *TLSConfig       `mapstructure:"tls"`
+	Redirect  *RedirectConfig  `mapstructure:"redirect"`
+	GeoIP     *GeoIPConfig     `mapstructure:"geoIP"`
+	Analytics *AnalyticsConfig `mapstructure:"analytics"`
+	Passwords *PasswordConfig  `mapstructure:"passwords"`
+	Pages     *PagesConfig     `mapstructure:"pages"`
+	Sweeper   *SweeperConfig   `mapstructure:"sweeper"`
+	Archive   *ArchiveConfig   `mapstructure:"archive"`
+	Audit     *AuditConfig     `mapstructure:"audit"`
+	Webhooks  *WebhookConfig   `mapstructure:"webhooks"`
+	Prober    *ProberConfig    `mapstructure:"prober"`
+	Unfurl    *UnfurlConfig    `mapstructure:"unfurl"`
+	QR        *QRConfig        `mapstructure:"qr"`
+	Auth      *AuthConfig      `mapstructure:"auth"`
 }
 
 type ShortenerConfig struct {
@@ -39,6 +59,244 @@ type PostgresConfig struct {
 	Password string `mapstructure:"password"`
 }
 
+// TelemetryConfig controls OpenTelemetry tracing and metrics. Exporter is
+// one of "otlp", "stdout" or "none"; both are disabled when Enabled is false.
+type TelemetryConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	ServiceName string  `mapstructure:"serviceName"`
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sampleRatio"`
+}
+
+// LogConfig controls the structured logger. Level is one of debug, info,
+// warn or error and Format is json or text. RedactURLs reduces logged URLs to
+// their scheme and host; RedactQuery masks query string values.
+type LogConfig struct {
+	Level       string `mapstructure:"level"`
+	Format      string `mapstructure:"format"`
+	RedactURLs  bool   `mapstructure:"redactURLs"`
+	RedactQuery bool   `mapstructure:"redactQuery"`
+}
+
+// ServerConfig tunes the HTTP server. The server listens on
+// ListenAddress:Port. On SIGTERM/SIGINT readiness fails for ShutdownDelay
+// before the listener closes, then in-flight requests get up to
+// ShutdownTimeout to finish. X-Forwarded-For is only trusted from peers in
+// TrustedProxies, a list of IPs or CIDRs.
+type ServerConfig struct {
+	ListenAddress     string        `mapstructure:"listenAddress"`
+	ReadTimeout       time.Duration `mapstructure:"readTimeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `mapstructure:"writeTimeout"`
+	IdleTimeout       time.Duration `mapstructure:"idleTimeout"`
+	MaxHeaderBytes    int           `mapstructure:"maxHeaderBytes"`
+	ShutdownDelay     time.Duration `mapstructure:"shutdownDelay"`
+	ShutdownTimeout   time.Duration `mapstructure:"shutdownTimeout"`
+	TrustedProxies    []string      `mapstructure:"trustedProxies"`
+}
+
+// TLSConfig makes the server speak HTTPS on Port. Certificates come from
+// CertFile/KeyFile, reloaded whenever either file changes, or from an ACME
+// CA when ACME.Enabled is set. With RedirectHTTP a plain HTTP listener on
+// HTTPPort redirects to HTTPS; it also answers ACME http-01 challenges,
+// which CAs only ever send to port 80, so HTTPPort defaults to 80.
+type TLSConfig struct {
+	Enabled      bool        `mapstructure:"enabled"`
+	CertFile     string      `mapstructure:"certFile"`
+	KeyFile      string      `mapstructure:"keyFile"`
+	RedirectHTTP bool        `mapstructure:"redirectHTTP"`
+	HTTPPort     int         `mapstructure:"httpPort"`
+	ACME         *ACMEConfig `mapstructure:"acme"`
+}
+
+// ACMEConfig obtains certificates for Domains from the CA at DirectoryURL.
+// CACertFile adds trusted roots for the directory itself, which is needed
+// for local test CAs such as pebble.
+type ACMEConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	DirectoryURL string        `mapstructure:"directoryURL"`
+	Email        string        `mapstructure:"email"`
+	CacheDir     string        `mapstructure:"cacheDir"`
+	Domains      []string      `mapstructure:"domains"`
+	CACertFile   string        `mapstructure:"caCertFile"`
+	RenewBefore  time.Duration `mapstructure:"renewBefore"`
+}
+
+// RedirectConfig holds service wide redirect defaults. DefaultType is used
+// for links created without a redirect type. Permanent redirects are cached
+// by clients for PermanentMaxAge only, so edited links take effect soon.
+// Expired links without a fallback of their own redirect to the
+// DomainFallbacks entry for the domain of their destination, then to
+// ExpiryFallback; without one they answer 410 Gone.
+type RedirectConfig struct {
+	DefaultType     int              `mapstructure:"defaultType"`
+	PermanentMaxAge time.Duration    `mapstructure:"permanentMaxAge"`
+	ExpiryFallback  string           `mapstructure:"expiryFallback"`
+	DomainFallbacks []DomainFallback `mapstructure:"domainFallbacks"`
+}
+
+// DomainFallback is where expired links to Domain send visitors.
+type DomainFallback struct {
+	Domain string `mapstructure:"domain"`
+	URL    string `mapstructure:"url"`
+}
+
+// Validate rejects fallbacks that are not absolute http or https URLs, so
+// a typo fails at startup rather than on the first expired link.
+func (c *RedirectConfig) Validate() error {
+	if c.ExpiryFallback != "" && !httpURL(c.ExpiryFallback) {
+		return fmt.Errorf("redirect.expiryFallback %q must be an absolute http or https url", c.ExpiryFallback)
+	}
+	for _, fallback := range c.DomainFallbacks {
+		if fallback.Domain == "" {
+			return errors.New("redirect.domainFallbacks entries need a domain")
+		}
+		if !httpURL(fallback.URL) {
+			return fmt.Errorf("redirect.domainFallbacks url %q of %s must be an absolute http or https url", fallback.URL, fallback.Domain)
+		}
+	}
+	return nil
+}
+
+// GeoIPConfig points at a MaxMind format (mmdb) country or city database on
+// disk. Geo targeting is disabled while DatabasePath is empty.
+type GeoIPConfig struct {
+	DatabasePath string `mapstructure:"databasePath"`
+}
+
+// AnalyticsConfig tunes click recording. Up to BufferSize clicks are queued
+// in memory and written in batches of BatchSize at least every
+// FlushInterval; clicks arriving while the queue is full are dropped.
+type AnalyticsConfig struct {
+	BufferSize    int           `mapstructure:"bufferSize"`
+	BatchSize     int           `mapstructure:"batchSize"`
+	FlushInterval time.Duration `mapstructure:"flushInterval"`
+}
+
+// PasswordConfig tunes password protected links. After MaxAttempts wrong
+// passwords from one client for a link, it is locked out for Lockout. The
+// correct password sets a cookie signed with CookieSecret that is valid for
+// CookieTTL; a zero CookieTTL disables the cookie.
+type PasswordConfig struct {
+	CookieSecret string        `mapstructure:"cookieSecret"`
+	CookieTTL    time.Duration `mapstructure:"cookieTTL"`
+	MaxAttempts  int           `mapstructure:"maxAttempts"`
+	Lockout      time.Duration `mapstructure:"lockout"`
+}
+
+// PagesConfig replaces built in HTML pages with html/template files.
+// Exhausted is served with 410 Gone once a link reached its click limit,
+// Unavailable with 403 Forbidden before a link's activation time or outside
+// its availability windows, and Expired with 410 Gone after a link expired
+// when there is no fallback to redirect to. Preview is served at /{code}+
+// and to link preview crawlers.
+type PagesConfig struct {
+	Exhausted   string `mapstructure:"exhausted"`
+	Unavailable string `mapstructure:"unavailable"`
+	Expired     string `mapstructure:"expired"`
+	Preview     string `mapstructure:"preview"`
+}
+
+// SweeperConfig controls the removal of expired links. Every Interval, links
+// that expired more than GracePeriod ago are archived in batches of
+// BatchSize. Until then they keep serving the expiry fallback. Only one
+// instance sweeps at a time. The sweeper also sends link.expired webhook
+// events, every Interval.
+type SweeperConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Interval    time.Duration `mapstructure:"interval"`
+	BatchSize   int           `mapstructure:"batchSize"`
+	GracePeriod time.Duration `mapstructure:"gracePeriod"`
+}
+
+// ArchiveConfig controls archived links. Codes of archived links are not
+// given to new links for Quarantine, a year if unset; a negative
+// Quarantine keeps them out of use forever.
+type ArchiveConfig struct {
+	Quarantine time.Duration `mapstructure:"quarantine"`
+}
+
+// AuditConfig lists where audit events are written: any of "postgres",
+// "file" and "stdout". The file sink appends JSON lines to File.
+type AuditConfig struct {
+	Sinks []string `mapstructure:"sinks"`
+	File  string   `mapstructure:"file"`
+}
+
+// WebhookConfig controls webhook delivery. Every PollInterval up to
+// BatchSize due deliveries are posted, each given Timeout to be answered.
+// Failed deliveries are retried after MinBackoff, doubling up to
+// MaxBackoff, and dead-lettered after MaxAttempts. link.click_limit_reached
+// is sent once a link used ClickLimitPercent of its clicks. Endpoints on
+// loopback, private and link-local addresses are only allowed with
+// AllowPrivateNetworks set.
+type WebhookConfig struct {
+	Enabled              bool          `mapstructure:"enabled"`
+	PollInterval         time.Duration `mapstructure:"pollInterval"`
+	BatchSize            int           `mapstructure:"batchSize"`
+	Timeout              time.Duration `mapstructure:"timeout"`
+	MaxAttempts          int           `mapstructure:"maxAttempts"`
+	MinBackoff           time.Duration `mapstructure:"minBackoff"`
+	MaxBackoff           time.Duration `mapstructure:"maxBackoff"`
+	ClickLimitPercent    int           `mapstructure:"clickLimitPercent"`
+	AllowPrivateNetworks bool          `mapstructure:"allowPrivateNetworks"`
+}
+
+// ProberConfig controls the checking of link destinations. Every
+// PollInterval up to BatchSize links not checked within Interval are
+// probed, Concurrency at a time, waiting HostDelay between requests to the
+// same host. A probe may take Timeout and follow MaxRedirects redirects.
+// Links are flagged broken after FailureThreshold failed probes in a row.
+// Destinations on loopback, private and link-local addresses are only
+// probed with AllowPrivateNetworks set.
+type ProberConfig struct {
+	Enabled              bool          `mapstructure:"enabled"`
+	PollInterval         time.Duration `mapstructure:"pollInterval"`
+	Interval             time.Duration `mapstructure:"interval"`
+	BatchSize            int           `mapstructure:"batchSize"`
+	Concurrency          int           `mapstructure:"concurrency"`
+	HostDelay            time.Duration `mapstructure:"hostDelay"`
+	Timeout              time.Duration `mapstructure:"timeout"`
+	MaxRedirects         int           `mapstructure:"maxRedirects"`
+	FailureThreshold     int           `mapstructure:"failureThreshold"`
+	AllowPrivateNetworks bool          `mapstructure:"allowPrivateNetworks"`
+}
+
+// UnfurlConfig controls fetching destination metadata for links created
+// with unfurl set. A fetch may take Timeout and reads at most MaxBytes of
+// the destination page. Destinations on loopback, private and link-local
+// addresses are only fetched with AllowPrivateNetworks set.
+type UnfurlConfig struct {
+	Enabled              bool          `mapstructure:"enabled"`
+	Timeout              time.Duration `mapstructure:"timeout"`
+	MaxBytes             int64         `mapstructure:"maxBytes"`
+	AllowPrivateNetworks bool          `mapstructure:"allowPrivateNetworks"`
+}
+
+// QRConfig controls QR codes of links. Logo is a PNG, JPEG or GIF file
+// centred on codes that ask for it. Codes are at most MaxSize pixels wide.
+type QRConfig struct {
+	Logo    string `mapstructure:"logo"`
+	MaxSize int    `mapstructure:"maxSize"`
+}
+
+// AuthConfig lists the API tokens of the management API under /api/.
+// Clients send one as "Authorization: Bearer <token>", and the changes they
+// make are recorded under its Name. Without tokens the management API
+// refuses every request.
+type AuthConfig struct {
+	Tokens []APIToken `mapstructure:"tokens"`
+}
+
+// APIToken is a secret Token identifying the client called Name. Tokens
+// are at least 16 characters long.
+type APIToken struct {
+	Name  string `mapstructure:"name"`
+	Token string `mapstructure:"token"`
+}
+
 var conf *AppConfig
 var once *sync.Once = &sync.Once{}
 
@@ -62,6 +320,13 @@ func ParseConfig(path string) (*AppConfig, error) {
 			err = fmt.Errorf("failed to unmarshal config: %w", err)
 			return
 		}
+
+		if conf.Redirect != nil {
+			if err = conf.Redirect.Validate(); err != nil {
+				err = fmt.Errorf("invalid config: %w", err)
+				return
+			}
+		}
 	})
 	if err != nil {
 		return nil, err
@@ -69,3 +334,8 @@ func ParseConfig(path string) (*AppConfig, error) {
 
 	return conf, nil
 }
+
+func httpURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
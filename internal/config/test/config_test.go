@@ -2,6 +2,7 @@ package test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/sri-shubham/snipr/internal/config"
 	"github.com/stretchr/testify/require"
@@ -33,4 +34,111 @@ func TestParseConfig(t *testing.T) {
 	require.Equal(t, 7, appConf.Shortener.CustomMinLength)
 	require.Equal(t, 16, appConf.Shortener.CustomMaxLength)
 
+	require.NotNil(t, appConf.Redirect)
+	require.Equal(t, 302, appConf.Redirect.DefaultType)
+	require.Equal(t, 10*time.Minute, appConf.Redirect.PermanentMaxAge)
+	require.Equal(t, "", appConf.Redirect.ExpiryFallback)
+	require.Equal(t, []config.DomainFallback{{Domain: "shop.example.com", URL: "https://shop.example.com/"}}, appConf.Redirect.DomainFallbacks)
+
+	require.NotNil(t, appConf.Telemetry)
+	require.False(t, appConf.Telemetry.Enabled)
+	require.Equal(t, "snipr", appConf.Telemetry.ServiceName)
+	require.Equal(t, "stdout", appConf.Telemetry.Exporter)
+	require.Equal(t, float64(1), appConf.Telemetry.SampleRatio)
+
+	require.NotNil(t, appConf.Log)
+	require.Equal(t, "info", appConf.Log.Level)
+	require.Equal(t, "json", appConf.Log.Format)
+	require.False(t, appConf.Log.RedactURLs)
+	require.True(t, appConf.Log.RedactQuery)
+
+	require.NotNil(t, appConf.Server)
+	require.Equal(t, "0.0.0.0", appConf.Server.ListenAddress)
+	require.Equal(t, 10*time.Second, appConf.Server.ReadTimeout)
+	require.Equal(t, 15*time.Second, appConf.Server.WriteTimeout)
+	require.Equal(t, 16384, appConf.Server.MaxHeaderBytes)
+	require.Equal(t, 20*time.Second, appConf.Server.ShutdownTimeout)
+	require.Equal(t, []string{"10.0.0.0/8", "127.0.0.1"}, appConf.Server.TrustedProxies)
+
+	require.NotNil(t, appConf.GeoIP)
+	require.Equal(t, "", appConf.GeoIP.DatabasePath)
+
+	require.NotNil(t, appConf.Analytics)
+	require.Equal(t, 10000, appConf.Analytics.BufferSize)
+	require.Equal(t, 500, appConf.Analytics.BatchSize)
+	require.Equal(t, time.Second, appConf.Analytics.FlushInterval)
+
+	require.NotNil(t, appConf.Passwords)
+	require.Equal(t, 10*time.Minute, appConf.Passwords.CookieTTL)
+	require.Equal(t, 5, appConf.Passwords.MaxAttempts)
+	require.Equal(t, 15*time.Minute, appConf.Passwords.Lockout)
+
+	require.NotNil(t, appConf.Pages)
+	require.Equal(t, "", appConf.Pages.Exhausted)
+	require.Equal(t, "", appConf.Pages.Expired)
+	require.Equal(t, "", appConf.Pages.Preview)
+
+	require.NotNil(t, appConf.Sweeper)
+	require.True(t, appConf.Sweeper.Enabled)
+	require.Equal(t, 10*time.Minute, appConf.Sweeper.Interval)
+	require.Equal(t, 1000, appConf.Sweeper.BatchSize)
+	require.Equal(t, 720*time.Hour, appConf.Sweeper.GracePeriod)
+
+	require.NotNil(t, appConf.Archive)
+	require.Equal(t, 8760*time.Hour, appConf.Archive.Quarantine)
+	require.NotNil(t, appConf.Audit)
+	require.Equal(t, []string{"postgres"}, appConf.Audit.Sinks)
+	require.Equal(t, "", appConf.Audit.File)
+	require.NotNil(t, appConf.Webhooks)
+	require.True(t, appConf.Webhooks.Enabled)
+	require.Equal(t, 5*time.Second, appConf.Webhooks.PollInterval)
+	require.Equal(t, 100, appConf.Webhooks.BatchSize)
+	require.Equal(t, 10*time.Second, appConf.Webhooks.Timeout)
+	require.Equal(t, 8, appConf.Webhooks.MaxAttempts)
+	require.Equal(t, 30*time.Second, appConf.Webhooks.MinBackoff)
+	require.Equal(t, 6*time.Hour, appConf.Webhooks.MaxBackoff)
+	require.Equal(t, 100, appConf.Webhooks.ClickLimitPercent)
+	require.False(t, appConf.Webhooks.AllowPrivateNetworks)
+	require.NotNil(t, appConf.Prober)
+	require.True(t, appConf.Prober.Enabled)
+	require.Equal(t, time.Minute, appConf.Prober.PollInterval)
+	require.Equal(t, 24*time.Hour, appConf.Prober.Interval)
+	require.Equal(t, 200, appConf.Prober.BatchSize)
+	require.Equal(t, 8, appConf.Prober.Concurrency)
+	require.Equal(t, time.Second, appConf.Prober.HostDelay)
+	require.Equal(t, 10*time.Second, appConf.Prober.Timeout)
+	require.Equal(t, 10, appConf.Prober.MaxRedirects)
+	require.Equal(t, 2, appConf.Prober.FailureThreshold)
+	require.False(t, appConf.Prober.AllowPrivateNetworks)
+
+	require.NotNil(t, appConf.Unfurl)
+	require.True(t, appConf.Unfurl.Enabled)
+	require.Equal(t, 3*time.Second, appConf.Unfurl.Timeout)
+	require.Equal(t, int64(512<<10), appConf.Unfurl.MaxBytes)
+	require.False(t, appConf.Unfurl.AllowPrivateNetworks)
+
+	require.NotNil(t, appConf.QR)
+	require.Equal(t, "", appConf.QR.Logo)
+	require.Equal(t, 2048, appConf.QR.MaxSize)
+
+	require.NotNil(t, appConf.Auth)
+	require.Equal(t, []config.APIToken{{Name: "ops", Token: "ops-0123456789abcdef"}}, appConf.Auth.Tokens)
+}
+
+func TestRedirectConfigValidate(t *testing.T) {
+	valid := &config.RedirectConfig{
+		ExpiryFallback:  "https://example.com/",
+		DomainFallbacks: []config.DomainFallback{{Domain: "shop.example.com", URL: "http://shop.example.com/sale"}},
+	}
+	require.Nil(t, valid.Validate())
+	require.Nil(t, (&config.RedirectConfig{}).Validate())
+
+	for _, invalid := range []*config.RedirectConfig{
+		{ExpiryFallback: "example.com"},
+		{ExpiryFallback: "javascript:alert(1)"},
+		{DomainFallbacks: []config.DomainFallback{{URL: "https://example.com/"}}},
+		{DomainFallbacks: []config.DomainFallback{{Domain: "shop.example.com", URL: "/sale"}}},
+	} {
+		require.NotNil(t, invalid.Validate(), invalid)
+	}
 }
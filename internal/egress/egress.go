@@ -0,0 +1,89 @@
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbidden is returned for connections to addresses outbound requests
+// may not reach.
+var ErrForbidden = errors.New("destination address is not allowed")
+
+// Allowed reports whether outbound requests made on behalf of users may
+// connect to addr. Loopback, private, link-local, multicast and
+// unspecified addresses belong to the network snipr runs in, and reaching
+// them would let anyone who can create a link probe that network.
+func Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!thisNetwork.Contains(addr)
+}
+
+// thisNetwork is 0.0.0.0/8, which Linux connects to the local host.
+var thisNetwork = netip.MustParsePrefix("0.0.0.0/8")
+
+// Control is a net.Dialer Control hook refusing connections to addresses
+// that are not Allowed. It runs for every connection after names are
+// resolved, so redirects and names resolving to internal addresses are
+// refused too.
+func Control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbidden, address)
+	}
+	if !Allowed(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbidden, addrPort.Addr())
+	}
+	return nil
+}
+
+// CheckHost rejects hosts that name the local machine or an address that
+// is not Allowed, for refusing URLs up front. Names resolving to such
+// addresses are only caught when connecting.
+func CheckHost(host string) error {
+	name := strings.TrimSuffix(strings.ToLower(host), ".")
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrForbidden, host)
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(name, "[]")); err == nil && !Allowed(addr) {
+		return fmt.Errorf("%w: %s", ErrForbidden, host)
+	}
+	return nil
+}
+
+// NewClient returns an HTTP client giving requests timeout that only
+// connects to Allowed addresses, unless allowPrivate is set for networks
+// where destinations are internal on purpose. Proxies from the environment
+// are not used, as the client would connect to the proxy rather than the
+// destination.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivate {
+		dialer.Control = Control
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/egress"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowed(t *testing.T) {
+	for addr, allowed := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"0.0.0.0":              false,
+		"0.1.2.3":              false,
+		"::":                   false,
+		"224.0.0.1":            false,
+		"::ffff:127.0.0.1":     false,
+	} {
+		require.Equal(t, allowed, egress.Allowed(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for host, allowed := range map[string]bool{
+		"example.com":     true,
+		"93.184.216.34":   true,
+		"localhost":       false,
+		"api.localhost.":  false,
+		"127.0.0.1":       false,
+		"[::1]":           false,
+		"169.254.169.254": false,
+	} {
+		err := egress.CheckHost(host)
+		require.Equal(t, allowed, err == nil, host)
+		if err != nil {
+			require.True(t, errors.Is(err, egress.ErrForbidden))
+		}
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, err := egress.NewClient(time.Second, false).Get(server.URL)
+	require.True(t, errors.Is(err, egress.ErrForbidden), err)
+
+	resp, err := egress.NewClient(time.Second, true).Get(server.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,59 @@
+//go:generate mockgen -source=geoip.go -destination geoip_mock.go -package geoip
+package geoip
+
+import (
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Resolver maps client addresses to ISO 3166-1 alpha-2 country codes.
+type Resolver interface {
+	// Country returns the country of ip, or "" if it is unknown.
+	Country(ip netip.Addr) string
+	Close() error
+}
+
+// record is the subset of the GeoIP2/GeoLite2 Country and City schemas
+// needed to find a country.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+type mmdbResolver struct {
+	reader *maxminddb.Reader
+}
+
+// Open memory maps the MaxMind format database at path. Lookups never leave
+// the process.
+func Open(path string) (Resolver, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &mmdbResolver{reader: reader}, nil
+}
+
+func (m *mmdbResolver) Country(ip netip.Addr) string {
+	if !ip.IsValid() {
+		return ""
+	}
+
+	var rec record
+	if err := m.reader.Lookup(ip.AsSlice(), &rec); err != nil {
+		return ""
+	}
+	if rec.Country.ISOCode != "" {
+		return rec.Country.ISOCode
+	}
+	return rec.RegisteredCountry.ISOCode
+}
+
+func (m *mmdbResolver) Close() error {
+	return m.reader.Close()
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/sri-shubham/snipr/internal/geoip"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDB builds a small country database mapping each network to a record.
+func writeDB(t *testing.T, networks map[string]mmdbtype.Map) string {
+	writer, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            "GeoLite2-Country",
+		IncludeReservedNetworks: true,
+	})
+	require.Nil(t, err)
+
+	for cidr, rec := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		require.Nil(t, err)
+		require.Nil(t, writer.Insert(network, rec))
+	}
+
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	f, err := os.Create(path)
+	require.Nil(t, err)
+	defer f.Close()
+	_, err = writer.WriteTo(f)
+	require.Nil(t, err)
+	return path
+}
+
+func country(field string, code string) mmdbtype.Map {
+	return mmdbtype.Map{
+		mmdbtype.String(field): mmdbtype.Map{"iso_code": mmdbtype.String(code)},
+	}
+}
+
+func TestCountry(t *testing.T) {
+	path := writeDB(t, map[string]mmdbtype.Map{
+		"203.0.113.0/24":  country("country", "DE"),
+		"198.51.100.0/24": country("registered_country", "FR"),
+		"2001:db8::/32":   country("country", "JP"),
+	})
+
+	resolver, err := geoip.Open(path)
+	require.Nil(t, err)
+	defer resolver.Close()
+
+	require.Equal(t, "DE", resolver.Country(netip.MustParseAddr("203.0.113.9")))
+	require.Equal(t, "FR", resolver.Country(netip.MustParseAddr("198.51.100.20")))
+	require.Equal(t, "JP", resolver.Country(netip.MustParseAddr("2001:db8::1")))
+	require.Equal(t, "", resolver.Country(netip.MustParseAddr("192.0.2.1")))
+	require.Equal(t, "", resolver.Country(netip.Addr{}))
+}
+
+func TestOpenMissingDatabase(t *testing.T) {
+	_, err := geoip.Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+	require.NotNil(t, err)
+}
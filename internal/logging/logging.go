@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	redacted = "REDACTED"
+)
+
+type requestIDKey struct{}
+
+// New builds a logger from conf writing to w. Any *url.URL attribute is
+// redacted according to conf before it reaches the output, and records
+// logged with a request context carry its request and trace IDs.
+func New(conf *config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	if conf == nil {
+		conf = &config.LogConfig{}
+	}
+
+	var level slog.Level
+	if conf.Level != "" {
+		if err := level.UnmarshalText([]byte(conf.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", conf.Level, err)
+		}
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if u, ok := a.Value.Any().(*url.URL); ok && a.Value.Kind() == slog.KindAny {
+				return slog.String(a.Key, RedactURL(u, conf.RedactURLs, conf.RedactQuery))
+			}
+			return a
+		},
+	}
+
+	var handler slog.Handler
+	switch conf.Format {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", conf.Format)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// RedactURL renders u for logging. With redactURL only the scheme and host
+// are kept; with redactQuery every query value is masked.
+func RedactURL(u *url.URL, redactURL bool, redactQuery bool) string {
+	if u == nil {
+		return ""
+	}
+
+	if redactURL {
+		out := &url.URL{Scheme: u.Scheme, Host: u.Host}
+		if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return out.String() + "/" + redacted
+		}
+		return out.String()
+	}
+
+	if redactQuery && u.RawQuery != "" {
+		out := *u
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{redacted}
+		}
+		out.RawQuery = query.Encode()
+		return out.String()
+	}
+
+	return u.String()
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID generates a random 128 bit request ID.
+func NewRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// contextHandler decorates records with request scoped attributes.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// sanitizeRequestID keeps caller supplied request IDs printable and short.
+func sanitizeRequestID(id string) string {
+	if len(id) > 128 {
+		return ""
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return ""
+		}
+	}
+	return strings.TrimSpace(id)
+}
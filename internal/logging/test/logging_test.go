@@ -0,0 +1,69 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactURL(t *testing.T) {
+	u, err := url.Parse("https://example.com/private/doc?token=secret&utm_source=mail")
+	require.Nil(t, err)
+
+	require.Equal(t, u.String(), logging.RedactURL(u, false, false))
+	require.Equal(t, "https://example.com/REDACTED", logging.RedactURL(u, true, false))
+	require.Equal(t, "https://example.com/private/doc?token=REDACTED&utm_source=REDACTED", logging.RedactURL(u, false, true))
+}
+
+func TestLoggerRedactsURLAttributes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger, err := logging.New(&config.LogConfig{Format: logging.FormatJSON, RedactQuery: true}, buf)
+	require.Nil(t, err)
+
+	u, err := url.Parse("https://example.com/?token=secret")
+	require.Nil(t, err)
+	logger.Info("shortened", slog.Any("url", u))
+
+	line := map[string]any{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "https://example.com/?token=REDACTED", line["url"])
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger, err := logging.New(&config.LogConfig{Format: logging.FormatJSON}, buf)
+	require.Nil(t, err)
+
+	var seen string
+	handler := logging.Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest("GET", "/abc", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-123")
+	respWriter := httptest.NewRecorder()
+	handler.ServeHTTP(respWriter, req)
+
+	require.Equal(t, "req-123", seen)
+	require.Equal(t, "req-123", respWriter.Header().Get(logging.RequestIDHeader))
+
+	line := map[string]any{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "req-123", line["request_id"])
+	require.Equal(t, float64(http.StatusTeapot), line["status"])
+
+	// A request without an ID gets a generated one
+	req = httptest.NewRequest("GET", "/abc", nil)
+	respWriter = httptest.NewRecorder()
+	handler.ServeHTTP(respWriter, req)
+	require.Len(t, respWriter.Header().Get(logging.RequestIDHeader), 32)
+}
@@ -0,0 +1,346 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/egress"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultPollInterval     = time.Minute
+	defaultInterval         = 24 * time.Hour
+	defaultBatchSize        = 200
+	defaultConcurrency      = 8
+	defaultHostDelay        = time.Second
+	defaultTimeout          = 10 * time.Second
+	defaultMaxRedirects     = 10
+	defaultFailureThreshold = 2
+
+	userAgent = "snipr-link-checker"
+	// maxBodySize bounds what is read of GET responses.
+	maxBodySize = 64 << 10
+)
+
+var meter = otel.Meter("github.com/sri-shubham/snipr/internal/prober")
+
+var (
+	// ErrProberStopped is reported by Check once the prober stopped.
+	ErrProberStopped = errors.New("link prober is not running")
+
+	errTooManyRedirects = errors.New("too many redirects")
+	// errHostBusy marks probes that ran out of time waiting for their turn
+	// at a host, which says nothing about the destination.
+	errHostBusy = errors.New("timed out waiting for host")
+)
+
+// Prober periodically checks that link destinations still answer and flags
+// links whose destination keeps failing as broken.
+type Prober struct {
+	storage storage.ProbeStorage
+	conf    config.ProberConfig
+	client  *http.Client
+	hosts   *hostGate
+	logger  *slog.Logger
+	probes  metric.Int64Counter
+
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	running atomic.Bool
+}
+
+func New(storage storage.ProbeStorage, conf *config.ProberConfig, logger *slog.Logger) (*Prober, error) {
+	proberConf := config.ProberConfig{}
+	if conf != nil {
+		proberConf = *conf
+	}
+	if proberConf.PollInterval <= 0 {
+		proberConf.PollInterval = defaultPollInterval
+	}
+	if proberConf.Interval <= 0 {
+		proberConf.Interval = defaultInterval
+	}
+	if proberConf.BatchSize <= 0 {
+		proberConf.BatchSize = defaultBatchSize
+	}
+	if proberConf.Concurrency <= 0 {
+		proberConf.Concurrency = defaultConcurrency
+	}
+	if proberConf.HostDelay <= 0 {
+		proberConf.HostDelay = defaultHostDelay
+	}
+	if proberConf.Timeout <= 0 {
+		proberConf.Timeout = defaultTimeout
+	}
+	if proberConf.MaxRedirects <= 0 {
+		proberConf.MaxRedirects = defaultMaxRedirects
+	}
+	if proberConf.FailureThreshold <= 0 {
+		proberConf.FailureThreshold = defaultFailureThreshold
+	}
+
+	probes, err := meter.Int64Counter("snipr.prober.probes",
+		metric.WithDescription("Link destinations probed by outcome"),
+		metric.WithUnit("{probe}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Prober{
+		storage: storage,
+		conf:    proberConf,
+		client:  egress.NewClient(0, proberConf.AllowPrivateNetworks),
+		hosts:   newHostGate(proberConf.HostDelay),
+		logger:  logger,
+		probes:  probes,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Start launches the probing goroutine.
+func (p *Prober) Start() {
+	p.running.Store(true)
+	go p.run()
+}
+
+// Check reports whether the probing goroutine is running. It can be
+// registered as a health check.
+func (p *Prober) Check(context.Context) error {
+	if !p.running.Load() {
+		return ErrProberStopped
+	}
+	return nil
+}
+
+// Close stops probing after the current batch and waits for the goroutine
+// to finish or ctx to expire.
+func (p *Prober) Close(ctx context.Context) error {
+	p.once.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ProbeDue checks one batch of links due at now and returns how many it
+// checked. Another instance probing is not an error.
+func (p *Prober) ProbeDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := p.storage.ClaimDueLinks(ctx, now, p.conf.BatchSize, p.conf.Interval)
+	if errors.Is(err, util.ErrLocked) {
+		p.logger.DebugContext(ctx, "Another instance is probing links")
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	limit := make(chan struct{}, p.conf.Concurrency)
+	var wg sync.WaitGroup
+	for _, probe := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.hosts.wait(ctx, probe.URL); err != nil {
+				return
+			}
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			p.check(ctx, probe)
+		}()
+	}
+	wg.Wait()
+
+	return len(due), ctx.Err()
+}
+
+// check probes the destination of probe and stores the result.
+func (p *Prober) check(ctx context.Context, probe *models.LinkProbe) {
+	statusCode, redirects, err := p.probe(ctx, probe.URL)
+	if ctx.Err() != nil || errors.Is(err, errHostBusy) {
+		// Shutting down or the host is busy; the link is checked again
+		// next interval
+		return
+	}
+
+	probe.StatusCode = statusCode
+	probe.Redirects = redirects
+	probe.CheckedAt = time.Now().UTC()
+	probe.Error = ""
+	outcome := "ok"
+	if err != nil {
+		probe.Error = err.Error()
+		probe.Failures++
+		outcome = "failed"
+	} else {
+		probe.Failures = 0
+	}
+	probe.Broken = probe.Failures >= p.conf.FailureThreshold
+	p.probes.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+
+	if err := p.storage.StoreProbe(ctx, probe); err != nil {
+		p.logger.ErrorContext(ctx, "Failed to store probe", slog.String("short_url", probe.ShortURL), slog.Any("error", err))
+	}
+}
+
+// Probe requests destination with HEAD, falling back to GET for servers
+// that do not handle HEAD, and returns the final status code and the
+// redirects followed. Statuses of 400 and up are errors. The GET and every
+// redirect wait their turn at the host they go to like the first request.
+func (p *Prober) Probe(ctx context.Context, destination string) (int, []string, error) {
+	if err := p.hosts.wait(ctx, destination); err != nil {
+		return 0, nil, err
+	}
+	return p.probe(ctx, destination)
+}
+
+// probe is Probe for destinations whose host already let it through.
+func (p *Prober) probe(ctx context.Context, destination string) (int, []string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.conf.Timeout)
+	defer cancel()
+
+	statusCode, redirects, err := p.request(ctx, http.MethodHead, destination)
+	if ctx.Err() == nil && !errors.Is(err, errHostBusy) && (err != nil || statusCode >= http.StatusBadRequest) {
+		if err := p.hosts.wait(ctx, destination); err != nil {
+			return 0, nil, fmt.Errorf("%w: %w", errHostBusy, err)
+		}
+		statusCode, redirects, err = p.request(ctx, http.MethodGet, destination)
+	}
+	if err == nil && statusCode >= http.StatusBadRequest {
+		err = fmt.Errorf("destination answered %d %s", statusCode, http.StatusText(statusCode))
+	}
+	return statusCode, redirects, err
+}
+
+func (p *Prober) request(ctx context.Context, method string, destination string) (int, []string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	redirects := []string{}
+	client := *p.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > p.conf.MaxRedirects {
+			return errTooManyRedirects
+		}
+		redirects = append(redirects, req.URL.String())
+		if err := p.hosts.wait(req.Context(), req.URL.String()); err != nil {
+			return fmt.Errorf("%w: %w", errHostBusy, err)
+		}
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, redirects, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+
+	return resp.StatusCode, redirects, nil
+}
+
+func (p *Prober) run() {
+	defer close(p.done)
+	defer p.running.Store(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(p.conf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while batches come back full
+		for ctx.Err() == nil {
+			probed, err := p.ProbeDue(ctx, time.Now())
+			if err != nil && ctx.Err() == nil {
+				p.logger.Error("Failed to probe links", slog.Any("error", err))
+			}
+			if err != nil || probed < p.conf.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// hostGate spaces out requests to the same host.
+type hostGate struct {
+	delay time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newHostGate(delay time.Duration) *hostGate {
+	return &hostGate{
+		delay: delay,
+		next:  map[string]time.Time{},
+	}
+}
+
+// wait blocks until a request to the host of destination is allowed, or
+// ctx is done.
+func (g *hostGate) wait(ctx context.Context, destination string) error {
+	host := destination
+	if u, err := url.Parse(destination); err == nil {
+		host = u.Host
+	}
+
+	g.mu.Lock()
+	now := time.Now()
+	at := g.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	g.next[host] = at.Add(g.delay)
+	for h, next := range g.next {
+		if next.Before(now) {
+			delete(g.next, h)
+		}
+	}
+	g.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
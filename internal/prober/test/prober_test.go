@@ -0,0 +1,215 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/egress"
+	"github.com/sri-shubham/snipr/internal/prober"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/stretchr/testify/require"
+)
+
+func destinations() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestProbe(t *testing.T) {
+	server := destinations()
+	defer server.Close()
+
+	p, err := prober.New(nil, &config.ProberConfig{MaxRedirects: 3, HostDelay: time.Millisecond, AllowPrivateNetworks: true}, slog.Default())
+	require.Nil(t, err)
+
+	statusCode, redirects, err := p.Probe(context.Background(), server.URL+"/ok")
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Empty(t, redirects)
+
+	statusCode, redirects, err = p.Probe(context.Background(), server.URL+"/moved")
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Equal(t, []string{server.URL + "/ok"}, redirects)
+
+	// HEAD is not allowed, GET is
+	statusCode, _, err = p.Probe(context.Background(), server.URL+"/get-only")
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, statusCode)
+
+	statusCode, _, err = p.Probe(context.Background(), server.URL+"/gone")
+	require.NotNil(t, err)
+	require.Equal(t, http.StatusNotFound, statusCode)
+
+	_, redirects, err = p.Probe(context.Background(), server.URL+"/loop")
+	require.NotNil(t, err)
+	require.Len(t, redirects, 3)
+}
+
+func TestProbeDueFlagsBrokenLinks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := destinations()
+	defer server.Close()
+
+	storageMock := storage.NewMockProbeStorage(ctrl)
+	p, err := prober.New(storageMock, &config.ProberConfig{HostDelay: time.Millisecond, AllowPrivateNetworks: true}, slog.Default())
+	require.Nil(t, err)
+
+	now := time.Now()
+	storageMock.EXPECT().ClaimDueLinks(gomock.Any(), now, 200, 24*time.Hour).Return([]*models.LinkProbe{
+		{ShortURL: "https://snipr.com/ok", URL: server.URL + "/ok", Failures: 1},
+		{ShortURL: "https://snipr.com/new", URL: server.URL + "/gone"},
+		{ShortURL: "https://snipr.com/old", URL: server.URL + "/gone", Failures: 1},
+	}, nil)
+
+	var mu sync.Mutex
+	stored := map[string]*models.LinkProbe{}
+	storageMock.EXPECT().StoreProbe(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, probe *models.LinkProbe) error {
+		mu.Lock()
+		defer mu.Unlock()
+		stored[probe.ShortURL] = probe
+		return nil
+	}).Times(3)
+
+	probed, err := p.ProbeDue(context.Background(), now)
+	require.Nil(t, err)
+	require.Equal(t, 3, probed)
+
+	require.Equal(t, 0, stored["https://snipr.com/ok"].Failures)
+	require.False(t, stored["https://snipr.com/ok"].Broken)
+	require.False(t, stored["https://snipr.com/ok"].CheckedAt.IsZero())
+
+	// One failure is not enough to flag a link
+	require.Equal(t, 1, stored["https://snipr.com/new"].Failures)
+	require.False(t, stored["https://snipr.com/new"].Broken)
+	require.Equal(t, http.StatusNotFound, stored["https://snipr.com/new"].StatusCode)
+	require.NotEmpty(t, stored["https://snipr.com/new"].Error)
+
+	require.Equal(t, 2, stored["https://snipr.com/old"].Failures)
+	require.True(t, stored["https://snipr.com/old"].Broken)
+}
+
+func TestProbeDueIsPoliteToHosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, time.Now())
+	}))
+	defer server.Close()
+
+	storageMock := storage.NewMockProbeStorage(ctrl)
+	p, err := prober.New(storageMock, &config.ProberConfig{HostDelay: 50 * time.Millisecond, Concurrency: 3, AllowPrivateNetworks: true}, slog.Default())
+	require.Nil(t, err)
+
+	storageMock.EXPECT().ClaimDueLinks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]*models.LinkProbe{
+		{ShortURL: "https://snipr.com/a", URL: server.URL + "/a"},
+		{ShortURL: "https://snipr.com/b", URL: server.URL + "/b"},
+		{ShortURL: "https://snipr.com/c", URL: server.URL + "/c"},
+	}, nil)
+	storageMock.EXPECT().StoreProbe(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+
+	_, err = p.ProbeDue(context.Background(), time.Now())
+	require.Nil(t, err)
+
+	require.Len(t, requests, 3)
+	first, last := requests[0], requests[0]
+	for _, at := range requests {
+		if at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	require.GreaterOrEqual(t, last.Sub(first), 90*time.Millisecond)
+}
+
+func TestProbeIsPoliteOnRedirects(t *testing.T) {
+	var mu sync.Mutex
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		mu.Unlock()
+		if r.URL.Path != "/c" {
+			http.Redirect(w, r, map[string]string{"/a": "/b", "/b": "/c"}[r.URL.Path], http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	p, err := prober.New(nil, &config.ProberConfig{HostDelay: 50 * time.Millisecond, AllowPrivateNetworks: true}, slog.Default())
+	require.Nil(t, err)
+
+	_, redirects, err := p.Probe(context.Background(), server.URL+"/a")
+	require.Nil(t, err)
+	require.Len(t, redirects, 2)
+
+	require.Len(t, requests, 3)
+	for i := 1; i < len(requests); i++ {
+		require.GreaterOrEqual(t, requests[i].Sub(requests[i-1]), 45*time.Millisecond)
+	}
+}
+
+func TestProbeRefusesInternalAddresses(t *testing.T) {
+	server := destinations()
+	defer server.Close()
+
+	p, err := prober.New(nil, &config.ProberConfig{HostDelay: time.Millisecond}, slog.Default())
+	require.Nil(t, err)
+
+	_, _, err = p.Probe(context.Background(), server.URL+"/ok")
+	require.True(t, errors.Is(err, egress.ErrForbidden), err)
+}
+
+func TestCheck(t *testing.T) {
+	p, err := prober.New(nil, &config.ProberConfig{PollInterval: time.Hour}, slog.Default())
+	require.Nil(t, err)
+	require.ErrorIs(t, p.Check(context.Background()), prober.ErrProberStopped)
+}
+
+func TestProbeDueWhenLocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockProbeStorage(ctrl)
+	storageMock.EXPECT().ClaimDueLinks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, util.ErrLocked)
+
+	p, err := prober.New(storageMock, nil, slog.Default())
+	require.Nil(t, err)
+
+	probed, err := p.ProbeDue(context.Background(), time.Now())
+	require.Nil(t, err)
+	require.Equal(t, 0, probed)
+}
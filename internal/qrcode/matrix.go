@@ -0,0 +1,267 @@
+package qrcode
+
+// matrix is a code being drawn. Function modules, the finder, timing and
+// alignment patterns and the format and version information, are marked so
+// codewords and masks leave them alone.
+type matrix struct {
+	*Code
+	function []bool
+}
+
+// newMatrix draws the function patterns of version.
+func newMatrix(version int, level Level) *matrix {
+	size := version*4 + 17
+	m := &matrix{
+		Code: &Code{
+			Size:    size,
+			Version: version,
+			Level:   level,
+			modules: make([]bool, size*size),
+		},
+		function: make([]bool, size*size),
+	}
+
+	for i := range size {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(size-4, 3)
+	m.drawFinder(3, size-4)
+
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// The corners hold finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			m.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format information; it is drawn once the mask is known
+	m.drawFormat(0)
+	m.drawVersion()
+	return m
+}
+
+func (m *matrix) setFunction(x, y int, black bool) {
+	m.modules[y*m.Size+x] = black
+	m.function[y*m.Size+x] = true
+}
+
+// drawFinder draws a finder pattern centred on x, y with its separator.
+func (m *matrix) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= m.Size || yy >= m.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			m.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignment draws an alignment pattern centred on x, y.
+func (m *matrix) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information for mask, and the
+// dark module next to the lower one.
+func (m *matrix) drawFormat(mask int) {
+	data := m.Level.formatBits()<<3 | mask
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		m.setFunction(8, i, bit(i))
+	}
+	m.setFunction(8, 7, bit(6))
+	m.setFunction(8, 8, bit(7))
+	m.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.setFunction(m.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.setFunction(8, m.Size-15+i, bit(i))
+	}
+	m.setFunction(8, m.Size-8, true)
+}
+
+// drawVersion draws both copies of the version information, which codes
+// from version 7 on carry.
+func (m *matrix) drawVersion() {
+	if m.Version < 7 {
+		return
+	}
+	rem := m.Version
+	for range 12 {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := m.Version<<12 | rem
+
+	for i := range 18 {
+		black := bits>>i&1 == 1
+		a, b := m.Size-11+i%3, i/3
+		m.setFunction(a, b, black)
+		m.setFunction(b, a, black)
+	}
+}
+
+// drawCodewords places codewords in the zigzag of two module wide columns,
+// from the bottom right corner up and down, skipping function modules.
+func (m *matrix) drawCodewords(codewords []byte) {
+	i := 0
+	for right := m.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// The vertical timing pattern
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := range m.Size {
+			y := vert
+			if upward {
+				y = m.Size - 1 - vert
+			}
+			for j := range 2 {
+				x := right - j
+				if m.function[y*m.Size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				m.modules[y*m.Size+x] = codewords[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by mask.
+func (m *matrix) applyMask(mask int) {
+	for y := range m.Size {
+		for x := range m.Size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !m.function[y*m.Size+x] {
+				m.modules[y*m.Size+x] = !m.modules[y*m.Size+x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to read: long runs of one colour,
+// 2x2 blocks, patterns resembling finders and an uneven share of dark
+// modules all add to it.
+func (m *matrix) penalty() int {
+	penalty := 0
+	for i := range m.Size {
+		row := func(j int) bool { return m.Black(j, i) }
+		column := func(j int) bool { return m.Black(i, j) }
+		penalty += m.linePenalty(row) + m.linePenalty(column)
+	}
+
+	dark := 0
+	for y := range m.Size {
+		for x := range m.Size {
+			black := m.Black(x, y)
+			if black {
+				dark++
+			}
+			if x+1 < m.Size && y+1 < m.Size &&
+				black == m.Black(x+1, y) && black == m.Black(x, y+1) && black == m.Black(x+1, y+1) {
+				penalty += 3
+			}
+		}
+	}
+
+	// 10 for every 5% the dark share is off from half
+	total := m.Size * m.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return penalty + max(k, 0)*10
+}
+
+// finderLike is the 1:1:3:1:1 dark and light pattern of finders.
+var finderLike = []bool{true, false, true, true, true, false, true}
+
+// linePenalty scores the runs and finder like patterns of one row or
+// column, read through at.
+func (m *matrix) linePenalty(at func(int) bool) int {
+	penalty := 0
+	run := 1
+	for j := 1; j <= m.Size; j++ {
+		if j < m.Size && at(j) == at(j-1) {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += 3 + run - 5
+		}
+		run = 1
+	}
+
+	for j := 0; j+len(finderLike) <= m.Size; j++ {
+		matches := true
+		for k, black := range finderLike {
+			if at(j+k) != black {
+				matches = false
+				break
+			}
+		}
+		if matches && (m.light(at, j-4, j) || m.light(at, j+len(finderLike), j+len(finderLike)+4)) {
+			penalty += 40
+		}
+	}
+	return penalty
+}
+
+// light reports whether the modules from up to before to are light; those
+// outside the code count as light.
+func (m *matrix) light(at func(int) bool, from, to int) bool {
+	for j := from; j < to; j++ {
+		if j >= 0 && j < m.Size && at(j) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
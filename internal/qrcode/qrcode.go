@@ -0,0 +1,228 @@
+// Package qrcode encodes text as QR codes (ISO/IEC 18004, model 2) in byte
+// mode and renders them as PNG or SVG.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is how much of a code can be damaged and still be read.
+type Level int
+
+const (
+	// Low recovers about 7% of the code.
+	Low Level = iota
+	// Medium recovers about 15% of the code.
+	Medium
+	// Quartile recovers about 25% of the code.
+	Quartile
+	// High recovers about 30% of the code.
+	High
+)
+
+const (
+	minVersion = 1
+	maxVersion = 40
+
+	modeByte = 0b0100
+)
+
+var ErrTooLong = errors.New("text too long for a QR code")
+
+var ErrInvalidLevel = errors.New("invalid error correction level")
+
+// ParseLevel parses one of L, M, Q and H.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLevel, s)
+	}
+}
+
+// formatBits are the bits identifying the level in the format information.
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+// Code is an encoded QR code, without its quiet zone.
+type Code struct {
+	// Size is the number of modules along a side.
+	Size    int
+	Version int
+	Level   Level
+	Mask    int
+
+	modules []bool
+}
+
+// Black reports whether the module in column x and row y is dark. Modules
+// outside the code are light.
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// Encode encodes text at level in the smallest version it fits, picking
+// the mask that leaves the fewest patterns hard to read.
+func Encode(text string, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, ErrInvalidLevel
+	}
+
+	data := []byte(text)
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		if 4+countBits(version)+8*len(data) <= 8*dataCodewords(version, level) {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	codewords := interleave(version, level, encodeData(data, version, level))
+
+	best := (*Code)(nil)
+	bestPenalty := 0
+	for mask := range 8 {
+		code := newMatrix(version, level)
+		code.drawCodewords(codewords)
+		code.applyMask(mask)
+		code.drawFormat(mask)
+		if penalty := code.penalty(); best == nil || penalty < bestPenalty {
+			best, bestPenalty = code.Code, penalty
+			best.Mask = mask
+		}
+	}
+	return best, nil
+}
+
+// countBits is the length of the character count in byte mode.
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// encodeData returns the data codewords of data: the mode, count, data and
+// terminator, padded to the capacity of version.
+func encodeData(data []byte, version int, level Level) []byte {
+	capacity := dataCodewords(version, level)
+	bits := &bitBuffer{}
+	bits.append(modeByte, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, 8*capacity-bits.len))
+	bits.append(0, (8-bits.len%8)%8)
+
+	out := bits.bytes
+	for pad := byte(0xEC); len(out) < capacity; pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// interleave splits data into the blocks of version at level, adds their
+// error correction and interleaves the lot.
+func interleave(version int, level Level, data []byte) []byte {
+	numBlocks := errorCorrectionBlocks[level][version]
+	eccLen := errorCorrectionCodewords[level][version]
+	raw := rawDataModules(version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, 0, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		dataLen := shortLen - eccLen
+		if i >= numShort {
+			dataLen++
+		}
+		block := append([]byte{}, data[k:k+dataLen]...)
+		k += dataLen
+		ecc := rsRemainder(block, divisor)
+		if i < numShort {
+			// Short blocks get a placeholder so all blocks line up
+			block = append(block, 0)
+		}
+		blocks = append(blocks, append(block, ecc...))
+	}
+
+	out := make([]byte, 0, raw)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				out = append(out, block[i])
+			}
+		}
+	}
+	return out
+}
+
+// dataCodewords is the number of data codewords version holds at level.
+func dataCodewords(version int, level Level) int {
+	return rawDataModules(version)/8 - errorCorrectionCodewords[level][version]*errorCorrectionBlocks[level][version]
+}
+
+// rawDataModules is the number of modules of version left for codewords
+// and remainder bits once function patterns are drawn.
+func rawDataModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		alignments := version/7 + 2
+		n -= (25*alignments-10)*alignments - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+// alignmentPositions are the row and column centres of the alignment
+// patterns of version.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*8 + count*3 + 5) / (count*4 - 4) * 2
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+type bitBuffer struct {
+	bytes []byte
+	len   int
+}
+
+// append adds the n low bits of value, most significant first.
+func (b *bitBuffer) append(value int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if b.len%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if value>>i&1 == 1 {
+			b.bytes[b.len/8] |= 0x80 >> (b.len % 8)
+		}
+		b.len++
+	}
+}
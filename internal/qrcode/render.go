@@ -0,0 +1,185 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// logoShare is the largest part of a code's width a logo covers. Codes
+// with logos need a level high enough to recover what the logo hides.
+const logoShare = 5
+
+// Options control how a code is rendered.
+type Options struct {
+	// Size is the width and height of the image in pixels. PNG codes are
+	// scaled by whole pixels per module and centred, and are never smaller
+	// than one pixel per module.
+	Size int
+	// Margin is the quiet zone around the code, in modules.
+	Margin     int
+	Foreground color.Color
+	Background color.Color
+	// Logo is centred on the code, on a patch of background, if set.
+	Logo image.Image
+}
+
+func (o Options) colors() (color.Color, color.Color) {
+	foreground, background := o.Foreground, o.Background
+	if foreground == nil {
+		foreground = color.Black
+	}
+	if background == nil {
+		background = color.White
+	}
+	return foreground, background
+}
+
+// PNG writes the code as a PNG image.
+func (c *Code) PNG(w io.Writer, opts Options) error {
+	foreground, background := opts.colors()
+	margin := max(opts.Margin, 0)
+	total := c.Size + 2*margin
+	scale := max(1, opts.Size/total)
+	side := max(opts.Size, total*scale)
+	origin := (side-total*scale)/2 + margin*scale
+
+	bounds := image.Rect(0, 0, side, side)
+	var img draw.Image
+	if opts.Logo == nil {
+		// Two colours keep the file small
+		img = image.NewPaletted(bounds, color.Palette{background, foreground})
+	} else {
+		img = image.NewNRGBA(bounds)
+		draw.Draw(img, bounds, image.NewUniform(background), image.Point{}, draw.Src)
+	}
+
+	dark := image.NewUniform(foreground)
+	for y := range c.Size {
+		for x := range c.Size {
+			if c.Black(x, y) {
+				module := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale).Add(image.Pt(origin, origin))
+				draw.Draw(img, module, dark, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if opts.Logo != nil {
+		width, height := fit(opts.Logo.Bounds(), c.Size*scale/logoShare)
+		if width > 0 && height > 0 {
+			center := side / 2
+			logo := image.Rect(center-width/2, center-height/2, center-width/2+width, center-height/2+height)
+			draw.Draw(img, logo.Inset(-scale), image.NewUniform(background), image.Point{}, draw.Src)
+			draw.Draw(img, logo, resize(opts.Logo, width, height), image.Point{}, draw.Over)
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// SVG writes the code as an SVG image drawn in modules, so it scales to any
+// size.
+func (c *Code) SVG(w io.Writer, opts Options) error {
+	foreground, background := opts.colors()
+	margin := max(opts.Margin, 0)
+	total := c.Size + 2*margin
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d"`, total, total)
+	if opts.Size > 0 {
+		fmt.Fprintf(&buf, ` width="%d" height="%d"`, opts.Size, opts.Size)
+	}
+	buf.WriteString(` shape-rendering="crispEdges">` + "\n")
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d"%s/>`+"\n", total, total, svgFill(background))
+
+	// One path of horizontal runs of dark modules
+	fmt.Fprintf(&buf, `<path%s d="`, svgFill(foreground))
+	for y := range c.Size {
+		for x := 0; x < c.Size; x++ {
+			if !c.Black(x, y) {
+				continue
+			}
+			run := 1
+			for c.Black(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+margin, y+margin, run, run)
+			x += run
+		}
+	}
+	buf.WriteString(`"/>` + "\n")
+
+	if opts.Logo != nil {
+		// Logos are embedded at 16 pixels per module
+		const pixels = 16
+		width, height := fit(opts.Logo.Bounds(), c.Size*pixels/logoShare)
+		if width > 0 && height > 0 {
+			var logo bytes.Buffer
+			if err := png.Encode(&logo, resize(opts.Logo, width, height)); err != nil {
+				return err
+			}
+			center := float64(total) / 2
+			w, h := float64(width)/pixels, float64(height)/pixels
+			fmt.Fprintf(&buf, `<rect x="%g" y="%g" width="%g" height="%g"%s/>`+"\n", center-w/2-1, center-h/2-1, w+2, h+2, svgFill(background))
+			fmt.Fprintf(&buf, `<image x="%g" y="%g" width="%g" height="%g" href="data:image/png;base64,%s"/>`+"\n", center-w/2, center-h/2, w, h, base64.StdEncoding.EncodeToString(logo.Bytes()))
+		}
+	}
+	buf.WriteString("</svg>\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// svgFill returns the fill attributes painting in c.
+func svgFill(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	fill := fmt.Sprintf(` fill="#%02x%02x%02x"`, n.R, n.G, n.B)
+	if n.A != 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%.3g"`, float64(n.A)/0xff)
+	}
+	return fill
+}
+
+// fit returns the size of bounds scaled to fit a square of side box,
+// keeping its aspect ratio.
+func fit(bounds image.Rectangle, box int) (int, int) {
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	if width >= height {
+		return box, height * box / width
+	}
+	return width * box / height, box
+}
+
+// resize scales src to width by height, averaging the source pixels each
+// pixel covers.
+func resize(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := range width {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
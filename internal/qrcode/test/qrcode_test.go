@@ -0,0 +1,186 @@
+package test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/qrcode"
+	"github.com/stretchr/testify/require"
+)
+
+// golden is https://snipr.com/abc123 at level M as encoded by rsc.io/qr
+// with the same version and mask.
+var golden = []string{
+	"#######.#.#.#.#.#.#######",
+	"#.....#..#.####.#.#.....#",
+	"#.###.#.#.#.##.#..#.###.#",
+	"#.###.#..#.#####..#.###.#",
+	"#.###.#..#...##...#.###.#",
+	"#.....#.#..#....#.#.....#",
+	"#######.#.#.#.#.#.#######",
+	".........#.##.###........",
+	"#.#...##.###....#..#..#.#",
+	"####...#.#.##..##.##.#.##",
+	".#..#.######..##.....##.#",
+	".#...#.#...#...#..#..#...",
+	"##..###........##.#.....#",
+	".#..#..#..#....##.##...##",
+	"###.#.##.###..####...##.#",
+	"..##....##....####.###...",
+	"###.####.#.##.#.#####..#.",
+	"........###.#.#.#...#...#",
+	"#######.#.#.###.#.#.#...#",
+	"#.....#..####.#.#...#....",
+	"#.###.#..##.#.#######..#.",
+	"#.###.#..#....#.##..#.##.",
+	"#.###.#.####..###..###.##",
+	"#.....#....#...##..##....",
+	"#######.####..#.###..#..#",
+}
+
+func rows(code *qrcode.Code) []string {
+	out := make([]string, 0, code.Size)
+	for y := range code.Size {
+		var row strings.Builder
+		for x := range code.Size {
+			if code.Black(x, y) {
+				row.WriteByte('#')
+			} else {
+				row.WriteByte('.')
+			}
+		}
+		out = append(out, row.String())
+	}
+	return out
+}
+
+func TestEncode(t *testing.T) {
+	code, err := qrcode.Encode("https://snipr.com/abc123", qrcode.Medium)
+	require.Nil(t, err)
+	require.Equal(t, 2, code.Version)
+	require.Equal(t, 25, code.Size)
+	require.Equal(t, 1, code.Mask)
+	require.Equal(t, golden, rows(code))
+	require.False(t, code.Black(-1, 0))
+	require.False(t, code.Black(0, code.Size))
+}
+
+func TestEncodeVersions(t *testing.T) {
+	// Version 1 holds 17 bytes at L, 2 holds 14 at H
+	code, err := qrcode.Encode(strings.Repeat("a", 17), qrcode.Low)
+	require.Nil(t, err)
+	require.Equal(t, 1, code.Version)
+
+	code, err = qrcode.Encode(strings.Repeat("a", 17), qrcode.High)
+	require.Nil(t, err)
+	require.Equal(t, 3, code.Version)
+
+	// Version 7 and up carry version information
+	code, err = qrcode.Encode(strings.Repeat("a", 200), qrcode.Medium)
+	require.Nil(t, err)
+	require.Equal(t, 10, code.Version)
+	require.Equal(t, 57, code.Size)
+
+	code, err = qrcode.Encode(strings.Repeat("a", 2953), qrcode.Low)
+	require.Nil(t, err)
+	require.Equal(t, 40, code.Version)
+
+	_, err = qrcode.Encode(strings.Repeat("a", 2954), qrcode.Low)
+	require.ErrorIs(t, err, qrcode.ErrTooLong)
+	_, err = qrcode.Encode(strings.Repeat("a", 1274), qrcode.High)
+	require.ErrorIs(t, err, qrcode.ErrTooLong)
+}
+
+func TestParseLevel(t *testing.T) {
+	for text, want := range map[string]qrcode.Level{"L": qrcode.Low, "m": qrcode.Medium, "Q": qrcode.Quartile, "h": qrcode.High} {
+		level, err := qrcode.ParseLevel(text)
+		require.Nil(t, err)
+		require.Equal(t, want, level)
+	}
+
+	_, err := qrcode.ParseLevel("X")
+	require.ErrorIs(t, err, qrcode.ErrInvalidLevel)
+}
+
+func TestPNG(t *testing.T) {
+	code, err := qrcode.Encode("https://snipr.com/abc123", qrcode.Medium)
+	require.Nil(t, err)
+
+	red := color.NRGBA{R: 0xcc, A: 0xff}
+	var buf bytes.Buffer
+	require.Nil(t, code.PNG(&buf, qrcode.Options{Size: 200, Margin: 2, Foreground: red}))
+
+	img, err := png.Decode(&buf)
+	require.Nil(t, err)
+	require.Equal(t, image.Rect(0, 0, 200, 200), img.Bounds())
+
+	// 29 modules of 6 pixels, centred in 200
+	origin := (200-29*6)/2 + 2*6
+	for y := range code.Size {
+		for x := range code.Size {
+			want := color.Color(color.White)
+			if code.Black(x, y) {
+				want = red
+			}
+			require.Equal(t, color.NRGBAModel.Convert(want), color.NRGBAModel.Convert(img.At(origin+x*6+3, origin+y*6+3)), "module %d,%d", x, y)
+		}
+	}
+	require.Equal(t, color.NRGBAModel.Convert(color.White), color.NRGBAModel.Convert(img.At(1, 1)))
+}
+
+func TestPNGIsNeverSmallerThanTheCode(t *testing.T) {
+	code, err := qrcode.Encode("https://snipr.com/abc123", qrcode.Medium)
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	require.Nil(t, code.PNG(&buf, qrcode.Options{Size: 10, Margin: 4}))
+	img, err := png.Decode(&buf)
+	require.Nil(t, err)
+	require.Equal(t, 33, img.Bounds().Dx())
+}
+
+func TestPNGLogo(t *testing.T) {
+	code, err := qrcode.Encode("https://snipr.com/abc123", qrcode.High)
+	require.Nil(t, err)
+
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	logo := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for y := range 32 {
+		for x := range 64 {
+			logo.SetNRGBA(x, y, blue)
+		}
+	}
+
+	var buf bytes.Buffer
+	require.Nil(t, code.PNG(&buf, qrcode.Options{Size: 400, Margin: 4, Logo: logo}))
+	img, err := png.Decode(&buf)
+	require.Nil(t, err)
+	require.Equal(t, blue, color.NRGBAModel.Convert(img.At(200, 200)))
+	require.NotEqual(t, blue, color.NRGBAModel.Convert(img.At(200, 120)))
+}
+
+func TestSVG(t *testing.T) {
+	code, err := qrcode.Encode("https://snipr.com/abc123", qrcode.Medium)
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	require.Nil(t, code.SVG(&buf, qrcode.Options{
+		Size:       300,
+		Margin:     4,
+		Foreground: color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff},
+		Background: color.NRGBA{A: 0},
+	}))
+	svg := buf.String()
+	require.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 33 33" width="300" height="300"`))
+	require.Contains(t, svg, `<rect width="33" height="33" fill="#000000" fill-opacity="0"/>`)
+	require.Contains(t, svg, `<path fill="#112233" d="M4 4h7v1h-7z`)
+	require.NotContains(t, svg, "<image")
+
+	buf.Reset()
+	require.Nil(t, code.SVG(&buf, qrcode.Options{Margin: 4, Logo: image.NewNRGBA(image.Rect(0, 0, 10, 10))}))
+	require.Contains(t, buf.String(), `<image x="14" y="14" width="5" height="5" href="data:image/png;base64,`)
+}
@@ -0,0 +1,141 @@
+package redirect
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+// botTokens are lower case user agent fragments sent by crawlers and link
+// preview fetchers.
+var botTokens = []string{
+	"bot",
+	"crawler",
+	"spider",
+	"slurp",
+	"facebookexternalhit",
+	"embedly",
+	"whatsapp",
+	"skypeuripreview",
+	"bitlybot",
+	"curl/",
+	"wget/",
+	"python-requests",
+	"go-http-client",
+}
+
+// previewTokens are lower case user agent fragments sent by fetchers that
+// render link previews on social platforms and in chat apps.
+var previewTokens = []string{
+	"facebookexternalhit",
+	"facebot",
+	"twitterbot",
+	"slackbot",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"embedly",
+	"pinterest",
+	"redditbot",
+	"mastodon",
+	"iframely",
+}
+
+// Platform classifies a user agent as ios, android or desktop. Empty user
+// agents are not classified.
+func Platform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return ""
+	case strings.Contains(ua, "android"):
+		return models.PlatformAndroid
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return models.PlatformIOS
+	default:
+		return models.PlatformDesktop
+	}
+}
+
+// IsBot reports whether the user agent belongs to a crawler.
+func IsBot(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, token := range botTokens {
+		if strings.Contains(ua, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPreviewBot reports whether the user agent belongs to a link preview
+// fetcher.
+func IsPreviewBot(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, token := range previewTokens {
+		if strings.Contains(ua, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// PreferredLanguage returns the Accept-Language tag with the highest
+// quality, the first one listed on ties.
+func PreferredLanguage(acceptLanguage string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" || name == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			tags = append(tags, tag{name: name, q: q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	if len(tags) == 0 {
+		return ""
+	}
+	return tags[0].name
+}
+
+// matches reports whether visit satisfies every condition of rule.
+func matches(rule *models.TargetRule, visit *Visit) bool {
+	if rule.Platform != "" && rule.Platform != visit.Platform {
+		return false
+	}
+	if rule.Bot && !visit.Bot {
+		return false
+	}
+	if rule.Language != "" && !languageMatches(rule.Language, visit.Language) {
+		return false
+	}
+	return true
+}
+
+func languageMatches(want string, got string) bool {
+	if strings.EqualFold(want, got) {
+		return true
+	}
+	primary, _, _ := strings.Cut(got, "-")
+	return !strings.Contains(want, "-") && strings.EqualFold(want, primary)
+}
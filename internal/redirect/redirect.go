@@ -0,0 +1,229 @@
+package redirect
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/clientip"
+	"github.com/sri-shubham/snipr/internal/geoip"
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+// Visit describes the request being redirected, reduced to what link
+// settings can act on.
+type Visit struct {
+	Code string
+	// Query is the query string sent with the short url.
+	Query url.Values
+	// Path holds any path segments following the code, without a leading
+	// slash.
+	Path      string
+	Referrer  string
+	UserAgent string
+	// Platform, Language and Bot classify the visitor for TargetRules.
+	Platform string
+	Language string
+	Bot      bool
+	// IP and Country are only set by a Classifier.
+	IP      netip.Addr
+	Country string
+	// Variant holds the A/B variant the visitor was assigned earlier and,
+	// after Destination, the variant they are sent to.
+	Variant string
+	Time    time.Time
+}
+
+// NewVisit builds a Visit from r. extraPath is the part of the request path
+// following the code.
+func NewVisit(r *http.Request, code string, extraPath string) *Visit {
+	visit := &Visit{
+		Code:      code,
+		Query:     r.URL.Query(),
+		Path:      strings.Trim(extraPath, "/"),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		Platform:  Platform(r.UserAgent()),
+		Language:  PreferredLanguage(r.Header.Get("Accept-Language")),
+		Bot:       IsBot(r.UserAgent()),
+		Time:      time.Now(),
+	}
+	if cookie, err := r.Cookie(VariantCookie(code)); err == nil {
+		visit.Variant = cookie.Value
+	}
+	return visit
+}
+
+// Classifier builds visits that also carry the client address and its
+// country, both of which depend on deployment configuration.
+type Classifier struct {
+	clientIP *clientip.Resolver
+	geo      geoip.Resolver
+}
+
+// NewClassifier returns a Classifier. geo may be nil when no GeoIP database
+// is configured.
+func NewClassifier(clientIP *clientip.Resolver, geo geoip.Resolver) *Classifier {
+	return &Classifier{
+		clientIP: clientIP,
+		geo:      geo,
+	}
+}
+
+// NewVisit is NewVisit with the client address and country resolved. A nil
+// Classifier only resolves the peer address.
+func (c *Classifier) NewVisit(r *http.Request, code string, extraPath string) *Visit {
+	if c == nil {
+		c = &Classifier{}
+	}
+
+	visit := NewVisit(r, code, extraPath)
+	visit.IP = c.ClientIP(r)
+	if c.geo != nil {
+		visit.Country = c.geo.Country(visit.IP)
+	}
+	return visit
+}
+
+// ClientIP resolves the client address of r the same way NewVisit does.
+func (c *Classifier) ClientIP(r *http.Request) netip.Addr {
+	if c == nil {
+		return (&Classifier{}).ClientIP(r)
+	}
+	return c.clientIP.IP(r)
+}
+
+// Secure reports whether the client of r reached us over HTTPS.
+func (c *Classifier) Secure(r *http.Request) bool {
+	if c == nil {
+		return r.TLS != nil
+	}
+	return c.clientIP.Secure(r)
+}
+
+// Click summarises visit to shortURL for analytics.
+func (v *Visit) Click(shortURL string) *models.Click {
+	click := &models.Click{
+		ShortURL: shortURL,
+		Time:     v.Time,
+		Country:  v.Country,
+		Platform: v.Platform,
+		Bot:      v.Bot,
+		Variant:  v.Variant,
+	}
+	if ref, err := url.Parse(v.Referrer); err == nil {
+		click.ReferrerHost = ref.Hostname()
+	}
+	return click
+}
+
+// Destination computes where visit should be sent for link. The link itself
+// is left untouched.
+func Destination(link *models.ShortenedURL, visit *Visit) *url.URL {
+	dest := *Target(link, visit)
+
+	if len(link.ParamTemplate) > 0 {
+		query := dest.Query()
+		for key, template := range link.ParamTemplate {
+			if value := expand(template, visit); value != "" {
+				query.Set(key, value)
+			}
+		}
+		dest.RawQuery = query.Encode()
+	}
+
+	if pt := link.Passthrough; pt != nil {
+		if pt.Path && visit.Path != "" {
+			dest = *dest.JoinPath(confine(visit.Path))
+		}
+		if pt.Query && len(visit.Query) > 0 {
+			dest.RawQuery = mergeQuery(dest.Query(), visit.Query, pt.QueryConflict).Encode()
+		}
+	}
+
+	return &dest
+}
+
+// confine resolves dot segments of a passed through path as if it were
+// rooted, so it can not climb out of the destination path it is appended to.
+func confine(p string) string {
+	return path.Clean("/" + p)
+}
+
+// Target returns the destination of the first rule of link matching visit,
+// then the destination for the visitor's country, then that of the A/B
+// variant chosen for the visitor and otherwise the link's url. visit.Variant
+// is left empty unless a variant is used.
+func Target(link *models.ShortenedURL, visit *Visit) *url.URL {
+	assigned := visit.Variant
+	visit.Variant = ""
+
+	for i := range link.Rules {
+		if !matches(&link.Rules[i], visit) {
+			continue
+		}
+		target, err := url.Parse(link.Rules[i].URL)
+		if err == nil {
+			return target
+		}
+	}
+
+	if dest, ok := link.Geo[visit.Country]; ok && visit.Country != "" {
+		target, err := url.Parse(dest)
+		if err == nil {
+			return target
+		}
+	}
+
+	visit.Variant = assigned
+	if target := variantTarget(link, visit); target != nil {
+		return target
+	}
+	visit.Variant = ""
+
+	return link.URL
+}
+
+// expand replaces the placeholders in template with values from visit.
+func expand(template string, visit *Visit) string {
+	return models.ParamPlaceholderRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return visit.placeholder(strings.Trim(placeholder, "{}"))
+	})
+}
+
+func (v *Visit) placeholder(name string) string {
+	switch name {
+	case "referrer":
+		return v.Referrer
+	case "referrer_host":
+		if ref, err := url.Parse(v.Referrer); err == nil {
+			return ref.Hostname()
+		}
+		return ""
+	case "date":
+		return v.Time.UTC().Format(time.DateOnly)
+	case "code":
+		return v.Code
+	default:
+		return ""
+	}
+}
+
+func mergeQuery(dest url.Values, incoming url.Values, conflict string) url.Values {
+	for key, values := range incoming {
+		_, exists := dest[key]
+		switch {
+		case !exists:
+			dest[key] = values
+		case conflict == models.QueryConflictDestination:
+		case conflict == models.QueryConflictAppend:
+			dest[key] = append(dest[key], values...)
+		default:
+			dest[key] = values
+		}
+	}
+	return dest
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/redirect"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+	desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	botUA     = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestPlatform(t *testing.T) {
+	require.Equal(t, models.PlatformIOS, redirect.Platform(iPhoneUA))
+	require.Equal(t, models.PlatformAndroid, redirect.Platform(androidUA))
+	require.Equal(t, models.PlatformDesktop, redirect.Platform(desktopUA))
+	require.Equal(t, "", redirect.Platform(""))
+
+	require.True(t, redirect.IsBot(botUA))
+	require.True(t, redirect.IsBot("facebookexternalhit/1.1"))
+	require.False(t, redirect.IsBot(iPhoneUA))
+
+	require.True(t, redirect.IsPreviewBot("facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"))
+	require.True(t, redirect.IsPreviewBot("Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"))
+	require.False(t, redirect.IsPreviewBot(botUA))
+	require.False(t, redirect.IsPreviewBot("curl/8.5.0"))
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	require.Equal(t, "de-CH", redirect.PreferredLanguage("de-CH"))
+	require.Equal(t, "fr", redirect.PreferredLanguage("en;q=0.8, fr, de;q=0.9"))
+	require.Equal(t, "en", redirect.PreferredLanguage("*, en;q=0.5, es;q=0"))
+	require.Equal(t, "", redirect.PreferredLanguage(""))
+}
+
+func TestDestinationRules(t *testing.T) {
+	l := link(t, "https://example.com/app?ref=short", models.LinkSettings{
+		Rules: []models.TargetRule{
+			{Bot: true, URL: "https://example.com/preview"},
+			{Platform: models.PlatformIOS, URL: "https://apps.apple.com/app/id123"},
+			{Platform: models.PlatformAndroid, Language: "de", URL: "https://play.google.com/store/apps/details?id=app&hl=de"},
+			{Platform: models.PlatformAndroid, URL: "https://play.google.com/store/apps/details?id=app"},
+		},
+	})
+
+	tests := []struct {
+		userAgent string
+		language  string
+		expected  string
+	}{
+		{iPhoneUA, "", "https://apps.apple.com/app/id123"},
+		{androidUA, "de-AT,en;q=0.5", "https://play.google.com/store/apps/details?id=app&hl=de"},
+		{androidUA, "en-US", "https://play.google.com/store/apps/details?id=app"},
+		{desktopUA, "de", "https://example.com/app?ref=short"},
+		{botUA, "", "https://example.com/preview"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/abc", nil)
+		req.Header.Set("User-Agent", test.userAgent)
+		req.Header.Set("Accept-Language", test.language)
+
+		dest := redirect.Destination(l, redirect.NewVisit(req, "abc", ""))
+		require.Equal(t, test.expected, dest.String(), test.userAgent)
+	}
+}
+
+func TestRuleValidation(t *testing.T) {
+	invalid := []models.TargetRule{
+		{URL: "https://example.com"},
+		{Platform: "windows", URL: "https://example.com"},
+		{Language: "en_US", URL: "https://example.com"},
+		{Platform: models.PlatformIOS, URL: "/relative"},
+		{Platform: models.PlatformAndroid, URL: "market://details?id=app"},
+	}
+	for _, rule := range invalid {
+		settings := models.LinkSettings{Rules: []models.TargetRule{rule}}
+		require.ErrorIs(t, settings.Validate(), models.ErrInvalidLinkSettings, rule)
+	}
+
+	settings := models.LinkSettings{Rules: []models.TargetRule{
+		{Platform: models.PlatformAndroid, URL: "https://play.google.com/store/apps/details?id=app"},
+		{Language: "pt-BR", URL: "https://example.com/br"},
+	}}
+	require.Nil(t, settings.Validate())
+}
+
+func TestDestinationGeo(t *testing.T) {
+	l := link(t, "https://example.com/", models.LinkSettings{
+		Rules: []models.TargetRule{{Platform: models.PlatformIOS, URL: "https://apps.apple.com/app/id123"}},
+		Geo:   map[string]string{"DE": "https://example.de/"},
+	})
+
+	visit := &redirect.Visit{Country: "DE", Platform: models.PlatformDesktop}
+	require.Equal(t, "https://example.de/", redirect.Destination(l, visit).String())
+
+	// Rules take precedence over geo targets
+	visit = &redirect.Visit{Country: "DE", Platform: models.PlatformIOS}
+	require.Equal(t, "https://apps.apple.com/app/id123", redirect.Destination(l, visit).String())
+
+	visit = &redirect.Visit{Country: "US"}
+	require.Equal(t, "https://example.com/", redirect.Destination(l, visit).String())
+
+	for _, geo := range []map[string]string{{"de": "https://example.de/"}, {"DEU": "https://example.de/"}, {"DE": "example.de"}} {
+		settings := models.LinkSettings{Geo: geo}
+		require.ErrorIs(t, settings.Validate(), models.ErrInvalidLinkSettings)
+	}
+}
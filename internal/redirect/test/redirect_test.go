@@ -0,0 +1,100 @@
+package test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/redirect"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func link(t *testing.T, dest string, settings models.LinkSettings) *models.ShortenedURL {
+	u, err := url.Parse(dest)
+	require.Nil(t, err)
+	return &models.ShortenedURL{URL: u, LinkSettings: settings}
+}
+
+func TestDestinationWithoutPassthrough(t *testing.T) {
+	l := link(t, "https://example.com/landing?a=1", models.LinkSettings{})
+	req := httptest.NewRequest("GET", "/abc/extra?utm_source=x", nil)
+
+	dest := redirect.Destination(l, redirect.NewVisit(req, "abc", "extra"))
+	require.Equal(t, "https://example.com/landing?a=1", dest.String())
+}
+
+func TestDestinationPathPassthrough(t *testing.T) {
+	l := link(t, "https://example.com/docs/", models.LinkSettings{
+		Passthrough: &models.Passthrough{Path: true},
+	})
+	req := httptest.NewRequest("GET", "/abc/guide/intro", nil)
+
+	dest := redirect.Destination(l, redirect.NewVisit(req, "abc", "guide/intro"))
+	require.Equal(t, "https://example.com/docs/guide/intro", dest.String())
+
+	// Path traversal can not escape the destination path
+	for _, rest := range []string{"../../admin", "guide/../../admin", "./../admin"} {
+		dest = redirect.Destination(l, redirect.NewVisit(req, "abc", rest))
+		require.Equal(t, "https://example.com/docs/admin", dest.String(), rest)
+	}
+}
+
+func TestDestinationQueryPassthrough(t *testing.T) {
+	tests := []struct {
+		conflict string
+		expected string
+	}{
+		{"", "https://example.com/?a=2&b=3"},
+		{models.QueryConflictRequest, "https://example.com/?a=2&b=3"},
+		{models.QueryConflictDestination, "https://example.com/?a=1&b=3"},
+		{models.QueryConflictAppend, "https://example.com/?a=1&a=2&b=3"},
+	}
+
+	for _, test := range tests {
+		l := link(t, "https://example.com/?a=1", models.LinkSettings{
+			Passthrough: &models.Passthrough{Query: true, QueryConflict: test.conflict},
+		})
+		req := httptest.NewRequest("GET", "/abc?a=2&b=3", nil)
+
+		dest := redirect.Destination(l, redirect.NewVisit(req, "abc", ""))
+		require.Equal(t, test.expected, dest.String(), test.conflict)
+	}
+}
+
+func TestDestinationParamTemplate(t *testing.T) {
+	l := link(t, "https://example.com/?utm_source=old", models.LinkSettings{
+		ParamTemplate: map[string]string{
+			"utm_source":   "{referrer_host}",
+			"utm_medium":   "social",
+			"utm_campaign": "launch-{date}-{code}",
+			"ref":          "{referrer}",
+		},
+	})
+	req := httptest.NewRequest("GET", "/abc", nil)
+	req.Header.Set("Referer", "https://news.example.org/item?id=1")
+
+	visit := redirect.NewVisit(req, "abc", "")
+	visit.Time = time.Date(2024, 3, 9, 23, 0, 0, 0, time.FixedZone("X", -3*60*60))
+
+	dest := redirect.Destination(l, visit)
+	require.Equal(t, url.Values{
+		"utm_source":   {"news.example.org"},
+		"utm_medium":   {"social"},
+		"utm_campaign": {"launch-2024-03-10-abc"},
+		"ref":          {"https://news.example.org/item?id=1"},
+	}, dest.Query())
+}
+
+func TestDestinationParamTemplateOmitsEmpty(t *testing.T) {
+	l := link(t, "https://example.com/?utm_source=newsletter", models.LinkSettings{
+		ParamTemplate: map[string]string{"utm_source": "{referrer_host}", "utm_content": "{referrer}"},
+		Passthrough:   &models.Passthrough{Query: true},
+	})
+	req := httptest.NewRequest("GET", "/abc?utm_content=banner", nil)
+
+	// No referrer: the destination value is kept and the request still wins
+	dest := redirect.Destination(l, redirect.NewVisit(req, "abc", ""))
+	require.Equal(t, "https://example.com/?utm_content=banner&utm_source=newsletter", dest.String())
+}
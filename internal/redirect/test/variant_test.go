@@ -0,0 +1,89 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/redirect"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func variantLink(t *testing.T) *models.ShortenedURL {
+	return link(t, "https://example.com/", models.LinkSettings{
+		Variants: []models.Variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 3},
+			{Name: "b", URL: "https://example.com/b", Weight: 1},
+		},
+	})
+}
+
+func TestChooseVariantWeights(t *testing.T) {
+	l := variantLink(t)
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		visit := &redirect.Visit{
+			Code:      "abc",
+			IP:        netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}),
+			UserAgent: fmt.Sprintf("agent-%d", i%7),
+		}
+		counts[redirect.ChooseVariant(l, visit).Name]++
+	}
+
+	require.InDelta(t, 3000, counts["a"], 200)
+	require.InDelta(t, 1000, counts["b"], 200)
+}
+
+func TestChooseVariantSticky(t *testing.T) {
+	l := variantLink(t)
+	visit := &redirect.Visit{Code: "abc", IP: netip.MustParseAddr("203.0.113.9"), UserAgent: "agent"}
+
+	first := redirect.ChooseVariant(l, visit)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, first, redirect.ChooseVariant(l, visit))
+	}
+
+	// The cookie wins over the hash, unless it names a removed variant
+	visit.Variant = "b"
+	require.Equal(t, "b", redirect.ChooseVariant(l, visit).Name)
+	visit.Variant = "c"
+	require.Equal(t, first, redirect.ChooseVariant(l, visit))
+
+	require.Nil(t, redirect.ChooseVariant(link(t, "https://example.com/", models.LinkSettings{}), visit))
+}
+
+func TestDestinationVariant(t *testing.T) {
+	l := variantLink(t)
+	l.Rules = []models.TargetRule{{Bot: true, URL: "https://example.com/preview"}}
+
+	req := httptest.NewRequest("GET", "/abc", nil)
+	req.AddCookie(&http.Cookie{Name: redirect.VariantCookie("abc"), Value: "b"})
+	visit := redirect.NewVisit(req, "abc", "")
+	require.Equal(t, "https://example.com/b", redirect.Destination(l, visit).String())
+	require.Equal(t, "b", visit.Variant)
+	require.Equal(t, "b", visit.Click("https://snipr.com/abc").Variant)
+
+	// Visitors matched by a rule are not part of the experiment
+	req.Header.Set("User-Agent", botUA)
+	visit = redirect.NewVisit(req, "abc", "")
+	require.Equal(t, "https://example.com/preview", redirect.Destination(l, visit).String())
+	require.Equal(t, "", visit.Variant)
+}
+
+func TestVariantValidation(t *testing.T) {
+	invalid := [][]models.Variant{
+		{{Name: "", URL: "https://example.com/a", Weight: 1}},
+		{{Name: "a b", URL: "https://example.com/a", Weight: 1}},
+		{{Name: "a", URL: "https://example.com/a", Weight: 0}},
+		{{Name: "a", URL: "/a", Weight: 1}},
+		{{Name: "a", URL: "https://example.com/a", Weight: 1}, {Name: "a", URL: "https://example.com/b", Weight: 1}},
+	}
+	for _, variants := range invalid {
+		settings := models.LinkSettings{Variants: variants}
+		require.ErrorIs(t, settings.Validate(), models.ErrInvalidLinkSettings, variants)
+	}
+}
@@ -0,0 +1,68 @@
+package redirect
+
+import (
+	"hash/fnv"
+	"net/url"
+
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+const variantCookiePrefix = "snipr_variant_"
+
+// VariantCookie is the name of the cookie remembering which variant of the
+// link with code a visitor was assigned.
+func VariantCookie(code string) string {
+	return variantCookiePrefix + code
+}
+
+// ChooseVariant picks the variant of link for visit. Visitors already
+// holding an existing variant keep it; others are assigned by hashing their
+// address and user agent, so they stay on the same variant even without
+// cookies. It returns nil if link has no variants.
+func ChooseVariant(link *models.ShortenedURL, visit *Visit) *models.Variant {
+	if len(link.Variants) == 0 {
+		return nil
+	}
+
+	total := 0
+	for i := range link.Variants {
+		if link.Variants[i].Name == visit.Variant {
+			return &link.Variants[i]
+		}
+		total += link.Variants[i].Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(visit.IP.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(visit.UserAgent))
+	h.Write([]byte{0})
+	h.Write([]byte(visit.Code))
+	bucket := int(h.Sum64() % uint64(total))
+
+	for i := range link.Variants {
+		bucket -= link.Variants[i].Weight
+		if bucket < 0 {
+			return &link.Variants[i]
+		}
+	}
+	return &link.Variants[len(link.Variants)-1]
+}
+
+// variantTarget resolves the destination of the variant chosen for visit
+// and records its name on visit.
+func variantTarget(link *models.ShortenedURL, visit *Visit) *url.URL {
+	variant := ChooseVariant(link, visit)
+	if variant == nil {
+		return nil
+	}
+	target, err := url.Parse(variant.URL)
+	if err != nil {
+		return nil
+	}
+	visit.Variant = variant.Name
+	return target
+}
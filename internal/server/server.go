@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/health"
+)
+
+const (
+	defaultReadTimeout       = 10 * time.Second
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultWriteTimeout      = 15 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultShutdownTimeout   = 20 * time.Second
+)
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// Server owns the HTTP server and the ordered shutdown of everything the
+// process started.
+type Server struct {
+	HTTP       *http.Server
+	companions []*http.Server
+	conf       *config.ServerConfig
+	checker    *health.Checker
+	logger     *slog.Logger
+	closers    []closer
+}
+
+func New(conf *config.ServerConfig, port int, handler http.Handler, checker *health.Checker, logger *slog.Logger) *Server {
+	if conf == nil {
+		conf = &config.ServerConfig{}
+	}
+
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              net.JoinHostPort(conf.ListenAddress, strconv.Itoa(port)),
+			Handler:           handler,
+			ReadTimeout:       orDefault(conf.ReadTimeout, defaultReadTimeout),
+			ReadHeaderTimeout: orDefault(conf.ReadHeaderTimeout, defaultReadHeaderTimeout),
+			WriteTimeout:      orDefault(conf.WriteTimeout, defaultWriteTimeout),
+			IdleTimeout:       orDefault(conf.IdleTimeout, defaultIdleTimeout),
+			MaxHeaderBytes:    conf.MaxHeaderBytes,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		},
+		conf:    conf,
+		checker: checker,
+		logger:  logger,
+	}
+}
+
+// Also serves srv alongside the main server, for example a plain HTTP
+// redirect listener, and shuts it down together with it.
+func (s *Server) Also(srv *http.Server) {
+	s.companions = append(s.companions, srv)
+}
+
+// OnShutdown registers fn to run after the HTTP server has drained. Hooks
+// run in registration order, so register background pipelines before the
+// connections they write to.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.closers = append(s.closers, closer{name: name, close: fn})
+}
+
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is cancelled, then shuts down gracefully:
+// readiness starts failing, the listener closes after the configured delay,
+// in-flight requests drain and finally the shutdown hooks run.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1+len(s.companions))
+	go func() {
+		s.logger.Info("Starting server", slog.String("addr", ln.Addr().String()), slog.Bool("tls", s.HTTP.TLSConfig != nil))
+		if s.HTTP.TLSConfig != nil {
+			serveErr <- s.HTTP.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- s.HTTP.Serve(ln)
+	}()
+	for _, companion := range s.companions {
+		go func(srv *http.Server) {
+			s.logger.Info("Starting server", slog.String("addr", srv.Addr))
+			serveErr <- srv.ListenAndServe()
+		}(companion)
+	}
+
+	pending := 1 + len(s.companions)
+	var errs []error
+	select {
+	case err := <-serveErr:
+		// A listener failed on its own; stop the others as well.
+		pending--
+		errs = append(errs, err)
+	case <-ctx.Done():
+		s.logger.Info("Shutting down")
+		if s.checker != nil {
+			s.checker.MarkShuttingDown()
+		}
+		if s.conf.ShutdownDelay > 0 {
+			time.Sleep(s.conf.ShutdownDelay)
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), orDefault(s.conf.ShutdownTimeout, defaultShutdownTimeout))
+	defer cancel()
+
+	for _, srv := range append([]*http.Server{s.HTTP}, s.companions...) {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("http server %s: %w", srv.Addr, err))
+		}
+	}
+	for ; pending > 0; pending-- {
+		if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, s.close(shutdownCtx))
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) close(ctx context.Context) error {
+	var errs []error
+	for _, c := range s.closers {
+		s.logger.Info("Closing", slog.String("component", c.name))
+		if err := c.close(ctx); err != nil {
+			s.logger.Error("Failed to close", slog.String("component", c.name), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func orDefault(d time.Duration, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
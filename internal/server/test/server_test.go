@@ -0,0 +1,78 @@
+package test
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/health"
+	"github.com/sri-shubham/snipr/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAppliesConfig(t *testing.T) {
+	srv := server.New(&config.ServerConfig{
+		ListenAddress:  "127.0.0.1",
+		ReadTimeout:    3 * time.Second,
+		MaxHeaderBytes: 4096,
+	}, 9090, http.NotFoundHandler(), nil, slog.Default())
+
+	require.Equal(t, "127.0.0.1:9090", srv.HTTP.Addr)
+	require.Equal(t, 3*time.Second, srv.HTTP.ReadTimeout)
+	require.NotZero(t, srv.HTTP.WriteTimeout)
+	require.Equal(t, 4096, srv.HTTP.MaxHeaderBytes)
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	checker := health.NewChecker(0)
+	srv := server.New(&config.ServerConfig{ShutdownTimeout: 5 * time.Second}, 0, handler, checker, slog.Default())
+
+	closed := []string{}
+	srv.OnShutdown("pipeline", func(context.Context) error {
+		closed = append(closed, "pipeline")
+		return nil
+	})
+	srv.OnShutdown("postgres", func(context.Context) error {
+		closed = append(closed, "postgres")
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ctx, ln) }()
+
+	respStatus := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			respStatus <- 0
+			return
+		}
+		resp.Body.Close()
+		respStatus <- resp.StatusCode
+	}()
+
+	<-started
+	cancel()
+	require.Eventually(t, checker.ShuttingDown, time.Second, 10*time.Millisecond)
+	close(release)
+
+	require.Equal(t, http.StatusOK, <-respStatus)
+	require.Nil(t, <-serveErr)
+	require.Equal(t, []string{"pipeline", "postgres"}, closed)
+}
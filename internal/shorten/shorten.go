@@ -6,7 +6,9 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
+	"reflect"
 	"regexp"
 	"time"
 
@@ -14,40 +16,79 @@ import (
 	"github.com/sri-shubham/snipr/storage"
 	"github.com/sri-shubham/snipr/storage/models"
 	"github.com/sri-shubham/snipr/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/sri-shubham/snipr/internal/shorten")
+
+// defaultQuarantine keeps codes of archived links out of use for a year.
+const defaultQuarantine = 365 * 24 * time.Hour
+
 var ErrNotAvailable = errors.New("short url not available")
 
-var customCodeRegexp *regexp.Regexp = regexp.MustCompile("[a-zA-Z1-9]+")
+var ErrInvalidCustomCode = errors.New("invalid custom url code")
+
+var customCodeRegexp *regexp.Regexp = regexp.MustCompile("^[a-zA-Z1-9]+$")
 
 type Shortener interface {
-	Shorten(ctx context.Context, url *url.URL, ttl time.Duration) (*models.ShortenedURL, error)
-	ShortenCustom(ctx context.Context, url *url.URL, customString string, ttl time.Duration) (*models.ShortenedURL, error)
+	Shorten(ctx context.Context, url *url.URL, ttl time.Duration, settings models.LinkSettings) (*models.ShortenedURL, error)
+	ShortenCustom(ctx context.Context, url *url.URL, customString string, ttl time.Duration, settings models.LinkSettings) (*models.ShortenedURL, error)
+	// ShortURL returns the short url a code is served under.
+	ShortURL(code string) string
 }
 
 type shortenImpl struct {
 	storage         storage.URLStorage
+	archive         storage.URLArchive
+	quarantine      time.Duration
 	minLength       int
 	customMinLength int
 	customMaxLength int
 	host            string
+	logger          *slog.Logger
 }
 
 func NewShortener(minLength int,
 	customMinLength int,
 	customMaxLength int,
 	host string,
-	storage storage.URLStorage) Shortener {
+	storage storage.URLStorage,
+	archive storage.URLArchive,
+	quarantine time.Duration,
+	logger *slog.Logger) Shortener {
+	if quarantine == 0 {
+		quarantine = defaultQuarantine
+	}
 	return &shortenImpl{
 		storage:         storage,
+		archive:         archive,
+		quarantine:      quarantine,
 		minLength:       minLength,
 		customMinLength: customMinLength,
 		customMaxLength: customMaxLength,
 		host:            host,
+		logger:          logger,
 	}
 }
 
-func (s *shortenImpl) Shorten(ctx context.Context, url *url.URL, ttl time.Duration) (*models.ShortenedURL, error) {
+func (s *shortenImpl) ShortURL(code string) string {
+	return fmt.Sprintf("https://%s/%s", s.host, code)
+}
+
+func (s *shortenImpl) Shorten(ctx context.Context, url *url.URL, ttl time.Duration, settings models.LinkSettings) (_ *models.ShortenedURL, err error) {
+	ctx, span := tracer.Start(ctx, "shorten.Shorten", trace.WithAttributes(attribute.String("url.host", url.Host)))
+	defer func() { endSpan(span, err) }()
+
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	if err := settings.HashPassword(); err != nil {
+		return nil, err
+	}
+
 	stringURL := url.String()
 	hash := sha256.Sum256([]byte(stringURL))
 
@@ -56,27 +97,32 @@ func (s *shortenImpl) Shorten(ctx context.Context, url *url.URL, ttl time.Durati
 	for {
 		shortCode := hash[:currentLen]
 		encoded := base62.EncodeToString(shortCode)
-		currentShortenUrl = fmt.Sprintf("https://%s/%s", s.host, encoded)
+		currentShortenUrl = s.ShortURL(encoded)
 
 		existingUrl, err := s.storage.GetOriginalURL(ctx, currentShortenUrl)
-		if err != nil {
-			fmt.Println(err, errors.Is(err, util.ErrNotFound))
-			if errors.Is(err, util.ErrNotFound) {
+		if errors.Is(err, util.ErrNotFound) {
+			quarantined, err := s.quarantined(ctx, currentShortenUrl)
+			if err != nil {
+				return nil, err
+			}
+			if !quarantined {
 				break
 			}
+		} else if err != nil {
 			return nil, err
+		} else if existingUrl != nil && existingUrl.URL.String() == stringURL && reusable(existingUrl, settings) {
+			// If this url is already shortended with the same settings return existing one
+			return existingUrl, nil
 		}
-		if existingUrl != nil {
-			if existingUrl.URL.String() == stringURL {
-				// If this url is already shortended return existing one
-				return existingUrl, nil
-			}
-			currentLen++
 
-			if currentLen == len(hash) {
-				return nil, ErrNotAvailable
-			}
-			continue
+		// The code is taken by another link or still quarantined
+		currentLen++
+		span.AddEvent("collision", trace.WithAttributes(attribute.Int("snipr.code_length", currentLen)))
+		s.logger.DebugContext(ctx, "Short code collision, retrying with longer code",
+			slog.Int("code_length", currentLen))
+
+		if currentLen == len(hash) {
+			return nil, ErrNotAvailable
 		}
 	}
 
@@ -87,8 +133,9 @@ func (s *shortenImpl) Shorten(ctx context.Context, url *url.URL, ttl time.Durati
 
 	shortendUrl := &models.ShortenedURL{
 		URL:          url,
-		TTLInSeconds: int64(ttl),
+		TTLInSeconds: int64(ttl / time.Second),
 		ShortURL:     shortUrl,
+		LinkSettings: settings,
 	}
 
 	err = s.storage.StoreShortURL(ctx, shortendUrl)
@@ -105,24 +152,43 @@ func (s *shortenImpl) Shorten(ctx context.Context, url *url.URL, ttl time.Durati
 }
 
 // ShortenCustom implements Shortener.
-func (s *shortenImpl) ShortenCustom(ctx context.Context, url *url.URL, customString string, ttl time.Duration) (*models.ShortenedURL, error) {
-	if len(customString) < s.customMinLength && len(customString) > s.customMaxLength {
-		return nil, fmt.Errorf("custom url code should be between %d, %d", s.customMinLength, s.customMaxLength)
+func (s *shortenImpl) ShortenCustom(ctx context.Context, url *url.URL, customString string, ttl time.Duration, settings models.LinkSettings) (_ *models.ShortenedURL, err error) {
+	ctx, span := tracer.Start(ctx, "shorten.ShortenCustom", trace.WithAttributes(attribute.String("url.host", url.Host)))
+	defer func() { endSpan(span, err) }()
+
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	if err := settings.HashPassword(); err != nil {
+		return nil, err
+	}
+
+	if len(customString) < s.customMinLength || len(customString) > s.customMaxLength {
+		return nil, fmt.Errorf("%w: custom url code should be between %d, %d", ErrInvalidCustomCode, s.customMinLength, s.customMaxLength)
 	}
 
 	if !customCodeRegexp.Match([]byte(customString)) {
-		return nil, fmt.Errorf("custom url can only contain alphanumeric string")
+		return nil, fmt.Errorf("%w: custom url can only contain alphanumeric string", ErrInvalidCustomCode)
 	}
 
-	currentShortenUrl := fmt.Sprintf("https://%s/%s", s.host, customString)
+	currentShortenUrl := s.ShortURL(customString)
 	existingURL, err := s.storage.GetOriginalURL(ctx, currentShortenUrl)
-	if !errors.Is(err, util.ErrNotFound) {
+	if err != nil && !errors.Is(err, util.ErrNotFound) {
 		return nil, err
 	}
 
-	if existingURL.URL.String() == currentShortenUrl {
-		return existingURL, nil
-	} else if existingURL != nil {
+	if existingURL != nil {
+		if existingURL.URL.String() == url.String() && reusable(existingURL, settings) {
+			return existingURL, nil
+		}
+		return nil, ErrNotAvailable
+	}
+
+	quarantined, err := s.quarantined(ctx, currentShortenUrl)
+	if err != nil {
+		return nil, err
+	}
+	if quarantined {
 		return nil, ErrNotAvailable
 	}
 
@@ -133,8 +199,9 @@ func (s *shortenImpl) ShortenCustom(ctx context.Context, url *url.URL, customStr
 
 	shortendUrl := &models.ShortenedURL{
 		URL:          url,
-		TTLInSeconds: int64(ttl),
+		TTLInSeconds: int64(ttl / time.Second),
 		ShortURL:     shortUrl,
+		LinkSettings: settings,
 	}
 
 	err = s.storage.StoreShortURL(ctx, shortendUrl)
@@ -149,3 +216,32 @@ func (s *shortenImpl) ShortenCustom(ctx context.Context, url *url.URL, customStr
 
 	return shortendUrl, nil
 }
+
+// quarantined reports whether shortURL belonged to a link archived within
+// the quarantine period, so an old code never leads somewhere new.
+func (s *shortenImpl) quarantined(ctx context.Context, shortURL string) (bool, error) {
+	if s.archive == nil {
+		return false, nil
+	}
+
+	since := time.Time{}
+	if s.quarantine > 0 {
+		since = time.Now().Add(-s.quarantine)
+	}
+	return s.archive.ArchivedSince(ctx, shortURL, since)
+}
+
+// reusable reports whether an existing link can be handed out again for a
+// request with settings. Click limited links never are, since every
+// requester expects their own clicks.
+func reusable(existing *models.ShortenedURL, settings models.LinkSettings) bool {
+	return settings.MaxClicks == 0 && reflect.DeepEqual(existing.LinkSettings, settings)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -37,32 +37,46 @@ func (m *MockShortener) EXPECT() *MockShortenerMockRecorder {
 	return m.recorder
 }
 
+// ShortURL mocks base method.
+func (m *MockShortener) ShortURL(code string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShortURL", code)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ShortURL indicates an expected call of ShortURL.
+func (mr *MockShortenerMockRecorder) ShortURL(code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortURL", reflect.TypeOf((*MockShortener)(nil).ShortURL), code)
+}
+
 // Shorten mocks base method.
-func (m *MockShortener) Shorten(ctx context.Context, url *url.URL, ttl time.Duration) (*models.ShortenedURL, error) {
+func (m *MockShortener) Shorten(ctx context.Context, url *url.URL, ttl time.Duration, settings models.LinkSettings) (*models.ShortenedURL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Shorten", ctx, url, ttl)
+	ret := m.ctrl.Call(m, "Shorten", ctx, url, ttl, settings)
 	ret0, _ := ret[0].(*models.ShortenedURL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Shorten indicates an expected call of Shorten.
-func (mr *MockShortenerMockRecorder) Shorten(ctx, url, ttl interface{}) *gomock.Call {
+func (mr *MockShortenerMockRecorder) Shorten(ctx, url, ttl, settings interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shorten", reflect.TypeOf((*MockShortener)(nil).Shorten), ctx, url, ttl)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shorten", reflect.TypeOf((*MockShortener)(nil).Shorten), ctx, url, ttl, settings)
 }
 
 // ShortenCustom mocks base method.
-func (m *MockShortener) ShortenCustom(ctx context.Context, url *url.URL, customString string, ttl time.Duration) (*models.ShortenedURL, error) {
+func (m *MockShortener) ShortenCustom(ctx context.Context, url *url.URL, customString string, ttl time.Duration, settings models.LinkSettings) (*models.ShortenedURL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ShortenCustom", ctx, url, customString, ttl)
+	ret := m.ctrl.Call(m, "ShortenCustom", ctx, url, customString, ttl, settings)
 	ret0, _ := ret[0].(*models.ShortenedURL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ShortenCustom indicates an expected call of ShortenCustom.
-func (mr *MockShortenerMockRecorder) ShortenCustom(ctx, url, customString, ttl interface{}) *gomock.Call {
+func (mr *MockShortenerMockRecorder) ShortenCustom(ctx, url, customString, ttl, settings interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenCustom", reflect.TypeOf((*MockShortener)(nil).ShortenCustom), ctx, url, customString, ttl)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenCustom", reflect.TypeOf((*MockShortener)(nil).ShortenCustom), ctx, url, customString, ttl, settings)
 }
@@ -2,8 +2,12 @@ package test
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sri-shubham/snipr/internal/shorten"
@@ -11,6 +15,11 @@ import (
 	"github.com/sri-shubham/snipr/storage/models"
 	"github.com/sri-shubham/snipr/util"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestShorten(t *testing.T) {
@@ -25,6 +34,9 @@ func TestShorten(t *testing.T) {
 		8,
 		"localhost:8080",
 		storageMock,
+		nil,
+		0,
+		slog.Default(),
 	)
 
 	longURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
@@ -44,7 +56,7 @@ func TestShorten(t *testing.T) {
 		URL:      longURL,
 		ShortURL: expectedShortURL,
 	}, nil)
-	shortenedUrl, err := shortener.Shorten(context.Background(), longURL, 0)
+	shortenedUrl, err := shortener.Shorten(context.Background(), longURL, 0, models.LinkSettings{})
 	require.Nil(t, err)
 	require.NotNil(t, shortenedUrl)
 
@@ -52,7 +64,7 @@ func TestShorten(t *testing.T) {
 	storageMock.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any()).Return(&models.ShortenedURL{
 		URL: longURL,
 	}, nil)
-	shortenedUrl2, err := shortener.Shorten(context.Background(), longURL, 1000)
+	shortenedUrl2, err := shortener.Shorten(context.Background(), longURL, 1000, models.LinkSettings{})
 	require.Nil(t, err)
 	require.NotNil(t, shortenedUrl2)
 
@@ -72,6 +84,9 @@ func TestShortenWhenMinLengthNotAvailable(t *testing.T) {
 		8,
 		"localhost:8080",
 		storageMock,
+		nil,
+		0,
+		slog.Default(),
 	)
 
 	longURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
@@ -104,7 +119,211 @@ func TestShortenWhenMinLengthNotAvailable(t *testing.T) {
 		ShortURL:     expectedShortURL2,
 		TTLInSeconds: 1000,
 	}, nil)
-	shortenedUrl, err := shortener.Shorten(context.Background(), longURL, 1000)
+	shortenedUrl, err := shortener.Shorten(context.Background(), longURL, 1000*time.Second, models.LinkSettings{})
 	require.Nil(t, err)
 	require.NotNil(t, shortenedUrl)
 }
+
+func TestShortenRecordsSpan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	storageMock := storage.NewMockURLStorage(ctrl)
+
+	shortener := shorten.NewShortener(
+		4,
+		6,
+		8,
+		"localhost:8080",
+		storageMock,
+		nil,
+		0,
+		slog.Default(),
+	)
+
+	longURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused"))
+	_, err = shortener.Shorten(context.Background(), longURL, 0, models.LinkSettings{})
+	require.NotNil(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "shorten.Shorten", spans[0].Name())
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestShortenCustom(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockURLStorage(ctrl)
+
+	shortener := shorten.NewShortener(
+		4,
+		6,
+		8,
+		"localhost:8080",
+		storageMock,
+		nil,
+		0,
+		slog.Default(),
+	)
+
+	longURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+
+	expectedShortURL, err := url.Parse("https://localhost:8080/sniper")
+	require.Nil(t, err)
+
+	settings := models.LinkSettings{RedirectType: http.StatusMovedPermanently}
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), expectedShortURL.String()).Return(nil, util.ErrNotFound)
+	storageMock.EXPECT().StoreShortURL(gomock.Any(), &models.ShortenedURL{
+		URL:          longURL,
+		ShortURL:     expectedShortURL,
+		LinkSettings: settings,
+	}).Return(nil)
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), expectedShortURL.String()).Return(&models.ShortenedURL{
+		URL:          longURL,
+		ShortURL:     expectedShortURL,
+		LinkSettings: settings,
+	}, nil)
+	shortenedUrl, err := shortener.ShortenCustom(context.Background(), longURL, "sniper", 0, settings)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusMovedPermanently, shortenedUrl.RedirectType)
+
+	// The code is now taken by a different destination
+	otherURL, err := url.Parse("https://example.com")
+	require.Nil(t, err)
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), expectedShortURL.String()).Return(&models.ShortenedURL{
+		URL:          longURL,
+		ShortURL:     expectedShortURL,
+		LinkSettings: settings,
+	}, nil)
+	_, err = shortener.ShortenCustom(context.Background(), otherURL, "sniper", 0, settings)
+	require.ErrorIs(t, err, shorten.ErrNotAvailable)
+
+	_, err = shortener.ShortenCustom(context.Background(), otherURL, "snip/er", 0, settings)
+	require.NotNil(t, err)
+
+	_, err = shortener.ShortenCustom(context.Background(), otherURL, "sniper", 0, models.LinkSettings{RedirectType: 200})
+	require.ErrorIs(t, err, models.ErrInvalidLinkSettings)
+}
+
+func TestShortenClickLimitedNotReused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockURLStorage(ctrl)
+
+	shortener := shorten.NewShortener(
+		4,
+		6,
+		8,
+		"localhost:8080",
+		storageMock,
+		nil,
+		0,
+		slog.Default(),
+	)
+
+	longURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+	settings := models.LinkSettings{MaxClicks: 1}
+
+	// The one-time link for this url is taken, so a longer code is used
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/6H6EhC").Return(&models.ShortenedURL{
+		URL:          longURL,
+		LinkSettings: settings,
+	}, nil)
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/fUfhORo").Return(nil, util.ErrNotFound)
+	storageMock.EXPECT().StoreShortURL(gomock.Any(), gomock.Any()).Return(nil)
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/fUfhORo").Return(&models.ShortenedURL{
+		URL:          longURL,
+		LinkSettings: settings,
+	}, nil)
+
+	_, err = shortener.Shorten(context.Background(), longURL, 1000, settings)
+	require.Nil(t, err)
+}
+
+func TestShortenSkipsQuarantinedCodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockURLStorage(ctrl)
+	archiveMock := storage.NewMockURLArchive(ctrl)
+
+	shortener := shorten.NewShortener(
+		4,
+		6,
+		8,
+		"localhost:8080",
+		storageMock,
+		archiveMock,
+		24*time.Hour,
+		slog.Default(),
+	)
+
+	longURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+
+	// The first code belonged to an archived link
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/6H6EhC").Return(nil, util.ErrNotFound)
+	archiveMock.EXPECT().ArchivedSince(gomock.Any(), "https://localhost:8080/6H6EhC", gomock.Any()).DoAndReturn(func(_ context.Context, _ string, since time.Time) (bool, error) {
+		require.WithinDuration(t, time.Now().Add(-24*time.Hour), since, time.Minute)
+		return true, nil
+	})
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/fUfhORo").Return(nil, util.ErrNotFound)
+	archiveMock.EXPECT().ArchivedSince(gomock.Any(), "https://localhost:8080/fUfhORo", gomock.Any()).Return(false, nil)
+	storageMock.EXPECT().StoreShortURL(gomock.Any(), gomock.Any()).Return(nil)
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/fUfhORo").Return(&models.ShortenedURL{
+		URL: longURL,
+	}, nil)
+
+	_, err = shortener.Shorten(context.Background(), longURL, time.Hour, models.LinkSettings{})
+	require.Nil(t, err)
+
+	// Custom codes in quarantine are not available
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/sniper").Return(nil, util.ErrNotFound)
+	archiveMock.EXPECT().ArchivedSince(gomock.Any(), "https://localhost:8080/sniper", gomock.Any()).Return(true, nil)
+
+	_, err = shortener.ShortenCustom(context.Background(), longURL, "sniper", time.Hour, models.LinkSettings{})
+	require.ErrorIs(t, err, shorten.ErrNotAvailable)
+}
+
+func TestQuarantineDefaultsToAYear(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	longURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+
+	for _, test := range []struct {
+		quarantine time.Duration
+		since      time.Time
+	}{
+		// Unset, as without an archive section in the config
+		{0, time.Now().Add(-365 * 24 * time.Hour)},
+		// Negative keeps codes out of use forever
+		{-time.Hour, time.Time{}},
+	} {
+		storageMock := storage.NewMockURLStorage(ctrl)
+		archiveMock := storage.NewMockURLArchive(ctrl)
+		shortener := shorten.NewShortener(4, 6, 8, "localhost:8080", storageMock, archiveMock, test.quarantine, slog.Default())
+
+		storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/sniper").Return(nil, util.ErrNotFound)
+		archiveMock.EXPECT().ArchivedSince(gomock.Any(), "https://localhost:8080/sniper", gomock.Any()).DoAndReturn(func(_ context.Context, _ string, since time.Time) (bool, error) {
+			require.WithinDuration(t, test.since, since, time.Minute)
+			return true, nil
+		})
+
+		_, err = shortener.ShortenCustom(context.Background(), longURL, "sniper", time.Hour, models.LinkSettings{})
+		require.ErrorIs(t, err, shorten.ErrNotAvailable)
+	}
+}
@@ -0,0 +1,210 @@
+package sweeper
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/webhook"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultInterval    = 10 * time.Minute
+	defaultBatchSize   = 1000
+	defaultGracePeriod = 30 * 24 * time.Hour
+)
+
+var meter = otel.Meter("github.com/sri-shubham/snipr/internal/sweeper")
+
+// ErrSweeperStopped is reported by Check once the sweeper stopped.
+var ErrSweeperStopped = errors.New("expiry sweeper is not running")
+
+// Sweeper periodically tells webhooks about links that expired and archives
+// links that expired longer than the grace period ago.
+type Sweeper struct {
+	archive  storage.URLArchive
+	tx       storage.Transactor
+	webhooks webhook.Publisher
+	conf     config.SweeperConfig
+	logger   *slog.Logger
+	swept    metric.Int64Counter
+
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	running atomic.Bool
+}
+
+func New(archive storage.URLArchive, tx storage.Transactor, webhooks webhook.Publisher, conf *config.SweeperConfig, logger *slog.Logger) (*Sweeper, error) {
+	sweeperConf := config.SweeperConfig{}
+	if conf != nil {
+		sweeperConf = *conf
+	}
+	if sweeperConf.Interval <= 0 {
+		sweeperConf.Interval = defaultInterval
+	}
+	if sweeperConf.BatchSize <= 0 {
+		sweeperConf.BatchSize = defaultBatchSize
+	}
+	if sweeperConf.GracePeriod <= 0 {
+		sweeperConf.GracePeriod = defaultGracePeriod
+	}
+
+	swept, err := meter.Int64Counter("snipr.sweeper.swept",
+		metric.WithDescription("Expired links archived by the sweeper"),
+		metric.WithUnit("{link}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sweeper{
+		archive:  archive,
+		tx:       tx,
+		webhooks: webhooks,
+		conf:     sweeperConf,
+		logger:   logger,
+		swept:    swept,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start launches the sweeping goroutine. The first sweep runs right away.
+func (s *Sweeper) Start() {
+	s.running.Store(true)
+	go s.run()
+}
+
+// Check reports whether the sweeping goroutine is running. It can be
+// registered as a health check.
+func (s *Sweeper) Check(context.Context) error {
+	if !s.running.Load() {
+		return ErrSweeperStopped
+	}
+	return nil
+}
+
+// Close stops sweeping after the current batch and waits for the goroutine
+// to finish or ctx to expire.
+func (s *Sweeper) Close(ctx context.Context) error {
+	s.once.Do(func() { close(s.stop) })
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sweep archives batches of links expired before now minus the grace period
+// until none are left, another instance is sweeping or ctx is done. It
+// returns how many links it archived.
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	cutoff := now.Add(-s.conf.GracePeriod)
+	total := 0
+	for ctx.Err() == nil {
+		archived, err := s.archive.ArchiveExpired(ctx, cutoff, s.conf.BatchSize)
+		if errors.Is(err, util.ErrLocked) {
+			s.logger.DebugContext(ctx, "Another instance is sweeping expired links")
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+
+		total += len(archived)
+		s.swept.Add(ctx, int64(len(archived)))
+		if len(archived) < s.conf.BatchSize {
+			break
+		}
+	}
+	return total, ctx.Err()
+}
+
+// Notify publishes link.expired for batches of links that expired before
+// now and were not reported yet, until none are left or ctx is done. It
+// returns how many links it reported. A batch is claimed and its deliveries
+// queued in one transaction, so links whose event could not be queued are
+// reported again by the next run.
+func (s *Sweeper) Notify(ctx context.Context, now time.Time) (int, error) {
+	if s.webhooks == nil {
+		return 0, nil
+	}
+
+	total := 0
+	for ctx.Err() == nil {
+		claimed := 0
+		err := s.tx.RunInTx(ctx, func(ctx context.Context) error {
+			expired, err := s.archive.ClaimExpired(ctx, now, s.conf.BatchSize)
+			if err != nil {
+				return err
+			}
+			for _, link := range expired {
+				err := s.webhooks.Enqueue(ctx, models.WebhookEventLinkExpired, models.PresentJsonShortenedURLModel(link))
+				if err != nil {
+					return err
+				}
+			}
+			claimed = len(expired)
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += claimed
+		if claimed < s.conf.BatchSize {
+			break
+		}
+	}
+	return total, ctx.Err()
+}
+
+func (s *Sweeper) run() {
+	defer close(s.done)
+	defer s.running.Store(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(s.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		reported, err := s.Notify(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to report expired links", slog.Int("reported", reported), slog.Any("error", err))
+		}
+
+		total, err := s.Sweep(ctx, time.Now())
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			s.logger.Error("Failed to sweep expired links", slog.Int("archived", total), slog.Any("error", err))
+		case total > 0:
+			s.logger.Info("Archived expired links", slog.Int("archived", total))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
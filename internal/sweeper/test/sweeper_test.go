@@ -0,0 +1,182 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/sweeper"
+	"github.com/sri-shubham/snipr/internal/webhook"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func expired(n int) []*models.JSONArchivedURL {
+	links := make([]*models.JSONArchivedURL, 0, n)
+	for range n {
+		links = append(links, &models.JSONArchivedURL{Reason: models.ArchiveReasonExpired})
+	}
+	return links
+}
+
+func TestSweepArchivesInBatches(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-24 * time.Hour)
+
+	archive := storage.NewMockURLArchive(ctrl)
+	gomock.InOrder(
+		archive.EXPECT().ArchiveExpired(gomock.Any(), cutoff, 2).Return(expired(2), nil),
+		archive.EXPECT().ArchiveExpired(gomock.Any(), cutoff, 2).Return(expired(2), nil),
+		archive.EXPECT().ArchiveExpired(gomock.Any(), cutoff, 2).Return(expired(1), nil),
+	)
+	// Archiving is not an event; webhooks were told at expiry
+	webhooks := webhook.NewMockPublisher(ctrl)
+
+	s, err := sweeper.New(archive, nil, webhooks, &config.SweeperConfig{
+		BatchSize:   2,
+		GracePeriod: 24 * time.Hour,
+	}, slog.Default())
+	require.Nil(t, err)
+
+	archived, err := s.Sweep(context.Background(), now)
+	require.Nil(t, err)
+	require.Equal(t, 5, archived)
+
+	var metrics metricdata.ResourceMetrics
+	require.Nil(t, reader.Collect(context.Background(), &metrics))
+	require.Len(t, metrics.ScopeMetrics, 1)
+	require.Equal(t, "snipr.sweeper.swept", metrics.ScopeMetrics[0].Metrics[0].Name)
+	sum := metrics.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	require.Equal(t, int64(5), sum.DataPoints[0].Value)
+}
+
+func expiredLink(now time.Time, code string) *models.ShortenedURL {
+	return &models.ShortenedURL{
+		URL:      &url.URL{Scheme: "https", Host: "example.com"},
+		ShortURL: &url.URL{Scheme: "https", Host: "snipr.com", Path: "/" + code},
+		Expires:  now.Add(-time.Minute),
+	}
+}
+
+// inTx expects n transactions, each calling fn and returning its error.
+func inTx(ctrl *gomock.Controller, n int) storage.Transactor {
+	tx := storage.NewMockTransactor(ctrl)
+	tx.EXPECT().RunInTx(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+		return fn(ctx)
+	}).Times(n)
+	return tx
+}
+
+func TestNotifyReportsExpiredLinks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	archive := storage.NewMockURLArchive(ctrl)
+	gomock.InOrder(
+		archive.EXPECT().ClaimExpired(gomock.Any(), now, 2).Return([]*models.ShortenedURL{expiredLink(now, "a"), expiredLink(now, "b")}, nil),
+		archive.EXPECT().ClaimExpired(gomock.Any(), now, 2).Return([]*models.ShortenedURL{expiredLink(now, "c")}, nil),
+	)
+	webhooks := webhook.NewMockPublisher(ctrl)
+	reported := []string{}
+	webhooks.EXPECT().Enqueue(gomock.Any(), models.WebhookEventLinkExpired, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, data any) error {
+		reported = append(reported, data.(*models.JSONShortenedURL).ShortURL)
+		return nil
+	}).Times(3)
+
+	s, err := sweeper.New(archive, inTx(ctrl, 2), webhooks, &config.SweeperConfig{BatchSize: 2}, slog.Default())
+	require.Nil(t, err)
+
+	total, err := s.Notify(context.Background(), now)
+	require.Nil(t, err)
+	require.Equal(t, 3, total)
+	require.Equal(t, []string{"https://snipr.com/a", "https://snipr.com/b", "https://snipr.com/c"}, reported)
+}
+
+func TestNotifyRollsBackClaimWhenQueueingFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	archive := storage.NewMockURLArchive(ctrl)
+	archive.EXPECT().ClaimExpired(gomock.Any(), now, 2).Return([]*models.ShortenedURL{expiredLink(now, "a"), expiredLink(now, "b")}, nil)
+	webhooks := webhook.NewMockPublisher(ctrl)
+	queueErr := errors.New("connection refused")
+	webhooks.EXPECT().Enqueue(gomock.Any(), models.WebhookEventLinkExpired, gomock.Any()).Return(queueErr)
+
+	// The transaction gets the error, so the claim is rolled back and the
+	// batch reported by the next run
+	s, err := sweeper.New(archive, inTx(ctrl, 1), webhooks, &config.SweeperConfig{BatchSize: 2}, slog.Default())
+	require.Nil(t, err)
+
+	total, err := s.Notify(context.Background(), now)
+	require.ErrorIs(t, err, queueErr)
+	require.Equal(t, 0, total)
+}
+
+func TestNotifyWithoutWebhooks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Links are not claimed, so they are reported once webhooks are enabled
+	s, err := sweeper.New(storage.NewMockURLArchive(ctrl), nil, nil, nil, slog.Default())
+	require.Nil(t, err)
+
+	total, err := s.Notify(context.Background(), time.Now())
+	require.Nil(t, err)
+	require.Equal(t, 0, total)
+}
+
+func TestSweepStopsWhenLocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	archive := storage.NewMockURLArchive(ctrl)
+	archive.EXPECT().ArchiveExpired(gomock.Any(), gomock.Any(), 1000).Return(nil, util.ErrLocked)
+
+	s, err := sweeper.New(archive, nil, nil, nil, slog.Default())
+	require.Nil(t, err)
+
+	archived, err := s.Sweep(context.Background(), time.Now())
+	require.Nil(t, err)
+	require.Equal(t, 0, archived)
+}
+
+func TestSweeperRunsUntilClosed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	swept := make(chan struct{}, 10)
+	archive := storage.NewMockURLArchive(ctrl)
+	archive.EXPECT().ArchiveExpired(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, time.Time, int) ([]*models.JSONArchivedURL, error) {
+		swept <- struct{}{}
+		return nil, nil
+	}).MinTimes(2)
+
+	s, err := sweeper.New(archive, nil, nil, &config.SweeperConfig{Interval: 10 * time.Millisecond}, slog.Default())
+	require.Nil(t, err)
+	require.ErrorIs(t, s.Check(context.Background()), sweeper.ErrSweeperStopped)
+	s.Start()
+	require.Nil(t, s.Check(context.Background()))
+
+	<-swept
+	<-swept
+	require.Nil(t, s.Close(context.Background()))
+	require.ErrorIs(t, s.Check(context.Background()), sweeper.ErrSweeperStopped)
+}
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/logging"
+	"github.com/sri-shubham/snipr/internal/telemetry"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAccessLogCarriesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	buf := &bytes.Buffer{}
+	logger, err := logging.New(&config.LogConfig{Format: logging.FormatJSON}, buf)
+	require.Nil(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /{code}", telemetry.Route("GET /{code}", func(w http.ResponseWriter, r *http.Request) {}))
+	handler := telemetry.Middleware(logging.Middleware(logger, mux))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abc123", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "GET /{code}", spans[0].Name())
+
+	line := map[string]any{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "request", line["msg"])
+	require.Equal(t, spans[0].SpanContext().TraceID().String(), line["trace_id"])
+	require.Equal(t, spans[0].SpanContext().SpanID().String(), line["span_id"])
+}
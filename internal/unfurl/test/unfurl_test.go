@@ -0,0 +1,132 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/egress"
+	"github.com/sri-shubham/snipr/internal/unfurl"
+	"github.com/stretchr/testify/require"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html>
+<head>
+<title>Fallback title</title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="  Release   notes ">
+<meta property="og:image" content="/images/cover.png">
+<link rel="shortcut icon" href="/static/icon.png">
+</head>
+<body>
+<meta property="og:description" content="Outside the head">
+</body>
+</html>`
+
+// local lets the fetcher reach the test servers, which listen on loopback.
+var local = &config.UnfurlConfig{AllowPrivateNetworks: true}
+
+func destinations() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(articlePage))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/docs/bare", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/docs/bare", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<title>Bare</title><link rel="icon" href="javascript:alert(1)">`))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><!-- " + strings.Repeat("x", 4096) + " --><title>Too late</title></head></html>"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestUnfurl(t *testing.T) {
+	server := destinations()
+	defer server.Close()
+
+	metadata, err := unfurl.New(local).Unfurl(context.Background(), server.URL+"/article")
+	require.Nil(t, err)
+	require.Equal(t, "Release notes", metadata.Title)
+	require.Equal(t, "Plain description", metadata.Description)
+	require.Equal(t, server.URL+"/images/cover.png", metadata.Image)
+	require.Equal(t, server.URL+"/static/icon.png", metadata.Favicon)
+	require.False(t, metadata.FetchedAt.IsZero())
+}
+
+func TestUnfurlFollowsRedirects(t *testing.T) {
+	server := destinations()
+	defer server.Close()
+
+	metadata, err := unfurl.New(local).Unfurl(context.Background(), server.URL+"/moved")
+	require.Nil(t, err)
+	require.Equal(t, "Bare", metadata.Title)
+	require.Empty(t, metadata.Image)
+	// Only http links are kept; the favicon falls back to the default
+	require.Equal(t, server.URL+"/favicon.ico", metadata.Favicon)
+}
+
+func TestUnfurlIsBounded(t *testing.T) {
+	server := destinations()
+	defer server.Close()
+
+	fetcher := unfurl.New(&config.UnfurlConfig{Timeout: 50 * time.Millisecond, MaxBytes: 1024, AllowPrivateNetworks: true})
+
+	metadata, err := fetcher.Unfurl(context.Background(), server.URL+"/huge")
+	require.Nil(t, err)
+	require.Empty(t, metadata.Title)
+
+	_, err = fetcher.Unfurl(context.Background(), server.URL+"/slow")
+	require.NotNil(t, err)
+}
+
+func TestUnfurlFailures(t *testing.T) {
+	server := destinations()
+	defer server.Close()
+
+	fetcher := unfurl.New(local)
+
+	_, err := fetcher.Unfurl(context.Background(), server.URL+"/file.pdf")
+	require.ErrorIs(t, err, unfurl.ErrNotHTML)
+
+	_, err = fetcher.Unfurl(context.Background(), server.URL+"/gone")
+	require.NotNil(t, err)
+}
+
+func TestUnfurlRefusesInternalAddresses(t *testing.T) {
+	server := destinations()
+	defer server.Close()
+
+	fetcher := unfurl.New(nil)
+
+	for _, destination := range []string{
+		server.URL + "/article",
+		strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/article",
+		server.URL + "/moved",
+	} {
+		_, err := fetcher.Unfurl(context.Background(), destination)
+		require.ErrorIs(t, err, egress.ErrForbidden, destination)
+	}
+}
@@ -0,0 +1,201 @@
+//go:generate mockgen -source=unfurl.go -destination unfurl_mock.go -package unfurl
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/egress"
+	"github.com/sri-shubham/snipr/storage/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	defaultTimeout  = 3 * time.Second
+	defaultMaxBytes = 512 << 10
+
+	userAgent = "snipr-unfurl"
+	// maxFieldLength bounds the runes kept of titles and descriptions.
+	maxFieldLength = 300
+)
+
+var ErrNotHTML = errors.New("destination is not an HTML page")
+
+// Unfurler fetches metadata describing link destinations.
+type Unfurler interface {
+	// Unfurl fetches destination and returns its title, description,
+	// favicon and OpenGraph image.
+	Unfurl(ctx context.Context, destination string) (*models.LinkMetadata, error)
+}
+
+// Fetcher unfurls destinations over HTTP, reading only their head.
+type Fetcher struct {
+	conf   config.UnfurlConfig
+	client *http.Client
+}
+
+func New(conf *config.UnfurlConfig) *Fetcher {
+	unfurlConf := config.UnfurlConfig{}
+	if conf != nil {
+		unfurlConf = *conf
+	}
+	if unfurlConf.Timeout <= 0 {
+		unfurlConf.Timeout = defaultTimeout
+	}
+	if unfurlConf.MaxBytes <= 0 {
+		unfurlConf.MaxBytes = defaultMaxBytes
+	}
+
+	return &Fetcher{
+		conf:   unfurlConf,
+		client: egress.NewClient(unfurlConf.Timeout, unfurlConf.AllowPrivateNetworks),
+	}
+}
+
+// Unfurl implements Unfurler. Relative URLs are resolved against the page
+// the destination redirected to, and pages without a usable favicon link
+// get /favicon.ico. Destinations, and pages they redirect to, on internal
+// addresses fail with egress.ErrForbidden.
+func (f *Fetcher) Unfurl(ctx context.Context, destination string) (*models.LinkMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, destination, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("destination answered %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.conf.MaxBytes), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	page := parse(body)
+	base := resp.Request.URL
+
+	metadata := &models.LinkMetadata{
+		Title:       clip(first(page.meta["og:title"], page.meta["twitter:title"], page.title)),
+		Description: clip(first(page.meta["og:description"], page.meta["twitter:description"], page.meta["description"])),
+		Image:       resolve(base, first(page.meta["og:image"], page.meta["og:image:url"], page.meta["twitter:image"])),
+		Favicon:     first(resolve(base, page.icon), resolve(base, "/favicon.ico")),
+		FetchedAt:   time.Now().UTC(),
+	}
+	return metadata, nil
+}
+
+// head is what parse picks out of a page.
+type head struct {
+	title string
+	meta  map[string]string
+	icon  string
+}
+
+// parse reads the head of an HTML document, stopping at the body. Only the
+// first value of every meta property is kept.
+func parse(r io.Reader) *head {
+	page := &head{meta: map[string]string{}}
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			// The end of the page, or of what was read of it
+			return page
+		case html.TextToken:
+			if inTitle && page.title == "" {
+				page.title = strings.TrimSpace(string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				return page
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			tag := atom.Lookup(name)
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+
+			switch tag {
+			case atom.Body:
+				return page
+			case atom.Title:
+				inTitle = true
+			case atom.Meta:
+				// OpenGraph uses property, everything else name
+				key := strings.ToLower(first(attrs["property"], attrs["name"]))
+				if _, ok := page.meta[key]; key != "" && !ok {
+					page.meta[key] = strings.TrimSpace(attrs["content"])
+				}
+			case atom.Link:
+				rel := strings.Fields(strings.ToLower(attrs["rel"]))
+				if page.icon == "" && slices.Contains(rel, "icon") {
+					page.icon = strings.TrimSpace(attrs["href"])
+				}
+			}
+		}
+	}
+}
+
+// resolve makes ref absolute against base. References that are not http
+// or https URLs are dropped.
+func resolve(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func clip(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxFieldLength {
+		return s
+	}
+	return string([]rune(s)[:maxFieldLength-1]) + "…"
+}
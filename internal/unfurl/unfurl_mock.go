@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: unfurl.go
+
+// Package unfurl is a generated GoMock package.
+package unfurl
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sri-shubham/snipr/storage/models"
+)
+
+// MockUnfurler is a mock of Unfurler interface.
+type MockUnfurler struct {
+	ctrl     *gomock.Controller
+	recorder *MockUnfurlerMockRecorder
+}
+
+// MockUnfurlerMockRecorder is the mock recorder for MockUnfurler.
+type MockUnfurlerMockRecorder struct {
+	mock *MockUnfurler
+}
+
+// NewMockUnfurler creates a new mock instance.
+func NewMockUnfurler(ctrl *gomock.Controller) *MockUnfurler {
+	mock := &MockUnfurler{ctrl: ctrl}
+	mock.recorder = &MockUnfurlerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUnfurler) EXPECT() *MockUnfurlerMockRecorder {
+	return m.recorder
+}
+
+// Unfurl mocks base method.
+func (m *MockUnfurler) Unfurl(ctx context.Context, destination string) (*models.LinkMetadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unfurl", ctx, destination)
+	ret0, _ := ret[0].(*models.LinkMetadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Unfurl indicates an expected call of Unfurl.
+func (mr *MockUnfurlerMockRecorder) Unfurl(ctx, destination interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unfurl", reflect.TypeOf((*MockUnfurler)(nil).Unfurl), ctx, destination)
+}
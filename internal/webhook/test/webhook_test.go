@@ -0,0 +1,228 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/egress"
+	"github.com/sri-shubham/snipr/internal/webhook"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishQueuesSubscribedEndpoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	dispatcher, err := webhook.New(storageMock, nil, slog.Default())
+	require.Nil(t, err)
+
+	storageMock.EXPECT().ListEndpoints(gomock.Any()).Return([]*models.WebhookEndpoint{
+		{ID: 1, URL: "https://a.example.com/hook"},
+		{ID: 2, URL: "https://b.example.com/hook", Events: []string{models.WebhookEventLinkDeleted}},
+		{ID: 3, URL: "https://c.example.com/hook", Events: []string{models.WebhookEventLinkCreated}},
+	}, nil)
+	storageMock.EXPECT().EnqueueDeliveries(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, deliveries []*models.WebhookDelivery) error {
+		require.Len(t, deliveries, 2)
+		require.Equal(t, int64(1), deliveries[0].EndpointID)
+		require.Equal(t, int64(3), deliveries[1].EndpointID)
+		for _, delivery := range deliveries {
+			require.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+			require.Equal(t, models.WebhookEventLinkCreated, delivery.Event)
+		}
+
+		payload := map[string]any{}
+		require.Nil(t, json.Unmarshal(deliveries[0].Payload, &payload))
+		require.Equal(t, models.WebhookEventLinkCreated, payload["type"])
+		require.NotEmpty(t, payload["id"])
+		require.Equal(t, map[string]any{"short_url": "https://snipr.com/sniper"}, payload["data"])
+		return nil
+	})
+
+	dispatcher.Publish(context.Background(), models.WebhookEventLinkCreated, map[string]string{"short_url": "https://snipr.com/sniper"})
+}
+
+func TestEnqueueReturnsStorageErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	dispatcher, err := webhook.New(storageMock, nil, slog.Default())
+	require.Nil(t, err)
+
+	queueErr := errors.New("connection refused")
+	storageMock.EXPECT().ListEndpoints(gomock.Any()).Return([]*models.WebhookEndpoint{{ID: 1, URL: "https://a.example.com/hook"}}, nil)
+	storageMock.EXPECT().EnqueueDeliveries(gomock.Any(), gomock.Any()).Return(queueErr)
+
+	err = dispatcher.Enqueue(context.Background(), models.WebhookEventLinkExpired, map[string]string{"short_url": "https://snipr.com/sniper"})
+	require.ErrorIs(t, err, queueErr)
+}
+
+func TestDeliverSignsRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+		require.Nil(t, err)
+		require.Equal(t, "sha256="+webhook.Sign("secret", timestamp, body), r.Header.Get(webhook.SignatureHeader))
+		require.JSONEq(t, `{"type": "link.deleted"}`, string(body))
+		received <- r
+	}))
+	defer receiver.Close()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	dispatcher, err := webhook.New(storageMock, &config.WebhookConfig{Timeout: time.Second, AllowPrivateNetworks: true}, slog.Default())
+	require.Nil(t, err)
+
+	now := time.Now()
+	storageMock.EXPECT().ClaimDeliveries(gomock.Any(), now, 100, 2*time.Second).Return([]*models.WebhookDelivery{{
+		ID:         7,
+		EndpointID: 1,
+		Event:      models.WebhookEventLinkDeleted,
+		Payload:    json.RawMessage(`{"type": "link.deleted"}`),
+		Status:     models.WebhookDeliveryPending,
+	}}, nil)
+	storageMock.EXPECT().ListEndpoints(gomock.Any()).Return([]*models.WebhookEndpoint{
+		{ID: 1, URL: receiver.URL, Secret: "secret"},
+	}, nil)
+	storageMock.EXPECT().UpdateDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, delivery *models.WebhookDelivery) error {
+		require.Equal(t, models.WebhookDeliveryDelivered, delivery.Status)
+		require.Equal(t, 1, delivery.Attempts)
+		require.Equal(t, http.StatusOK, delivery.LastStatusCode)
+		require.NotNil(t, delivery.DeliveredAt)
+		return nil
+	})
+
+	attempted, err := dispatcher.Deliver(context.Background(), now)
+	require.Nil(t, err)
+	require.Equal(t, 1, attempted)
+
+	r := <-received
+	require.Equal(t, models.WebhookEventLinkDeleted, r.Header.Get(webhook.EventHeader))
+	require.Equal(t, "7", r.Header.Get(webhook.DeliveryHeader))
+	require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+}
+
+func TestDeliverRetriesThenDeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	dispatcher, err := webhook.New(storageMock, &config.WebhookConfig{
+		MaxAttempts: 3,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  90 * time.Second,
+
+		AllowPrivateNetworks: true,
+	}, slog.Default())
+	require.Nil(t, err)
+
+	storageMock.EXPECT().ListEndpoints(gomock.Any()).Return([]*models.WebhookEndpoint{
+		{ID: 1, URL: receiver.URL, Secret: "secret"},
+	}, nil).AnyTimes()
+
+	delivery := &models.WebhookDelivery{ID: 7, EndpointID: 1, Payload: json.RawMessage(`{}`), Status: models.WebhookDeliveryPending}
+	for _, tc := range []struct {
+		status  string
+		backoff time.Duration
+	}{
+		{models.WebhookDeliveryPending, time.Minute},
+		{models.WebhookDeliveryPending, 90 * time.Second},
+		{models.WebhookDeliveryDead, 0},
+	} {
+		storageMock.EXPECT().ClaimDeliveries(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]*models.WebhookDelivery{delivery}, nil)
+		storageMock.EXPECT().UpdateDelivery(gomock.Any(), delivery).Return(nil)
+
+		before := time.Now()
+		_, err := dispatcher.Deliver(context.Background(), before)
+		require.Nil(t, err)
+
+		require.Equal(t, tc.status, delivery.Status)
+		require.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+		require.Contains(t, delivery.LastError, "503")
+		if tc.backoff > 0 {
+			require.WithinDuration(t, before.Add(tc.backoff), delivery.NextAttempt, time.Second)
+		}
+	}
+	require.Equal(t, 3, delivery.Attempts)
+	require.Nil(t, delivery.DeliveredAt)
+}
+
+func TestDeliverRefusesInternalAddresses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("delivered to a loopback address")
+	}))
+	defer receiver.Close()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	dispatcher, err := webhook.New(storageMock, nil, slog.Default())
+	require.Nil(t, err)
+
+	delivery := &models.WebhookDelivery{ID: 7, EndpointID: 1, Payload: json.RawMessage(`{}`), Status: models.WebhookDeliveryPending}
+	storageMock.EXPECT().ClaimDeliveries(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]*models.WebhookDelivery{delivery}, nil)
+	storageMock.EXPECT().ListEndpoints(gomock.Any()).Return([]*models.WebhookEndpoint{
+		{ID: 1, URL: receiver.URL, Secret: "secret"},
+	}, nil)
+	storageMock.EXPECT().UpdateDelivery(gomock.Any(), delivery).Return(nil)
+
+	_, err = dispatcher.Deliver(context.Background(), time.Now())
+	require.Nil(t, err)
+	require.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	require.Contains(t, delivery.LastError, egress.ErrForbidden.Error())
+}
+
+func TestClickLimitThreshold(t *testing.T) {
+	dispatcher, err := webhook.New(nil, nil, slog.Default())
+	require.Nil(t, err)
+	require.Equal(t, int64(1), dispatcher.ClickLimitThreshold(1))
+	require.Equal(t, int64(10), dispatcher.ClickLimitThreshold(10))
+
+	dispatcher, err = webhook.New(nil, &config.WebhookConfig{ClickLimitPercent: 80}, slog.Default())
+	require.Nil(t, err)
+	require.Equal(t, int64(8), dispatcher.ClickLimitThreshold(10))
+	require.Equal(t, int64(3), dispatcher.ClickLimitThreshold(3))
+	require.Equal(t, int64(1), dispatcher.ClickLimitThreshold(1))
+}
+
+func TestDispatcherCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	storageMock.EXPECT().ClaimDeliveries(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	dispatcher, err := webhook.New(storageMock, &config.WebhookConfig{PollInterval: time.Hour}, slog.Default())
+	require.Nil(t, err)
+	require.ErrorIs(t, dispatcher.Check(context.Background()), webhook.ErrDispatcherStopped)
+
+	dispatcher.Start()
+	require.Nil(t, dispatcher.Check(context.Background()))
+
+	require.Nil(t, dispatcher.Close(context.Background()))
+	require.ErrorIs(t, dispatcher.Check(context.Background()), webhook.ErrDispatcherStopped)
+}
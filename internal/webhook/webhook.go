@@ -0,0 +1,397 @@
+//go:generate mockgen -source=webhook.go -destination webhook_mock.go -package webhook
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/egress"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultBatchSize    = 100
+	defaultTimeout      = 10 * time.Second
+	defaultMaxAttempts  = 8
+	defaultMinBackoff   = 30 * time.Second
+	defaultMaxBackoff   = 6 * time.Hour
+	// defaultClickLimitPercent sends link.click_limit_reached with the
+	// last click.
+	defaultClickLimitPercent = 100
+
+	// maxErrorLength bounds the error kept of a failed attempt.
+	maxErrorLength = 512
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256
+// of the timestamp, a dot and the body, keyed with the endpoint secret.
+const (
+	EventHeader     = "X-Snipr-Event"
+	DeliveryHeader  = "X-Snipr-Delivery"
+	TimestampHeader = "X-Snipr-Timestamp"
+	SignatureHeader = "X-Snipr-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+var meter = otel.Meter("github.com/sri-shubham/snipr/internal/webhook")
+
+// ErrDispatcherStopped is reported by Check once the dispatcher stopped.
+var ErrDispatcherStopped = errors.New("webhook dispatcher is not running")
+
+// Publisher sends link events to webhook endpoints.
+type Publisher interface {
+	// Publish queues event, carrying data, for every endpoint subscribed
+	// to it. Failures are logged.
+	Publish(ctx context.Context, event string, data any)
+	// Enqueue is Publish returning failures instead. Called with the
+	// context of storage.Transactor.RunInTx, the deliveries are queued in
+	// that transaction.
+	Enqueue(ctx context.Context, event string, data any) error
+	// ClickLimitThreshold returns the click count at which a link limited
+	// to maxClicks has reached its limit for link.click_limit_reached.
+	ClickLimitThreshold(maxClicks int64) int64
+}
+
+// Dispatcher queues events in storage and delivers them from a background
+// goroutine, retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	storage  storage.WebhookStorage
+	conf     config.WebhookConfig
+	client   *http.Client
+	logger   *slog.Logger
+	attempts metric.Int64Counter
+
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	running atomic.Bool
+}
+
+func New(storage storage.WebhookStorage, conf *config.WebhookConfig, logger *slog.Logger) (*Dispatcher, error) {
+	webhookConf := config.WebhookConfig{}
+	if conf != nil {
+		webhookConf = *conf
+	}
+	if webhookConf.PollInterval <= 0 {
+		webhookConf.PollInterval = defaultPollInterval
+	}
+	if webhookConf.BatchSize <= 0 {
+		webhookConf.BatchSize = defaultBatchSize
+	}
+	if webhookConf.Timeout <= 0 {
+		webhookConf.Timeout = defaultTimeout
+	}
+	if webhookConf.MaxAttempts <= 0 {
+		webhookConf.MaxAttempts = defaultMaxAttempts
+	}
+	if webhookConf.MinBackoff <= 0 {
+		webhookConf.MinBackoff = defaultMinBackoff
+	}
+	if webhookConf.MaxBackoff < webhookConf.MinBackoff {
+		webhookConf.MaxBackoff = max(defaultMaxBackoff, webhookConf.MinBackoff)
+	}
+	if webhookConf.ClickLimitPercent <= 0 || webhookConf.ClickLimitPercent > 100 {
+		webhookConf.ClickLimitPercent = defaultClickLimitPercent
+	}
+
+	attempts, err := meter.Int64Counter("snipr.webhook.attempts",
+		metric.WithDescription("Webhook delivery attempts by outcome"),
+		metric.WithUnit("{attempt}"))
+	if err != nil {
+		return nil, err
+	}
+
+	client := egress.NewClient(webhookConf.Timeout, webhookConf.AllowPrivateNetworks)
+	// Redirects are failures; endpoints must answer themselves
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &Dispatcher{
+		storage:  storage,
+		conf:     webhookConf,
+		client:   client,
+		logger:   logger,
+		attempts: attempts,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Publish implements Publisher. The event is queued even if the request
+// that caused it is cancelled meanwhile.
+func (d *Dispatcher) Publish(ctx context.Context, event string, data any) {
+	if err := d.Enqueue(context.WithoutCancel(ctx), event, data); err != nil {
+		d.logger.ErrorContext(ctx, "Failed to queue webhook deliveries", slog.String("event", event), slog.Any("error", err))
+	}
+}
+
+// Enqueue implements Publisher.
+func (d *Dispatcher) Enqueue(ctx context.Context, event string, data any) error {
+	endpoints, err := d.storage.ListEndpoints(ctx)
+	if err != nil {
+		return fmt.Errorf("list webhook endpoints: %w", err)
+	}
+
+	now := time.Now().UTC()
+	payload, err := json.Marshal(&models.WebhookPayload{
+		ID:   newID(),
+		Type: event,
+		Time: now,
+		Data: data,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+
+	deliveries := []*models.WebhookDelivery{}
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribed(event) {
+			continue
+		}
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			EndpointID:  endpoint.ID,
+			Event:       event,
+			Payload:     payload,
+			Status:      models.WebhookDeliveryPending,
+			NextAttempt: now,
+			CreatedAt:   now,
+		})
+	}
+
+	return d.storage.EnqueueDeliveries(ctx, deliveries)
+}
+
+// ClickLimitThreshold implements Publisher. It is ClickLimitPercent of
+// maxClicks, rounded up.
+func (d *Dispatcher) ClickLimitThreshold(maxClicks int64) int64 {
+	return max(1, (maxClicks*int64(d.conf.ClickLimitPercent)+99)/100)
+}
+
+// Start launches the delivery goroutine.
+func (d *Dispatcher) Start() {
+	d.running.Store(true)
+	go d.run()
+}
+
+// Check reports whether the delivery goroutine is running. It can be
+// registered as a health check.
+func (d *Dispatcher) Check(context.Context) error {
+	if !d.running.Load() {
+		return ErrDispatcherStopped
+	}
+	return nil
+}
+
+// Close stops delivering after the current batch and waits for the
+// goroutine to finish or ctx to expire. Undelivered events stay queued.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.once.Do(func() { close(d.stop) })
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Deliver attempts one batch of deliveries due at now and returns how many
+// it attempted.
+func (d *Dispatcher) Deliver(ctx context.Context, now time.Time) (int, error) {
+	// Claimed deliveries are retried by any instance once the lease is up,
+	// should this one die while attempting them
+	lease := 2 * d.conf.Timeout
+	deliveries, err := d.storage.ClaimDeliveries(ctx, now, d.conf.BatchSize, lease)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	endpoints, err := d.storage.ListEndpoints(ctx)
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[int64]*models.WebhookEndpoint, len(endpoints))
+	for _, endpoint := range endpoints {
+		byID[endpoint.ID] = endpoint
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.attempt(ctx, byID[delivery.EndpointID], delivery)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// attempt posts delivery to endpoint once and stores the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) {
+	statusCode, err := 0, errors.New("endpoint was deleted")
+	if endpoint != nil {
+		statusCode, err = d.post(ctx, endpoint, delivery)
+	}
+	if ctx.Err() != nil {
+		// Shutting down; the delivery is retried once its lease is up
+		return
+	}
+
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case endpoint == nil || delivery.Attempts >= d.conf.MaxAttempts:
+		delivery.Status = models.WebhookDeliveryDead
+		delivery.LastError = truncate(err.Error())
+	default:
+		delivery.NextAttempt = now.Add(d.backoff(delivery.Attempts))
+		delivery.LastError = truncate(err.Error())
+	}
+	d.attempts.Add(ctx, 1, metric.WithAttributes(attribute.String("status", delivery.Status)))
+
+	if err != nil {
+		d.logger.WarnContext(ctx, "Webhook delivery failed",
+			slog.Int64("delivery", delivery.ID),
+			slog.Int64("endpoint", delivery.EndpointID),
+			slog.Int("attempts", delivery.Attempts),
+			slog.String("status", delivery.Status),
+			slog.Any("error", err))
+	}
+
+	if err := d.storage.UpdateDelivery(ctx, delivery); err != nil {
+		d.logger.ErrorContext(ctx, "Failed to update webhook delivery", slog.Int64("delivery", delivery.ID), slog.Any("error", err))
+	}
+}
+
+// post sends delivery to endpoint and returns the response status code. Any
+// status outside 2xx is an error.
+func (d *Dispatcher) post(ctx context.Context, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "snipr-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, signaturePrefix+Sign(endpoint.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait after the given number of failed attempts, doubling
+// from MinBackoff up to MaxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.conf.MinBackoff
+	for i := 1; i < attempts && wait < d.conf.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.conf.MaxBackoff)
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	defer d.running.Store(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(d.conf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while batches come back full
+		for ctx.Err() == nil {
+			attempted, err := d.Deliver(ctx, time.Now())
+			if err != nil && ctx.Err() == nil {
+				d.logger.Error("Failed to deliver webhooks", slog.Any("error", err))
+			}
+			if err != nil || attempted < d.conf.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 signature of a delivery.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret generates a random signing secret.
+func NewSecret() string {
+	var b [32]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		return s[:maxErrorLength]
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webhook.go
+
+// Package webhook is a generated GoMock package.
+package webhook
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockPublisher is a mock of Publisher interface.
+type MockPublisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockPublisherMockRecorder
+}
+
+// MockPublisherMockRecorder is the mock recorder for MockPublisher.
+type MockPublisherMockRecorder struct {
+	mock *MockPublisher
+}
+
+// NewMockPublisher creates a new mock instance.
+func NewMockPublisher(ctrl *gomock.Controller) *MockPublisher {
+	mock := &MockPublisher{ctrl: ctrl}
+	mock.recorder = &MockPublisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPublisher) EXPECT() *MockPublisherMockRecorder {
+	return m.recorder
+}
+
+// ClickLimitThreshold mocks base method.
+func (m *MockPublisher) ClickLimitThreshold(maxClicks int64) int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClickLimitThreshold", maxClicks)
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// ClickLimitThreshold indicates an expected call of ClickLimitThreshold.
+func (mr *MockPublisherMockRecorder) ClickLimitThreshold(maxClicks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClickLimitThreshold", reflect.TypeOf((*MockPublisher)(nil).ClickLimitThreshold), maxClicks)
+}
+
+// Enqueue mocks base method.
+func (m *MockPublisher) Enqueue(ctx context.Context, event string, data any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", ctx, event, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockPublisherMockRecorder) Enqueue(ctx, event, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockPublisher)(nil).Enqueue), ctx, event, data)
+}
+
+// Publish mocks base method.
+func (m *MockPublisher) Publish(ctx context.Context, event string, data any) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Publish", ctx, event, data)
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockPublisherMockRecorder) Publish(ctx, event, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockPublisher)(nil).Publish), ctx, event, data)
+}
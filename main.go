@@ -1,11 +1,37 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// Availability windows use IANA time zones; embed them so minimal
+	// images without zoneinfo work too.
+	_ "time/tzdata"
 
+	"github.com/sri-shubham/snipr/internal/access"
+	"github.com/sri-shubham/snipr/internal/analytics"
+	"github.com/sri-shubham/snipr/internal/audit"
+	"github.com/sri-shubham/snipr/internal/auth"
+	"github.com/sri-shubham/snipr/internal/certs"
+	"github.com/sri-shubham/snipr/internal/clientip"
 	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/geoip"
+	"github.com/sri-shubham/snipr/internal/health"
+	"github.com/sri-shubham/snipr/internal/logging"
+	"github.com/sri-shubham/snipr/internal/prober"
+	"github.com/sri-shubham/snipr/internal/redirect"
+	"github.com/sri-shubham/snipr/internal/server"
 	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/internal/sweeper"
+	"github.com/sri-shubham/snipr/internal/telemetry"
+	"github.com/sri-shubham/snipr/internal/unfurl"
+	"github.com/sri-shubham/snipr/internal/webhook"
 	"github.com/sri-shubham/snipr/migrations"
 	"github.com/sri-shubham/snipr/service"
 	"github.com/sri-shubham/snipr/storage"
@@ -14,52 +40,295 @@ import (
 )
 
 func main() {
-	log.Println("Loading config")
 	config, err := config.ParseConfig("config/config.yml")
 	if err != nil {
 		log.Fatalf("Failed to read config: %s", err)
 	}
 
-	log.Println("opening conn to db")
-	pgDB, err := postgres.GetDB(config.Postgres)
+	logger, err := logging.New(config.Log, os.Stdout)
 	if err != nil {
-		log.Fatalf("Failed to init postgres connection: %s", err)
+		log.Fatalf("Failed to init logger: %s", err)
 	}
+	slog.SetDefault(logger)
 
-	log.Println("opening conn to redis")
-	redis, err := rediscache.GetDB(config.Redis)
+	logger.Info("Setting up telemetry")
+	shutdownTelemetry, err := telemetry.Setup(context.Background(), config.Telemetry)
 	if err != nil {
-		log.Fatalf("Failed to init redis connection: %s", err)
+		fatal(logger, "Failed to init telemetry", err)
 	}
 
-	log.Println("Running Migrations")
+	logger.Info("Opening conn to db")
+	pgDB, err := postgres.GetDB(config.Postgres, logger)
+	if err != nil {
+		fatal(logger, "Failed to init postgres connection", err)
+	}
+
+	logger.Info("Opening conn to redis")
+	redis, err := rediscache.GetDB(config.Redis, logger)
+	if err != nil {
+		fatal(logger, "Failed to init redis connection", err)
+	}
+
+	logger.Info("Running migrations")
 	err = migrations.MigrateDB(pgDB)
 	if err != nil {
-		log.Fatalf("Failed to init redis connection: %s", err)
+		fatal(logger, "Failed to run migrations", err)
 	}
 
-	_ = redis
+	checker := health.NewChecker(0)
+	checker.Register("postgres", pgDB.PingContext)
+	checker.Register("redis", func(ctx context.Context) error {
+		return redis.Ping(ctx).Err()
+	})
+	checker.Register("migrations", func(ctx context.Context) error {
+		return migrations.Check(ctx, pgDB)
+	})
+
+	postgresURLStorage := storage.NewPGShortenedURLStorage(pgDB, logger)
+	postgresURLReport := storage.NewPGURLReport(pgDB, logger)
+	urlArchive := storage.NewPGURLArchive(pgDB, logger)
+
+	var trustedProxies []string
+	if config.Server != nil {
+		trustedProxies = config.Server.TrustedProxies
+	}
+	clientIPs, err := clientip.New(trustedProxies)
+	if err != nil {
+		fatal(logger, "Failed to parse trusted proxies", err)
+	}
 
-	postgresURLStorage := storage.NewPGShortenedURLStorage(pgDB)
-	postgresURLReport := storage.NewPGURLReport(pgDB)
+	authenticator, err := auth.New(config.Auth)
+	if err != nil {
+		fatal(logger, "Failed to set up api tokens", err)
+	}
+	if !authenticator.Enabled() {
+		logger.Warn("No api tokens configured, the management api refuses every request")
+	}
 
+	transactor := storage.NewPGTransactor(pgDB)
+	auditStorage := storage.NewPGAuditStorage(pgDB, logger)
+	auditLog, err := audit.Open(config.Audit, auditStorage, clientIPs, logger)
+	if err != nil {
+		fatal(logger, "Failed to open audit log", err)
+	}
+
+	var geo geoip.Resolver
+	if config.GeoIP != nil && config.GeoIP.DatabasePath != "" {
+		logger.Info("Opening GeoIP database", slog.String("path", config.GeoIP.DatabasePath))
+		geo, err = geoip.Open(config.GeoIP.DatabasePath)
+		if err != nil {
+			fatal(logger, "Failed to open GeoIP database", err)
+		}
+	}
+
+	clickStorage := storage.NewPGClickStorage(pgDB, logger)
+	clickRecorder := analytics.NewAsyncRecorder(clickStorage, config.Analytics, logger)
+	clickRecorder.Start()
+	checker.Register("analytics", clickRecorder.Check)
+
+	webhookStorage := storage.NewPGWebhookStorage(pgDB, logger)
+	var webhooks webhook.Publisher
+	var dispatcher *webhook.Dispatcher
+	if config.Webhooks != nil && config.Webhooks.Enabled {
+		dispatcher, err = webhook.New(webhookStorage, config.Webhooks, logger)
+		if err != nil {
+			fatal(logger, "Failed to set up webhooks", err)
+		}
+		dispatcher.Start()
+		checker.Register("webhooks", dispatcher.Check)
+		webhooks = dispatcher
+	}
+
+	var expirySweeper *sweeper.Sweeper
+	if config.Sweeper != nil && config.Sweeper.Enabled {
+		expirySweeper, err = sweeper.New(urlArchive, transactor, webhooks, config.Sweeper, logger)
+		if err != nil {
+			fatal(logger, "Failed to set up expiry sweeper", err)
+		}
+		expirySweeper.Start()
+		checker.Register("sweeper", expirySweeper.Check)
+	}
+
+	probeStorage := storage.NewPGProbeStorage(pgDB, logger)
+	var linkProber *prober.Prober
+	if config.Prober != nil && config.Prober.Enabled {
+		linkProber, err = prober.New(probeStorage, config.Prober, logger)
+		if err != nil {
+			fatal(logger, "Failed to set up link prober", err)
+		}
+		linkProber.Start()
+		checker.Register("prober", linkProber.Check)
+	}
+
+	guard, err := access.NewGuard(config.Passwords)
+	if err != nil {
+		fatal(logger, "Failed to set up password protection", err)
+	}
+	if config.Passwords == nil || config.Passwords.CookieSecret == "" {
+		logger.Warn("No password cookie secret configured, access cookies will not survive restarts")
+	}
+
+	pages, err := service.LoadPages(config.Pages)
+	if err != nil {
+		fatal(logger, "Failed to load pages", err)
+	}
+
+	qrLogo, err := service.LoadQRLogo(config.QR)
+	if err != nil {
+		fatal(logger, "Failed to load QR code logo", err)
+	}
+
+	var unfurler unfurl.Unfurler
+	if config.Unfurl != nil && config.Unfurl.Enabled {
+		unfurler = unfurl.New(config.Unfurl)
+	}
+
+	var quarantine time.Duration
+	if config.Archive != nil {
+		quarantine = config.Archive.Quarantine
+	}
+	shortener := shorten.NewShortener(
+		config.Shortener.MinLength,
+		config.Shortener.CustomMinLength,
+		config.Shortener.CustomMaxLength,
+		config.Host,
+		postgresURLStorage,
+		urlArchive,
+		quarantine,
+		logger,
+	)
 	urlShorteningService := service.NewShortenURLService(
-		shorten.NewShortener(
-			config.Shortener.MinLength,
-			config.Shortener.CustomMinLength,
-			config.Shortener.CustomMaxLength,
-			config.Host,
-			postgresURLStorage,
-		),
-		postgresURLReport,
+		service.ShortenURLDeps{
+			Shortener:  shortener,
+			Report:     postgresURLReport,
+			Storage:    postgresURLStorage,
+			Archive:    urlArchive,
+			Classifier: redirect.NewClassifier(clientIPs, geo),
+			Clicks:     clickRecorder,
+			Guard:      guard,
+			Pages:      pages,
+			Audit:      auditLog,
+			Tx:         transactor,
+			Webhooks:   webhooks,
+			Unfurler:   unfurler,
+		},
+		config.Redirect,
+		logger,
+	)
+	linkService := service.NewLinkService(
+		shortener,
 		postgresURLStorage,
+		clickStorage,
+		urlArchive,
+		storage.NewPGLinkHistory(pgDB, logger),
+		transactor,
+		auditLog,
+		webhooks,
+		logger,
 	)
+	auditService := service.NewAuditService(shortener, auditStorage, logger)
+	webhookService := service.NewWebhookService(webhookStorage, transactor, auditLog, config.Webhooks, logger)
+	probeService := service.NewProbeService(probeStorage, logger)
+	qrService := service.NewQRService(shortener, postgresURLStorage, qrLogo, config.QR, logger)
+	openAPIService, err := service.NewOpenAPIService(logger)
+	if err != nil {
+		fatal(logger, "Failed to load OpenAPI document", err)
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /shorten", urlShorteningService.Shorten)
-	mux.HandleFunc("POST /shorten/custom", urlShorteningService.ShortenCustom)
-	mux.HandleFunc("GET /report/{count}", urlShorteningService.DomainReport)
-	mux.HandleFunc("GET /{code}", urlShorteningService.Redirect)
-	log.Println("Starting server...")
-	log.Fatal(http.ListenAndServe(":8080", mux))
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, telemetry.Route(pattern, h))
+	}
+	// The management API is only open to holders of an api token
+	handleAPI := func(pattern string, h http.HandlerFunc) {
+		handle(pattern, service.RequireIdentity(logger, h))
+	}
+	handle("POST /shorten", urlShorteningService.Shorten)
+	handle("POST /shorten/custom", urlShorteningService.ShortenCustom)
+	handle("GET /report/{count}", urlShorteningService.DomainReport)
+	handle("GET /{code}", urlShorteningService.Redirect)
+	// GET /{code}/qr would clash with GET /report/{count}, so QR codes are
+	// picked out of the trailing paths instead
+	handle("GET /{code}/{rest...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("rest") == "qr" {
+			qrService.PublicCode(w, r)
+			return
+		}
+		urlShorteningService.Redirect(w, r)
+	})
+	handle("POST /{code}", urlShorteningService.Unlock)
+	handle("POST /{code}/{rest...}", urlShorteningService.Unlock)
+	handleAPI("PATCH /api/links/{code}", linkService.UpdateLink)
+	handleAPI("DELETE /api/links/{code}", linkService.DeleteLink)
+	handleAPI("GET /api/links/broken", probeService.Broken)
+	handleAPI("GET /api/links/{code}/stats", linkService.Stats)
+	handleAPI("GET /api/links/{code}/history", linkService.History)
+	handleAPI("GET /api/links/{code}/qr", qrService.Code)
+	handleAPI("POST /api/links/{code}/rollback", linkService.Rollback)
+	handleAPI("GET /api/archive", linkService.Archived)
+	handleAPI("GET /api/audit", auditService.Events)
+	handleAPI("POST /api/webhooks", webhookService.CreateEndpoint)
+	handleAPI("GET /api/webhooks", webhookService.ListEndpoints)
+	handleAPI("DELETE /api/webhooks/{id}", webhookService.DeleteEndpoint)
+	handleAPI("GET /api/webhooks/{id}/deliveries", webhookService.Deliveries)
+
+	healthService := service.NewHealthService(checker, logger)
+	mux.HandleFunc("GET /healthz", healthService.Liveness)
+	mux.HandleFunc("GET /readyz", healthService.Readiness)
+	mux.HandleFunc("GET /openapi.json", openAPIService.Document)
+
+	srv := server.New(config.Server, config.Port, telemetry.Middleware(logging.Middleware(logger, authenticator.Middleware(openAPIService.Validate(mux)))), checker, logger)
+	if expirySweeper != nil {
+		srv.OnShutdown("sweeper", expirySweeper.Close)
+	}
+	if linkProber != nil {
+		srv.OnShutdown("prober", linkProber.Close)
+	}
+	if dispatcher != nil {
+		srv.OnShutdown("webhooks", dispatcher.Close)
+	}
+	srv.OnShutdown("analytics", clickRecorder.Close)
+	srv.OnShutdown("audit", auditLog.Close)
+	if geo != nil {
+		srv.OnShutdown("geoip", func(context.Context) error { return geo.Close() })
+	}
+	srv.OnShutdown("postgres", func(context.Context) error { return pgDB.Close() })
+	srv.OnShutdown("redis", func(context.Context) error { return redis.Close() })
+	// Flushed last so spans and metrics recorded while shutting down are kept
+	srv.OnShutdown("telemetry", shutdownTelemetry)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if config.TLS != nil && config.TLS.Enabled {
+		defaultDomain := config.Host
+		if host, _, err := net.SplitHostPort(config.Host); err == nil {
+			defaultDomain = host
+		}
+
+		tlsConfig, httpHandler, err := certs.Setup(ctx, config.TLS, config.Port, defaultDomain, logger)
+		if err != nil {
+			fatal(logger, "Failed to set up tls", err)
+		}
+		srv.HTTP.TLSConfig = tlsConfig
+
+		if httpHandler != nil {
+			httpPort := config.TLS.HTTPPort
+			if httpPort == 0 {
+				httpPort = certs.DefaultHTTPPort
+			}
+			redirectServer := server.New(config.Server, httpPort, telemetry.Middleware(logging.Middleware(logger, httpHandler)), nil, logger)
+			srv.Also(redirectServer.HTTP)
+		}
+	}
+
+	if err := srv.ListenAndServe(ctx); err != nil {
+		fatal(logger, "Server stopped with error", err)
+	}
+	logger.Info("Server stopped")
+}
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
@@ -2,23 +2,132 @@ package migrations
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
 	"github.com/sri-shubham/snipr/storage/persist/postgres"
 	"github.com/uptrace/bun"
 )
 
+// tables lists every model MigrateDB creates. Check uses it to verify the
+// schema is in place.
+var tables = []interface{}{
+	&postgres.PGShortenedURL{},
+	&postgres.PGClick{},
+	&postgres.PGArchivedURL{},
+	&postgres.PGLinkRevision{},
+	&postgres.PGAuditEvent{},
+	&postgres.PGWebhookEndpoint{},
+	&postgres.PGWebhookDelivery{},
+	&postgres.PGLinkProbe{},
+}
+
+// columns are added to tables created by earlier versions; CreateTable
+// leaves existing tables alone.
+var columns = []string{
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS redirect_type integer NOT NULL DEFAULT 0",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS passthrough jsonb",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS param_template jsonb",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS rules jsonb",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS geo jsonb",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS variants jsonb",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS password_hash text NOT NULL DEFAULT ''",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS max_clicks bigint NOT NULL DEFAULT 0",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS not_before timestamptz",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS windows jsonb",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS expiry_fallback text NOT NULL DEFAULT ''",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS clicks bigint NOT NULL DEFAULT 0",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS metadata jsonb",
+	"ALTER TABLE short_url ADD COLUMN IF NOT EXISTS expiry_reported timestamptz",
+	"ALTER TABLE click ADD COLUMN IF NOT EXISTS variant text NOT NULL DEFAULT ''",
+	"ALTER TABLE short_url_archive ADD COLUMN IF NOT EXISTS clicks bigint NOT NULL DEFAULT 0",
+	"ALTER TABLE short_url_archive ADD COLUMN IF NOT EXISTS password_hash text NOT NULL DEFAULT ''",
+}
+
 func MigrateDB(db *bun.DB) error {
-	_, err := db.NewCreateTable().IfNotExists().
-		Model(&postgres.PGShortenedURL{}).
-		Exec(context.Background())
+	for _, model := range tables {
+		_, err := db.NewCreateTable().IfNotExists().
+			Model(model).
+			Exec(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, column := range columns {
+		_, err := db.ExecContext(context.Background(), column)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := db.NewCreateIndex().Model(&postgres.PGShortenedURL{}).Index("idx_short_url_domain").Column("domain").IfNotExists().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model(&postgres.PGShortenedURL{}).Index("idx_short_url_expires").Column("expires").IfNotExists().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model(&postgres.PGClick{}).Index("idx_click_short_url").Column("short_url", "clicked_at").IfNotExists().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model(&postgres.PGArchivedURL{}).Index("idx_short_url_archive_short_url").Column("short_url", "archived_at").IfNotExists().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model(&postgres.PGLinkRevision{}).Index("idx_short_url_revision_short_url").Column("short_url", "id").IfNotExists().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model(&postgres.PGAuditEvent{}).Index("idx_audit_event_short_url").Column("short_url", "id").IfNotExists().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model(&postgres.PGAuditEvent{}).Index("idx_audit_event_time").Column("time").IfNotExists().Exec(context.Background())
 	if err != nil {
 		return err
 	}
 
-	_, err = db.NewCreateIndex().Model(&postgres.PGShortenedURL{}).Index("idx_short_url_domain").Column("domain").IfNotExists().Exec(context.Background())
+	_, err = db.NewCreateIndex().Model(&postgres.PGWebhookDelivery{}).Index("idx_webhook_delivery_due").Column("status", "next_attempt_at").IfNotExists().Exec(context.Background())
 	if err != nil {
 		return err
 	}
 
+	_, err = db.NewCreateIndex().Model(&postgres.PGWebhookDelivery{}).Index("idx_webhook_delivery_endpoint").Column("endpoint_id", "id").IfNotExists().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model(&postgres.PGLinkProbe{}).Index("idx_link_probe_broken").Column("broken", "checked_at").IfNotExists().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Check returns an error if any table created by MigrateDB is missing.
+func Check(ctx context.Context, db *bun.DB) error {
+	for _, model := range tables {
+		name := db.Table(reflect.TypeOf(model)).Name
+
+		var exists bool
+		err := db.NewRaw("SELECT to_regclass(?) IS NOT NULL", name).Scan(ctx, &exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("table %s does not exist", name)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+// AuditService queries the audit log.
+type AuditService interface {
+	Events(w http.ResponseWriter, r *http.Request)
+}
+
+type auditServiceImpl struct {
+	shortener shorten.Shortener
+	storage   storage.AuditStorage
+	logger    *slog.Logger
+}
+
+func NewAuditService(shortener shorten.Shortener, storage storage.AuditStorage, logger *slog.Logger) AuditService {
+	return &auditServiceImpl{
+		shortener: shortener,
+		storage:   storage,
+		logger:    logger,
+	}
+}
+
+type AuditResponse struct {
+	Items []*models.AuditEvent `json:"items"`
+	Count int                  `json:"count"`
+}
+
+// Events implements AuditService. Events are listed newest first and can be
+// filtered by action, actor, code and an RFC 3339 since/until time range,
+// then paged with limit and offset.
+func (s *auditServiceImpl) Events(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := page(w, r, s.logger)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := &models.AuditFilter{
+		Action: query.Get("action"),
+		Actor:  query.Get("actor"),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if code := query.Get("code"); code != "" {
+		filter.ShortURL = s.shortener.ShortURL(code)
+	}
+	for name, value := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			WriteError(w, r, s.logger, Validation(errors.New("invalid "+name)), "Since and until should be RFC 3339 times")
+			return
+		}
+		*value = parsed
+	}
+
+	items, err := s.storage.ListAuditEvents(r.Context(), filter)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to list audit events")
+		return
+	}
+
+	out, err := json.Marshal(AuditResponse{
+		Items: items,
+		Count: len(items),
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
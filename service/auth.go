@@ -0,0 +1,21 @@
+package service
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/sri-shubham/snipr/internal/auth"
+)
+
+// RequireIdentity passes requests authenticated by auth.Middleware on to
+// next and answers the others with 401.
+func RequireIdentity(logger *slog.Logger, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.Identity(r.Context()); !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="snipr"`)
+			WriteError(w, r, logger, auth.ErrUnauthenticated, "An API token is required")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -1,31 +1,73 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+
+	"github.com/sri-shubham/snipr/storage"
 )
 
+// ProblemContentType is the media type of error responses (RFC 7807).
+const ProblemContentType = "application/problem+json"
+
+// ErrorResponse is an RFC 7807 problem detail. Code is one of the ErrorCode
+// constants; Error and Message repeat Detail and the handler's summary for
+// clients of the older format.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     ErrorCode `json:"code"`
+	Error    string    `json:"error"`
+	Message  string    `json:"message"`
 }
 
 func WriteJsonResponseWithCode(w http.ResponseWriter, resp []byte, code int) {
-	w.WriteHeader(code)
-	w.Header().Set("content-type", "application/json")
-	fmt.Fprintf(w, string(resp))
+	writeResponse(w, "application/json", resp, code)
 }
 
-func WriteJsonErrorResponseWithCode(w http.ResponseWriter, err error, msg string, code int) {
+// WriteError answers r with the problem err is, as classified by
+// ErrorCodeOf, summarised by msg. Internal errors are logged to logger, and
+// hidden from the client.
+func WriteError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error, msg string) {
+	code := ErrorCodeOf(err)
+	if code == CodeInternal {
+		logger.ErrorContext(r.Context(), msg, slog.Any("error", err))
+	}
+
+	status := code.Status()
+	detail := publicError(code, err)
 	out, err := json.Marshal(&ErrorResponse{
-		Error:   err.Error(),
-		Message: msg,
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+		Error:    detail,
+		Message:  msg,
 	})
 	if err != nil {
-		log.Println("[Error] Failed to process request", err)
+		logger.ErrorContext(r.Context(), "Failed to marshal error response", slog.Any("error", err))
 	}
 
-	WriteJsonResponseWithCode(w, out, code)
+	writeResponse(w, ProblemContentType, out, status)
+}
+
+func writeResponse(w http.ResponseWriter, contentType string, resp []byte, code int) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(resp)
+}
+
+// inTx runs fn in a transaction of tx, or on its own without one.
+func inTx(ctx context.Context, tx storage.Transactor, fn func(ctx context.Context) error) error {
+	if tx == nil {
+		return fn(ctx)
+	}
+	return tx.RunInTx(ctx, fn)
 }
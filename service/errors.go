@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sri-shubham/snipr/internal/access"
+	"github.com/sri-shubham/snipr/internal/auth"
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+)
+
+// ErrorCode is the stable, machine readable kind of a failed request.
+// Clients branch on it rather than on status codes or messages.
+type ErrorCode string
+
+const (
+	CodeNotFound     ErrorCode = "not_found"
+	CodeConflict     ErrorCode = "conflict"
+	CodeValidation   ErrorCode = "validation"
+	CodeUnauthorized ErrorCode = "unauthorized"
+	CodeExpired      ErrorCode = "expired"
+	CodeRateLimited  ErrorCode = "rate_limited"
+	CodeInternal     ErrorCode = "internal"
+)
+
+// Status is the HTTP status code failures of kind c are answered with.
+func (c ErrorCode) Status() int {
+	switch c {
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeValidation:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeExpired:
+		return http.StatusGone
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error classifies an error for clients. Err is only shown to them for
+// codes other than CodeInternal.
+type Error struct {
+	Code ErrorCode
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return string(e.Code) + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound marks err as naming something that does not exist.
+func NotFound(err error) error {
+	return &Error{Code: CodeNotFound, Err: err}
+}
+
+// Validation marks err as a problem with the request itself.
+func Validation(err error) error {
+	return &Error{Code: CodeValidation, Err: err}
+}
+
+// Expired marks err as naming something that is gone for good.
+func Expired(err error) error {
+	return &Error{Code: CodeExpired, Err: err}
+}
+
+// ErrorCodeOf returns the kind of err: the code of the first Error it
+// wraps, or the kind of the well known errors of the storage, shortener,
+// access and auth packages. Anything else is CodeInternal.
+func ErrorCodeOf(err error) ErrorCode {
+	var typed *Error
+	var throttled *access.ThrottledError
+	switch {
+	case errors.As(err, &typed):
+		return typed.Code
+	case errors.Is(err, util.ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, shorten.ErrNotAvailable):
+		return CodeConflict
+	case errors.Is(err, shorten.ErrInvalidCustomCode), errors.Is(err, models.ErrInvalidLinkSettings):
+		return CodeValidation
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return CodeUnauthorized
+	case errors.Is(err, util.ErrExhausted):
+		return CodeExpired
+	case errors.As(err, &throttled):
+		return CodeRateLimited
+	default:
+		return CodeInternal
+	}
+}
+
+// publicError is what clients are told of err. Internal errors can carry
+// database and network details, so their text stays in the logs.
+func publicError(code ErrorCode, err error) string {
+	if code == CodeInternal {
+		return "internal error"
+	}
+	var typed *Error
+	if errors.As(err, &typed) {
+		return typed.Err.Error()
+	}
+	return err.Error()
+}
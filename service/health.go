@@ -0,0 +1,67 @@
+package service
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/sri-shubham/snipr/internal/health"
+)
+
+type HealthService interface {
+	Liveness(w http.ResponseWriter, r *http.Request)
+	Readiness(w http.ResponseWriter, r *http.Request)
+}
+
+type healthServiceImpl struct {
+	checker *health.Checker
+	logger  *slog.Logger
+}
+
+func NewHealthService(checker *health.Checker, logger *slog.Logger) HealthService {
+	return &healthServiceImpl{
+		checker: checker,
+		logger:  logger,
+	}
+}
+
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
+
+// Liveness implements HealthService. It only reports that the process is
+// able to serve HTTP and never touches dependencies.
+func (s *healthServiceImpl) Liveness(w http.ResponseWriter, r *http.Request) {
+	out, err := json.Marshal(&LivenessResponse{Status: health.StatusOK})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
+
+// Readiness implements HealthService. Only the status of each check is
+// served; why a check failed is logged.
+func (s *healthServiceImpl) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := s.checker.Check(r.Context())
+
+	code := http.StatusOK
+	if !report.Ready() {
+		code = http.StatusServiceUnavailable
+		s.logger.WarnContext(r.Context(), "Readiness check failing", slog.String("status", report.Status))
+		for name, result := range report.Checks {
+			if result.Error != "" {
+				s.logger.WarnContext(r.Context(), "Health check failed", slog.String("check", name), slog.String("error", result.Error))
+			}
+		}
+	}
+
+	out, err := json.Marshal(report)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, code)
+}
@@ -0,0 +1,487 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/audit"
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/internal/webhook"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+)
+
+// LinkService manages existing links.
+type LinkService interface {
+	UpdateLink(w http.ResponseWriter, r *http.Request)
+	DeleteLink(w http.ResponseWriter, r *http.Request)
+	Stats(w http.ResponseWriter, r *http.Request)
+	History(w http.ResponseWriter, r *http.Request)
+	Rollback(w http.ResponseWriter, r *http.Request)
+	Archived(w http.ResponseWriter, r *http.Request)
+}
+
+type linkServiceImpl struct {
+	shortener shorten.Shortener
+	storage   storage.URLStorage
+	clicks    storage.ClickStorage
+	archive   storage.URLArchive
+	history   storage.LinkHistory
+	tx        storage.Transactor
+	audit     *audit.Log
+	webhooks  webhook.Publisher
+	logger    *slog.Logger
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+func NewLinkService(
+	shortener shorten.Shortener,
+	storage storage.URLStorage,
+	clicks storage.ClickStorage,
+	archive storage.URLArchive,
+	history storage.LinkHistory,
+	tx storage.Transactor,
+	auditLog *audit.Log,
+	webhooks webhook.Publisher,
+	logger *slog.Logger,
+) LinkService {
+	return &linkServiceImpl{
+		shortener: shortener,
+		storage:   storage,
+		clicks:    clicks,
+		archive:   archive,
+		history:   history,
+		tx:        tx,
+		audit:     auditLog,
+		webhooks:  webhooks,
+		logger:    logger,
+	}
+}
+
+// UpdateLinkRequest is decoded on top of the current link settings, so
+// fields missing from the request keep their value. Fields that are set
+// replace the current value as a whole, lists, maps and objects such as
+// rules, geo and passthrough included, and null clears them.
+type UpdateLinkRequest struct {
+	// URL and Expires replace the destination and expiry when set.
+	URL     string     `json:"url,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	models.LinkSettings
+	// RemovePassword makes a password protected link public again.
+	RemovePassword bool `json:"remove_password"`
+}
+
+// decodeUpdate decodes the JSON in body onto req. encoding/json merges
+// into maps, slice elements and pointers that are already set, so the
+// request is decoded on its own and every setting it names replaces the
+// current one as a whole.
+func decodeUpdate(body io.Reader, req *UpdateLinkRequest) error {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	update := UpdateLinkRequest{}
+	if err := json.Unmarshal(raw, &update); err != nil {
+		return err
+	}
+
+	current := reflect.ValueOf(&req.LinkSettings).Elem()
+	changed := reflect.ValueOf(&update.LinkSettings).Elem()
+	for i := range current.NumField() {
+		field := current.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := fields[name]; ok {
+			current.Field(i).Set(changed.Field(i))
+		}
+	}
+	req.URL = update.URL
+	req.Expires = update.Expires
+	req.RemovePassword = update.RemovePassword
+	return nil
+}
+
+// UpdateLink implements LinkService. Every change is recorded as a
+// revision of the link.
+func (s *linkServiceImpl) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	link, ok := s.getLink(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	old := models.NewLinkState(link, now)
+
+	requestBody := UpdateLinkRequest{LinkSettings: link.LinkSettings}
+	if err := decodeUpdate(r.Body, &requestBody); err != nil {
+		WriteError(w, r, s.logger, Validation(err), "Failed to unmarshal JSON")
+		return
+	}
+
+	if err := requestBody.Validate(); err != nil {
+		WriteError(w, r, s.logger, Validation(err), "Invalid link settings")
+		return
+	}
+	if requestBody.RemovePassword {
+		requestBody.PasswordHash = ""
+	}
+	if err := requestBody.HashPassword(); err != nil {
+		WriteError(w, r, s.logger, err, "Failed to hash password")
+		return
+	}
+	if requestBody.URL != "" {
+		destination, err := parseDestination(requestBody.URL)
+		if err != nil {
+			WriteError(w, r, s.logger, Validation(err), "Invalid url")
+			return
+		}
+		if destination.String() != link.URL.String() {
+			// Metadata of the old destination would mislead previews
+			link.Metadata = nil
+		}
+		link.URL = destination
+	}
+	if requestBody.Expires != nil {
+		setExpiry(link, *requestBody.Expires, now)
+	}
+	link.LinkSettings = requestBody.LinkSettings
+
+	if !s.saveChange(w, r, link, &models.LinkRevision{
+		ShortURL: link.ShortURL.String(),
+		Action:   models.RevisionActionUpdate,
+		Time:     now,
+		Old:      old,
+		New:      models.NewLinkState(link, now),
+	}) {
+		return
+	}
+
+	s.writeLink(w, r, link)
+}
+
+type RollbackRequest struct {
+	Revision int64 `json:"revision"`
+}
+
+// Rollback implements LinkService. The link is restored to how it was
+// before the given revision, undoing it and every later change.
+func (s *linkServiceImpl) Rollback(w http.ResponseWriter, r *http.Request) {
+	link, ok := s.getLink(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	old := models.NewLinkState(link, now)
+
+	var requestBody RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		WriteError(w, r, s.logger, Validation(err), "Failed to unmarshal JSON")
+		return
+	}
+
+	if s.history == nil {
+		WriteError(w, r, s.logger, NotFound(errors.New("history not available")), "Link history is not kept")
+		return
+	}
+	revision, err := s.history.GetRevision(r.Context(), link.ShortURL.String(), requestBody.Revision)
+	if err == nil && (revision.Old == nil || revision.Time.Before(link.CreatedAt)) {
+		// Revisions of an earlier link with the same code do not apply
+		err = util.ErrNotFound
+	}
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to get revision")
+		return
+	}
+
+	destination, err := url.Parse(revision.Old.URL)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to restore url")
+		return
+	}
+	if destination.String() != link.URL.String() {
+		link.Metadata = nil
+	}
+	link.URL = destination
+	setExpiry(link, revision.Old.Expires, now)
+	link.LinkSettings = revision.Old.LinkSettings
+
+	if !s.saveChange(w, r, link, &models.LinkRevision{
+		ShortURL:   link.ShortURL.String(),
+		Action:     models.RevisionActionRollback,
+		Time:       now,
+		RolledBack: revision.ID,
+		Old:        old,
+		New:        models.NewLinkState(link, now),
+	}) {
+		return
+	}
+
+	s.writeLink(w, r, link)
+}
+
+type HistoryResponse struct {
+	Items []*models.LinkRevision `json:"items"`
+	Count int                    `json:"count"`
+}
+
+// History implements LinkService. Revisions are listed newest first and
+// paged with the limit and offset query parameters.
+func (s *linkServiceImpl) History(w http.ResponseWriter, r *http.Request) {
+	link, ok := s.getLink(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, ok := page(w, r, s.logger)
+	if !ok {
+		return
+	}
+
+	items := []*models.LinkRevision{}
+	if s.history != nil {
+		var err error
+		items, err = s.history.ListRevisions(r.Context(), link.ShortURL.String(), link.CreatedAt, limit, offset)
+		if err != nil {
+			WriteError(w, r, s.logger, err, "Failed to list revisions")
+			return
+		}
+	}
+
+	out, err := json.Marshal(HistoryResponse{
+		Items: items,
+		Count: len(items),
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
+
+// DeleteLink implements LinkService. Deleted links are archived rather than
+// dropped, which keeps their code out of use for the quarantine period.
+func (s *linkServiceImpl) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	link, ok := s.getLink(w, r)
+	if !ok {
+		return
+	}
+
+	shortURL := link.ShortURL.String()
+	err := inTx(r.Context(), s.tx, func(ctx context.Context) error {
+		if err := s.archive.ArchiveShortURL(ctx, shortURL, models.ArchiveReasonDeleted); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, r, audit.ActionDelete, shortURL, models.NewLinkState(link, time.Now()), nil)
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to delete link")
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "Deleted link", slog.String("short_url", shortURL))
+	s.publish(r, models.WebhookEventLinkDeleted, link)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ArchiveResponse struct {
+	Items []*models.JSONArchivedURL `json:"items"`
+	Count int                       `json:"count"`
+}
+
+// Archived implements LinkService. It pages through archived links with the
+// limit and offset query parameters; code narrows the list to one code.
+func (s *linkServiceImpl) Archived(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := page(w, r, s.logger)
+	if !ok {
+		return
+	}
+
+	shortURL := ""
+	if code := r.URL.Query().Get("code"); code != "" {
+		shortURL = s.shortener.ShortURL(code)
+	}
+
+	items, err := s.archive.ListArchived(r.Context(), shortURL, limit, offset)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to list archived links")
+		return
+	}
+
+	out, err := json.Marshal(ArchiveResponse{
+		Items: items,
+		Count: len(items),
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
+
+// Stats implements LinkService.
+func (s *linkServiceImpl) Stats(w http.ResponseWriter, r *http.Request) {
+	link, ok := s.getLink(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := s.clicks.ClickStats(r.Context(), link.ShortURL.String(), link.CreatedAt)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to get link stats")
+		return
+	}
+
+	out, err := json.Marshal(stats)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
+
+// getLink loads the link named by the code path value, writing an error
+// response if it can not.
+func (s *linkServiceImpl) getLink(w http.ResponseWriter, r *http.Request) (*models.ShortenedURL, bool) {
+	code := r.PathValue("code")
+	if code == "" {
+		WriteError(w, r, s.logger, Validation(errors.New("code not provided")), "Code is required")
+		return nil, false
+	}
+
+	link, err := s.storage.GetOriginalURL(r.Context(), s.shortener.ShortURL(code))
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to get link")
+		return nil, false
+	}
+
+	return link, true
+}
+
+// saveChange writes link back to storage and adds revision of it to the
+// link history and the audit log in one transaction, so no change goes
+// unrecorded, writing an error response if it can not. Webhooks are told
+// once the change is saved. Saves that change nothing are not recorded.
+func (s *linkServiceImpl) saveChange(w http.ResponseWriter, r *http.Request, link *models.ShortenedURL, revision *models.LinkRevision) bool {
+	changed := !reflect.DeepEqual(revision.Old, revision.New)
+	action := audit.ActionUpdate
+	if revision.Action == models.RevisionActionRollback {
+		action = audit.ActionRollback
+	}
+
+	err := inTx(r.Context(), s.tx, func(ctx context.Context) error {
+		if err := s.storage.UpdateShortURL(ctx, link); err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		if s.history != nil {
+			revision.Actor = audit.Actor(ctx)
+			if err := s.history.RecordRevision(ctx, revision); err != nil {
+				return err
+			}
+		}
+		return s.audit.Record(ctx, r, action, revision.ShortURL, revision.Old, revision.New)
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to update link")
+		return false
+	}
+
+	s.logger.InfoContext(r.Context(), "Updated link", slog.Any("short_url", link.ShortURL))
+	if changed {
+		s.publish(r, models.WebhookEventLinkUpdated, link)
+	}
+	return true
+}
+
+// publish sends event about link to the webhook endpoints, if there are
+// webhooks.
+func (s *linkServiceImpl) publish(r *http.Request, event string, link *models.ShortenedURL) {
+	if s.webhooks != nil {
+		s.webhooks.Publish(r.Context(), event, models.PresentJsonShortenedURLModel(link))
+	}
+}
+
+func (s *linkServiceImpl) writeLink(w http.ResponseWriter, r *http.Request, link *models.ShortenedURL) {
+	out, err := json.Marshal(models.PresentJsonShortenedURLModel(link))
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
+
+// parseDestination parses a destination url the way links are shortened,
+// defaulting to https.
+func parseDestination(raw string) (*url.URL, error) {
+	if !strings.HasPrefix(raw, "http://") && !strings.HasPrefix(raw, "https://") {
+		raw = "https://" + raw
+	}
+	destination, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if destination.Host == "" {
+		return nil, errors.New("url has no host")
+	}
+	return destination, nil
+}
+
+func setExpiry(link *models.ShortenedURL, expires time.Time, now time.Time) {
+	link.Expires = expires
+	link.TTLInSeconds = max(int64(expires.Sub(now)/time.Second), 0)
+}
+
+// page reads the limit and offset query parameters, writing an error
+// response if they are invalid.
+func page(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (int, int, bool) {
+	query := r.URL.Query()
+
+	limit := defaultPageSize
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxPageSize {
+			WriteError(w, r, logger, Validation(errors.New("invalid limit")), "Limit should be between 1 and "+strconv.Itoa(maxPageSize))
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+
+	offset := 0
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			WriteError(w, r, logger, Validation(errors.New("invalid offset")), "Offset should be a positive integer")
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+
+	return limit, offset, true
+}
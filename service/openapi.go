@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/sri-shubham/snipr/internal/auth"
+)
+
+// openAPIDocument describes every route served by main.go. Routes added
+// there need an entry here too, or their requests go unchecked.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// OpenAPIService publishes the OpenAPI document of the service and checks
+// requests against it.
+type OpenAPIService interface {
+	Document(w http.ResponseWriter, r *http.Request)
+	Validate(next http.Handler) http.Handler
+}
+
+type openAPIServiceImpl struct {
+	router  routers.Router
+	options *openapi3filter.Options
+	logger  *slog.Logger
+}
+
+func NewOpenAPIService(logger *slog.Logger) (OpenAPIService, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, err
+	}
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &openapi3filter.Options{
+		// Handlers apply their own defaults
+		SkipSettingDefaults: true,
+		// Operations needing an API token are only checked for callers
+		// holding one; the others are left to RequireIdentity to answer
+		// 401, without learning anything about the schema
+		AuthenticationFunc: authenticated,
+	}
+	options.WithCustomSchemaErrorFunc(schemaErrorMessage)
+
+	return &openAPIServiceImpl{
+		router:  router,
+		options: options,
+		logger:  logger,
+	}, nil
+}
+
+// Document implements OpenAPIService.
+func (s *openAPIServiceImpl) Document(w http.ResponseWriter, r *http.Request) {
+	WriteJsonResponseWithCode(w, openAPIDocument, http.StatusOK)
+}
+
+// Validate implements OpenAPIService. Requests to operations in the
+// document must match its parameters and request body, or get a 400
+// naming the first mismatch. Requests the document does not describe, and
+// requests to operations needing an API token that carry none, are passed
+// on untouched, for the mux to answer.
+func (s *openAPIServiceImpl) Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := s.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    bodyAs(r, route.Operation),
+			PathParams: pathParams,
+			Route:      route,
+			Options:    s.options,
+		}
+		err = openapi3filter.ValidateRequest(r.Context(), input)
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			s.logger.DebugContext(r.Context(), "Request does not match the OpenAPI document",
+				slog.String("operation", route.Operation.OperationID),
+				slog.Any("error", err))
+			WriteError(w, r, s.logger, Validation(errors.New(validationMessage(err))), "Invalid request")
+			return
+		}
+		// Validation reads the body and leaves a fresh reader on the request
+		// it checked
+		r.Body = input.Request.Body
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticated is the openapi3filter.AuthenticationFunc of Validate. It
+// requires an identity set by auth.Middleware.
+func authenticated(_ context.Context, input *openapi3filter.AuthenticationInput) error {
+	if _, ok := auth.Identity(input.RequestValidationInput.Request.Context()); !ok {
+		return auth.ErrUnauthenticated
+	}
+	return nil
+}
+
+// bodyAs returns r, or a copy of it claiming the only media type op takes.
+// The handlers decode bodies without looking at Content-Type, so a JSON
+// body sent as form data, as curl -d does, is checked as the JSON it is.
+func bodyAs(r *http.Request, op *openapi3.Operation) *http.Request {
+	if op.RequestBody == nil || op.RequestBody.Value == nil || len(op.RequestBody.Value.Content) != 1 {
+		return r
+	}
+
+	for mediaType := range op.RequestBody.Value.Content {
+		if sent, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && sent == mediaType {
+			return r
+		}
+		if r.ContentLength == 0 && r.Header.Get("Content-Type") == "" {
+			return r
+		}
+		clone := r.Clone(r.Context())
+		clone.Header.Set("Content-Type", mediaType)
+		return clone
+	}
+	return r
+}
+
+// validationMessage shortens err to what the client got wrong.
+func validationMessage(err error) string {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return err.Error()
+	}
+
+	var schemaErr *openapi3.SchemaError
+	reason := requestErr.Reason
+	if errors.As(requestErr.Err, &schemaErr) {
+		reason = schemaErrorMessage(schemaErr)
+	} else if requestErr.Err != nil && reason == "" {
+		reason = requestErr.Err.Error()
+	}
+
+	switch {
+	case requestErr.Parameter != nil:
+		return requestErr.Parameter.In + " parameter " + requestErr.Parameter.Name + ": " + reason
+	case requestErr.RequestBody != nil:
+		return "request body: " + reason
+	default:
+		return reason
+	}
+}
+
+// schemaErrorMessage formats err without the schema and value the
+// library appends, naming the innermost mismatch of combined schemas.
+func schemaErrorMessage(err *openapi3.SchemaError) string {
+	pointer := err.JSONPointer()
+	reason := err.Reason
+	for {
+		var inner *openapi3.SchemaError
+		if !errors.As(err.Origin, &inner) {
+			break
+		}
+		err = inner
+		pointer = append(pointer, err.JSONPointer()...)
+		reason = err.Reason
+	}
+	if reason == "" && err.Origin != nil {
+		reason = err.Origin.Error()
+	} else if reason == "" {
+		reason = "doesn't match schema " + err.SchemaField
+	}
+
+	if len(pointer) > 0 {
+		return "/" + strings.Join(pointer, "/") + ": " + reason
+	}
+	return reason
+}
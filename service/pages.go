@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+var pageLayout = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{template "title" .}}</title>
+{{block "head" .}}{{end}}
+<style>
+body { font-family: system-ui, sans-serif; max-width: 24rem; margin: 15vh auto; padding: 0 1rem; color: #222; }
+input, button { font: inherit; padding: .5rem; width: 100%; box-sizing: border-box; margin-top: .5rem; }
+.error { color: #b00020; }
+.destination { overflow-wrap: anywhere; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+{{template "body" .}}
+</body>
+</html>
+`
+
+var passwordPage = template.Must(template.Must(template.New("password").Parse(pageLayout)).Parse(`
+{{define "title"}}Password required{{end}}
+{{define "body"}}
+<h1>Password required</h1>
+<p>This link is protected. Enter its password to continue.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post">
+<input type="password" name="password" autocomplete="current-password" autofocus required aria-label="Password">
+<button type="submit">Continue</button>
+</form>
+{{end}}
+`))
+
+var exhaustedPage = template.Must(template.Must(template.New("exhausted").Parse(pageLayout)).Parse(`
+{{define "title"}}Link no longer available{{end}}
+{{define "body"}}
+<h1>Link no longer available</h1>
+<p>This link has been used as many times as it allows.</p>
+{{end}}
+`))
+
+var unavailablePage = template.Must(template.Must(template.New("unavailable").Parse(pageLayout)).Parse(`
+{{define "title"}}Link not available yet{{end}}
+{{define "body"}}
+<h1>Link not available yet</h1>
+{{if .NotBefore}}<p>This link goes live on {{.NotBefore.UTC.Format "2 January 2006 at 15:04 MST"}}.</p>
+{{else}}<p>This link can not be opened right now. Please try again later.</p>{{end}}
+{{end}}
+`))
+
+var expiredPage = template.Must(template.Must(template.New("expired").Parse(pageLayout)).Parse(`
+{{define "title"}}Link expired{{end}}
+{{define "body"}}
+<h1>Link expired</h1>
+<p>This link has expired and no longer leads anywhere.</p>
+{{end}}
+`))
+
+var previewPage = template.Must(template.Must(template.New("preview").Parse(pageLayout)).Parse(`
+{{define "title"}}{{if and .Metadata .Metadata.Title}}{{.Metadata.Title}}{{else}}Link preview{{end}}{{end}}
+{{define "head"}}
+<meta property="og:type" content="website">
+{{with .Destination}}<meta property="og:url" content="{{.}}">{{end}}
+{{with .Metadata}}
+{{if .Title}}<meta property="og:title" content="{{.Title}}">{{end}}
+{{if .Description}}<meta property="og:description" content="{{.Description}}">
+<meta name="description" content="{{.Description}}">{{end}}
+{{if .Image}}<meta property="og:image" content="{{.Image}}">
+<meta name="twitter:card" content="summary_large_image">{{end}}
+{{if .Favicon}}<link rel="icon" href="{{.Favicon}}">{{end}}
+{{end}}
+{{end}}
+{{define "body"}}
+<h1>{{if and .Metadata .Metadata.Title}}{{.Metadata.Title}}{{else}}Link preview{{end}}</h1>
+{{with .Metadata}}
+{{if .Image}}<p><img src="{{.Image}}" alt=""></p>{{end}}
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{end}}
+{{if .Destination}}
+<p>This link leads to</p>
+<p class="destination">{{if and .Metadata .Metadata.Favicon}}<img src="{{.Metadata.Favicon}}" alt="" width="16" height="16"> {{end}}{{.Destination}}</p>
+{{else}}
+<p>This link can only be opened a limited number of times, so where it leads is not shown.</p>
+{{end}}
+<p><a href="{{.ShortURL}}">Continue</a></p>
+{{end}}
+`))
+
+type passwordPageData struct {
+	Error string
+}
+
+// linkPageData is passed to pages describing the state of a link.
+type linkPageData struct {
+	ShortURL string
+	// NotBefore is set while a link waits for its activation time.
+	NotBefore *time.Time
+}
+
+// previewPageData is passed to the preview page.
+type previewPageData struct {
+	ShortURL string
+	// Destination is empty for click limited links, which do not give
+	// away where they lead before a click is used up.
+	Destination string
+	// Metadata is nil for links that were not unfurled or are click
+	// limited.
+	Metadata *models.LinkMetadata
+}
+
+// Pages holds the HTML pages served in place of a redirect.
+type Pages struct {
+	Exhausted   *template.Template
+	Unavailable *template.Template
+	Expired     *template.Template
+	Preview     *template.Template
+}
+
+// LoadPages parses the page overrides in conf. Pages not overridden use the
+// built in ones.
+func LoadPages(conf *config.PagesConfig) (*Pages, error) {
+	pages := &Pages{
+		Exhausted:   exhaustedPage,
+		Unavailable: unavailablePage,
+		Expired:     expiredPage,
+		Preview:     previewPage,
+	}
+	if conf == nil {
+		return pages, nil
+	}
+
+	// Several pages may share one file, so they are not keyed by path
+	for _, override := range []struct {
+		path string
+		page **template.Template
+	}{
+		{conf.Exhausted, &pages.Exhausted},
+		{conf.Unavailable, &pages.Unavailable},
+		{conf.Expired, &pages.Expired},
+		{conf.Preview, &pages.Preview},
+	} {
+		if override.path == "" {
+			continue
+		}
+		parsed, err := template.New(filepath.Base(override.path)).ParseFiles(override.path)
+		if err != nil {
+			return nil, err
+		}
+		*override.page = parsed
+	}
+
+	return pages, nil
+}
+
+// WriteHTMLPageWithCode renders page with data. Pages are never cached since
+// they stand in for a redirect. Rendering failures are logged to logger.
+func WriteHTMLPageWithCode(w http.ResponseWriter, r *http.Request, logger *slog.Logger, page *template.Template, data any, code int) {
+	var buf bytes.Buffer
+	if err := page.Execute(&buf, data); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to render page", slog.String("page", page.Name()), slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+// ProbeService reports on the checks of link destinations.
+type ProbeService interface {
+	Broken(w http.ResponseWriter, r *http.Request)
+}
+
+type probeServiceImpl struct {
+	storage storage.ProbeStorage
+	logger  *slog.Logger
+}
+
+func NewProbeService(storage storage.ProbeStorage, logger *slog.Logger) ProbeService {
+	return &probeServiceImpl{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+type BrokenLinksResponse struct {
+	Items []*models.LinkProbe `json:"items"`
+	Count int                 `json:"count"`
+}
+
+// Broken implements ProbeService. It pages through links whose destination
+// is flagged broken with the limit and offset query parameters.
+func (s *probeServiceImpl) Broken(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := page(w, r, s.logger)
+	if !ok {
+		return
+	}
+
+	items, err := s.storage.ListBroken(r.Context(), limit, offset)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to list broken links")
+		return
+	}
+
+	out, err := json.Marshal(BrokenLinksResponse{
+		Items: items,
+		Count: len(items),
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/qrcode"
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/storage"
+)
+
+const (
+	defaultQRSize    = 256
+	defaultQRMaxSize = 2048
+	defaultQRMargin  = 4
+	maxQRMargin      = 40
+
+	// qrMaxAge is how long clients may keep a code. Codes are never kept
+	// past the link's expiry.
+	qrMaxAge = time.Hour
+)
+
+// QRService renders QR codes of short links.
+type QRService interface {
+	// Code serves codes to API token holders, for their clients only to
+	// cache.
+	Code(w http.ResponseWriter, r *http.Request)
+	// PublicCode serves codes on the short link itself, for shared caches
+	// to keep too.
+	PublicCode(w http.ResponseWriter, r *http.Request)
+}
+
+type qrServiceImpl struct {
+	shortener shorten.Shortener
+	storage   storage.URLStorage
+	logo      image.Image
+	conf      config.QRConfig
+	logger    *slog.Logger
+}
+
+func NewQRService(shortener shorten.Shortener, storage storage.URLStorage, logo image.Image, conf *config.QRConfig, logger *slog.Logger) QRService {
+	qrConf := config.QRConfig{}
+	if conf != nil {
+		qrConf = *conf
+	}
+	if qrConf.MaxSize <= 0 {
+		qrConf.MaxSize = defaultQRMaxSize
+	}
+
+	return &qrServiceImpl{
+		shortener: shortener,
+		storage:   storage,
+		logo:      logo,
+		conf:      qrConf,
+		logger:    logger,
+	}
+}
+
+// LoadQRLogo reads the logo configured in conf. It returns nil if there is
+// none.
+func LoadQRLogo(conf *config.QRConfig) (image.Image, error) {
+	if conf == nil || conf.Logo == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(conf.Logo)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	logo, _, err := image.Decode(f)
+	return logo, err
+}
+
+// Code implements QRService. It encodes the short URL of the link named by
+// the code path value. The query parameters pick the format (png or svg),
+// size in pixels, error correction level (L, M, Q or H), margin in
+// modules, fg and bg colours as hex RGB or RGBA, and whether to centre the
+// configured logo. Codes with a logo default to level H and are never
+// encoded below Q, since the logo hides modules; others default to M.
+// Expired links get 410.
+func (s *qrServiceImpl) Code(w http.ResponseWriter, r *http.Request) {
+	s.serve(w, r, "private")
+}
+
+// PublicCode implements QRService. It serves the same codes as Code.
+func (s *qrServiceImpl) PublicCode(w http.ResponseWriter, r *http.Request) {
+	s.serve(w, r, "public")
+}
+
+// serve renders the code asked for by r, letting clients cache it with
+// the given Cache-Control visibility.
+func (s *qrServiceImpl) serve(w http.ResponseWriter, r *http.Request, visibility string) {
+	code := r.PathValue("code")
+	query := r.URL.Query()
+
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		WriteError(w, r, s.logger, Validation(fmt.Errorf("unknown format %q", format)), "Format should be png or svg")
+		return
+	}
+
+	opts := qrcode.Options{Size: defaultQRSize, Margin: defaultQRMargin}
+	if value := query.Get("size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 || size > s.conf.MaxSize {
+			WriteError(w, r, s.logger, Validation(errors.New("invalid size")), "Size should be between 1 and "+strconv.Itoa(s.conf.MaxSize))
+			return
+		}
+		opts.Size = size
+	}
+	if value := query.Get("margin"); value != "" {
+		margin, err := strconv.Atoi(value)
+		if err != nil || margin < 0 || margin > maxQRMargin {
+			WriteError(w, r, s.logger, Validation(errors.New("invalid margin")), "Margin should be between 0 and "+strconv.Itoa(maxQRMargin))
+			return
+		}
+		opts.Margin = margin
+	}
+
+	for _, param := range []struct {
+		name  string
+		color *color.Color
+	}{
+		{"fg", &opts.Foreground},
+		{"bg", &opts.Background},
+	} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := parseColor(value)
+		if err != nil {
+			WriteError(w, r, s.logger, Validation(err), "Colours should be hex RGB or RGBA")
+			return
+		}
+		*param.color = parsed
+	}
+
+	level := qrcode.Medium
+	if value := query.Get("logo"); value != "" {
+		withLogo, err := strconv.ParseBool(value)
+		if err != nil {
+			WriteError(w, r, s.logger, Validation(err), "Logo should be true or false")
+			return
+		}
+		if withLogo {
+			if s.logo == nil {
+				WriteError(w, r, s.logger, Validation(errors.New("no logo configured")), "No logo is configured")
+				return
+			}
+			opts.Logo = s.logo
+			level = qrcode.High
+		}
+	}
+	if value := query.Get("level"); value != "" {
+		parsed, err := qrcode.ParseLevel(value)
+		if err != nil {
+			WriteError(w, r, s.logger, Validation(err), "Level should be one of L, M, Q and H")
+			return
+		}
+		level = parsed
+	}
+	if opts.Logo != nil && level < qrcode.Quartile {
+		level = qrcode.Quartile
+	}
+
+	shortURL := s.shortener.ShortURL(code)
+	link, err := s.storage.GetOriginalURL(r.Context(), shortURL)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to get link")
+		return
+	}
+	if link.TTLInSeconds <= 0 {
+		WriteError(w, r, s.logger, Expired(errors.New("link expired")), "Link expired")
+		return
+	}
+
+	qr, err := qrcode.Encode(shortURL, level)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to encode QR code")
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if format == "svg" {
+		contentType = "image/svg+xml"
+		err = qr.SVG(&buf, opts)
+	} else {
+		err = qr.PNG(&buf, opts)
+	}
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to render QR code")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	maxAge := min(int64(qrMaxAge/time.Second), link.TTLInSeconds)
+	w.Header().Set("Cache-Control", visibility+", max-age="+strconv.FormatInt(maxAge, 10))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// parseColor parses a hex colour of 3, 6 or 8 digits, with or without a
+// leading #.
+func parseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("invalid colour %q", s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid colour %q", s)
+	}
+	return color.NRGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
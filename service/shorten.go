@@ -1,17 +1,29 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sri-shubham/snipr/internal/access"
+	"github.com/sri-shubham/snipr/internal/analytics"
+	"github.com/sri-shubham/snipr/internal/audit"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/redirect"
 	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/internal/unfurl"
+	"github.com/sri-shubham/snipr/internal/webhook"
 	"github.com/sri-shubham/snipr/storage"
 	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
 )
 
 type ShortenUrlService interface {
@@ -19,29 +31,101 @@ type ShortenUrlService interface {
 	ShortenCustom(w http.ResponseWriter, r *http.Request)
 	DomainReport(w http.ResponseWriter, r *http.Request)
 	Redirect(w http.ResponseWriter, r *http.Request)
+	Unlock(w http.ResponseWriter, r *http.Request)
 }
 
 type shortenURLServiceImpl struct {
-	shortener shorten.Shortener
-	report    storage.URLReport
-	storage   storage.URLStorage
+	shortener  shorten.Shortener
+	report     storage.URLReport
+	storage    storage.URLStorage
+	archive    storage.URLArchive
+	classifier *redirect.Classifier
+	clicks     analytics.Recorder
+	guard      *access.Guard
+	pages      *Pages
+	audit      *audit.Log
+	tx         storage.Transactor
+	webhooks   webhook.Publisher
+	unfurler   unfurl.Unfurler
+	conf       config.RedirectConfig
+	fallbacks  map[string]string
+	logger     *slog.Logger
 }
 
-func NewShortenURLService(
-	shortener shorten.Shortener,
-	report storage.URLReport,
-	storage storage.URLStorage,
-) ShortenUrlService {
+const (
+	// previewSuffix follows a code to ask for the link's preview page.
+	previewSuffix = "+"
+
+	defaultPermanentMaxAge = 10 * time.Minute
+	variantCookieMaxAge    = 90 * 24 * time.Hour
+)
+
+// ShortenURLDeps are what the shortening service works with. Shortener and
+// Storage are required; Report serves DomainReport. The others are
+// optional and leave their feature off when nil: Archive expiry answers
+// for swept links, Classifier targeted redirects, Clicks analytics, Guard password protection, Audit the audit
+// log, Webhooks link events and Unfurler previews. Tx groups creating a
+// link with its audit event. Pages defaults to the built in pages.
+type ShortenURLDeps struct {
+	Shortener  shorten.Shortener
+	Report     storage.URLReport
+	Storage    storage.URLStorage
+	Archive    storage.URLArchive
+	Classifier *redirect.Classifier
+	Clicks     analytics.Recorder
+	Guard      *access.Guard
+	Pages      *Pages
+	Audit      *audit.Log
+	Tx         storage.Transactor
+	Webhooks   webhook.Publisher
+	Unfurler   unfurl.Unfurler
+}
+
+func NewShortenURLService(deps ShortenURLDeps, conf *config.RedirectConfig, logger *slog.Logger) ShortenUrlService {
+	redirectConf := config.RedirectConfig{}
+	if conf != nil {
+		redirectConf = *conf
+	}
+	if redirectConf.DefaultType == 0 {
+		redirectConf.DefaultType = http.StatusFound
+	}
+	if redirectConf.PermanentMaxAge == 0 {
+		redirectConf.PermanentMaxAge = defaultPermanentMaxAge
+	}
+	pages := deps.Pages
+	if pages == nil {
+		pages, _ = LoadPages(nil)
+	}
+	fallbacks := make(map[string]string, len(redirectConf.DomainFallbacks))
+	for _, fallback := range redirectConf.DomainFallbacks {
+		fallbacks[strings.ToLower(fallback.Domain)] = fallback.URL
+	}
+
 	return &shortenURLServiceImpl{
-		shortener: shortener,
-		report:    report,
-		storage:   storage,
+		shortener:  deps.Shortener,
+		report:     deps.Report,
+		storage:    deps.Storage,
+		archive:    deps.Archive,
+		classifier: deps.Classifier,
+		clicks:     deps.Clicks,
+		guard:      deps.Guard,
+		pages:      pages,
+		audit:      deps.Audit,
+		tx:         deps.Tx,
+		webhooks:   deps.Webhooks,
+		unfurler:   deps.Unfurler,
+		conf:       redirectConf,
+		fallbacks:  fallbacks,
+		logger:     logger,
 	}
 }
 
 type ShortenRequest struct {
 	OriginalURL string    `json:"url"`
 	Expires     time.Time `json:"expires"`
+	// Unfurl fetches the destination's title and images for previews.
+	Unfurl bool `json:"unfurl,omitempty"`
+	models.LinkSettings
 }
 
 func (s *shortenURLServiceImpl) Shorten(w http.ResponseWriter, r *http.Request) {
@@ -49,7 +133,7 @@ func (s *shortenURLServiceImpl) Shorten(w http.ResponseWriter, r *http.Request)
 
 	// Unmarshal the JSON data into the struct
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Failed to unmarshal JSON", http.StatusBadRequest)
+		WriteError(w, r, s.logger, Validation(err), "Failed to unmarshal JSON")
 		return
 	}
 
@@ -59,34 +143,40 @@ func (s *shortenURLServiceImpl) Shorten(w http.ResponseWriter, r *http.Request)
 
 	requestUrl, err := url.Parse(requestBody.OriginalURL)
 	if err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Failed to process request", http.StatusBadRequest)
+		WriteError(w, r, s.logger, Validation(err), "Failed to process request")
 		return
 	}
 
-	shortenedURL, err := s.shortener.Shorten(
-		r.Context(),
-		requestUrl,
-		time.Duration(time.Until(requestBody.Expires)),
-	)
-	if err != nil {
-		code := http.StatusInternalServerError
-		if errors.Is(err, shorten.ErrNotAvailable) {
-			code = http.StatusConflict
+	// The link is only created if its audit event is recorded
+	var shortenedURL *models.ShortenedURL
+	err = inTx(r.Context(), s.tx, func(ctx context.Context) error {
+		shortenedURL, err = s.shortener.Shorten(
+			ctx,
+			requestUrl,
+			time.Duration(time.Until(requestBody.Expires)),
+			requestBody.LinkSettings,
+		)
+		if err != nil {
+			return err
 		}
-		WriteJsonErrorResponseWithCode(w, err, "Failed to shorten url", code)
+		return s.audit.Record(ctx, r, audit.ActionCreate, shortenedURL.ShortURL.String(), nil, models.NewLinkState(shortenedURL, time.Now()))
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to shorten url")
 		return
 	}
 
-	resp := &models.JSONShortenedURL{
-		URL:          shortenedURL.URL.String(),
-		ShortURL:     shortenedURL.ShortURL.String(),
-		TTLInSeconds: shortenedURL.TTLInSeconds,
-		CreatedAt:    shortenedURL.CreatedAt,
+	s.logger.InfoContext(r.Context(), "Shortened url",
+		slog.Any("url", shortenedURL.URL),
+		slog.Any("short_url", shortenedURL.ShortURL))
+	if requestBody.Unfurl {
+		s.unfurl(r, shortenedURL)
 	}
+	s.publish(r, models.WebhookEventLinkCreated, shortenedURL)
 
-	out, err := json.Marshal(resp)
+	out, err := json.Marshal(models.PresentJsonShortenedURLModel(shortenedURL))
 	if err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Failed to marshal response", http.StatusInternalServerError)
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
 		return
 	}
 
@@ -97,6 +187,8 @@ type ShortenCustomRequest struct {
 	OriginalURL string    `json:"url"`
 	CustomCode  string    `json:"custom_code"`
 	Expires     time.Time `json:"expires"`
+	Unfurl      bool      `json:"unfurl,omitempty"`
+	models.LinkSettings
 }
 
 func (s *shortenURLServiceImpl) ShortenCustom(w http.ResponseWriter, r *http.Request) {
@@ -104,7 +196,7 @@ func (s *shortenURLServiceImpl) ShortenCustom(w http.ResponseWriter, r *http.Req
 
 	// Unmarshal the JSON data into the struct
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-		http.Error(w, "Failed to unmarshal JSON", http.StatusBadRequest)
+		WriteError(w, r, s.logger, Validation(err), "Failed to unmarshal JSON")
 		return
 	}
 
@@ -114,35 +206,41 @@ func (s *shortenURLServiceImpl) ShortenCustom(w http.ResponseWriter, r *http.Req
 
 	requestUrl, err := url.Parse(requestBody.OriginalURL)
 	if err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Failed to process request", http.StatusBadRequest)
+		WriteError(w, r, s.logger, Validation(err), "Failed to process request")
 		return
 	}
 
-	shortenedURL, err := s.shortener.ShortenCustom(
-		r.Context(),
-		requestUrl,
-		requestBody.CustomCode,
-		time.Duration(time.Until(requestBody.Expires)),
-	)
-	if err != nil {
-		code := http.StatusInternalServerError
-		if errors.Is(err, shorten.ErrNotAvailable) {
-			code = http.StatusConflict
+	// The link is only created if its audit event is recorded
+	var shortenedURL *models.ShortenedURL
+	err = inTx(r.Context(), s.tx, func(ctx context.Context) error {
+		shortenedURL, err = s.shortener.ShortenCustom(
+			ctx,
+			requestUrl,
+			requestBody.CustomCode,
+			time.Duration(time.Until(requestBody.Expires)),
+			requestBody.LinkSettings,
+		)
+		if err != nil {
+			return err
 		}
-		WriteJsonErrorResponseWithCode(w, err, "Failed to shorten url", code)
+		return s.audit.Record(ctx, r, audit.ActionCreateCustom, shortenedURL.ShortURL.String(), nil, models.NewLinkState(shortenedURL, time.Now()))
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to shorten url")
 		return
 	}
 
-	resp := &models.JSONShortenedURL{
-		URL:          shortenedURL.URL.String(),
-		ShortURL:     shortenedURL.ShortURL.String(),
-		TTLInSeconds: shortenedURL.TTLInSeconds,
-		CreatedAt:    shortenedURL.CreatedAt,
+	s.logger.InfoContext(r.Context(), "Shortened url",
+		slog.Any("url", shortenedURL.URL),
+		slog.Any("short_url", shortenedURL.ShortURL))
+	if requestBody.Unfurl {
+		s.unfurl(r, shortenedURL)
 	}
+	s.publish(r, models.WebhookEventLinkCreated, shortenedURL)
 
-	out, err := json.Marshal(resp)
+	out, err := json.Marshal(models.PresentJsonShortenedURLModel(shortenedURL))
 	if err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Failed to marshal response", http.StatusInternalServerError)
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
 		return
 	}
 
@@ -158,13 +256,13 @@ type ReportResponse struct {
 func (s *shortenURLServiceImpl) DomainReport(w http.ResponseWriter, r *http.Request) {
 	count := r.PathValue("count")
 	if count == "" {
-		WriteJsonErrorResponseWithCode(w, errors.New("count not provided"), "Count is required", http.StatusBadRequest)
+		WriteError(w, r, s.logger, Validation(errors.New("count not provided")), "Count is required")
 		return
 	}
 
 	countInt, err := strconv.ParseInt(count, 10, 64)
 	if err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Count should be integer", http.StatusBadRequest)
+		WriteError(w, r, s.logger, Validation(err), "Count should be integer")
 		return
 	}
 
@@ -174,7 +272,7 @@ func (s *shortenURLServiceImpl) DomainReport(w http.ResponseWriter, r *http.Requ
 
 	reportItems, err := s.report.ReportTopDomains(r.Context(), int(countInt))
 	if err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Failed to get domain report", http.StatusBadRequest)
+		WriteError(w, r, s.logger, err, "Failed to get domain report")
 		return
 	}
 
@@ -184,27 +282,250 @@ func (s *shortenURLServiceImpl) DomainReport(w http.ResponseWriter, r *http.Requ
 	}
 	out, err := json.Marshal(resp)
 	if err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Failed to marshal response", http.StatusInternalServerError)
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
 		return
 	}
 
 	WriteJsonResponseWithCode(w, out, http.StatusOK)
 }
 
-// Redirect implements ShortenUrlService.
+// Redirect implements ShortenUrlService. Links are looked up by code alone;
+// any query string and trailing path only matter if the link passes them
+// through.
+// Password protected links get a password form instead, unless the visitor
+// holds a valid access cookie.
+// A code followed by + shows the preview page instead of redirecting, as
+// do link preview fetchers visiting unfurled links. Previews of click
+// limited links do not show the destination.
 func (s *shortenURLServiceImpl) Redirect(w http.ResponseWriter, r *http.Request) {
-	requestedURL := r.URL.String()
+	code, preview := strings.CutSuffix(r.PathValue("code"), previewSuffix)
+	preview = preview && r.PathValue("rest") == ""
+	requestedURL := s.shortener.ShortURL(code)
+	shortURL, ok := s.lookup(w, r, requestedURL)
+	if !ok {
+		return
+	}
+
+	if shortURL.PasswordHash != "" && (s.guard == nil || !s.guard.Allowed(r, code, shortURL.PasswordHash)) {
+		WriteHTMLPageWithCode(w, r, s.logger, passwordPage, passwordPageData{}, http.StatusOK)
+		return
+	}
 
+	if preview || (shortURL.Metadata != nil && shortURL.PasswordHash == "" && redirect.IsPreviewBot(r.UserAgent())) {
+		data := previewPageData{ShortURL: requestedURL}
+		// Previews use up no clicks, so showing the destination of click
+		// limited links would let anyone read one-time links for free
+		if shortURL.MaxClicks == 0 {
+			data.Destination = shortURL.URL.String()
+			data.Metadata = shortURL.Metadata
+		}
+		WriteHTMLPageWithCode(w, r, s.logger, s.pages.Preview, data, http.StatusOK)
+		return
+	}
+
+	status := shortURL.RedirectType
+	if status == 0 {
+		status = s.conf.DefaultType
+	}
+	s.redirect(w, r, code, requestedURL, shortURL, status)
+}
+
+// Unlock implements ShortenUrlService. It checks the password submitted
+// through the password form and redirects on success. Clients guessing
+// passwords are throttled per link.
+func (s *shortenURLServiceImpl) Unlock(w http.ResponseWriter, r *http.Request) {
+	// The password form of a preview page posts back to /{code}+
+	code := strings.TrimSuffix(r.PathValue("code"), previewSuffix)
+	requestedURL := s.shortener.ShortURL(code)
+	shortURL, ok := s.lookup(w, r, requestedURL)
+	if !ok {
+		return
+	}
+
+	if shortURL.PasswordHash != "" {
+		if s.guard == nil {
+			WriteHTMLPageWithCode(w, r, s.logger, passwordPage, passwordPageData{Error: "Password protected links are not available."}, http.StatusForbidden)
+			return
+		}
+
+		client := s.classifier.ClientIP(r).String()
+		err := s.guard.Unlock(client, code, shortURL.PasswordHash, r.PostFormValue("password"))
+		var throttled *access.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			s.logger.WarnContext(r.Context(), "Throttled password attempts", slog.String("code", code), slog.String("client", client))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			WriteHTMLPageWithCode(w, r, s.logger, passwordPage, passwordPageData{Error: "Too many attempts. Try again later."}, http.StatusTooManyRequests)
+			return
+		case err != nil:
+			WriteHTMLPageWithCode(w, r, s.logger, passwordPage, passwordPageData{Error: "Wrong password."}, http.StatusForbidden)
+			return
+		}
+
+		if cookie := s.guard.Cookie(code, shortURL.PasswordHash, s.classifier.Secure(r)); cookie != nil {
+			http.SetCookie(w, cookie)
+		}
+	}
+
+	// The form was posted, so send the browser on with a GET
+	s.redirect(w, r, code, requestedURL, shortURL, http.StatusSeeOther)
+}
+
+// lookup loads the link served under requestedURL, writing an error
+// response if it can not be served. Links the sweeper archived are still
+// answered as expired.
+func (s *shortenURLServiceImpl) lookup(w http.ResponseWriter, r *http.Request, requestedURL string) (*models.ShortenedURL, bool) {
 	shortURL, err := s.storage.GetOriginalURL(r.Context(), requestedURL)
+	if errors.Is(err, util.ErrNotFound) && s.archive != nil {
+		archived, archiveErr := s.archive.ListArchived(r.Context(), requestedURL, 1, 0)
+		if archiveErr != nil {
+			WriteError(w, r, s.logger, archiveErr, "Failed to get link")
+			return nil, false
+		}
+		if len(archived) > 0 && archived[0].Reason == models.ArchiveReasonExpired {
+			s.expired(w, r, requestedURL, archived[0].URL, archived[0].ExpiryFallback)
+			return nil, false
+		}
+	}
 	if err != nil {
-		WriteJsonErrorResponseWithCode(w, err, "Failed to get domain report", http.StatusBadRequest)
-		return
+		WriteError(w, r, s.logger, err, "Failed to get link")
+		return nil, false
 	}
 
 	if shortURL.TTLInSeconds <= 0 {
-		WriteJsonResponseWithCode(w, []byte("Not found"), http.StatusNotFound)
+		s.expired(w, r, requestedURL, shortURL.URL.String(), shortURL.ExpiryFallback)
+		return nil, false
+	}
+
+	// Nothing about the destination is revealed before the link is live
+	now := time.Now()
+	if !shortURL.ActiveAt(now) {
+		data := linkPageData{ShortURL: requestedURL}
+		if shortURL.NotBefore != nil && now.Before(*shortURL.NotBefore) {
+			data.NotBefore = shortURL.NotBefore
+			w.Header().Set("Retry-After", shortURL.NotBefore.UTC().Format(http.TimeFormat))
+		}
+		WriteHTMLPageWithCode(w, r, s.logger, s.pages.Unavailable, data, http.StatusForbidden)
+		return nil, false
+	}
+
+	return shortURL, true
+}
+
+// expired sends visitors of an expired link to destination to its
+// fallback, the one of the destination's domain or the service wide one,
+// and otherwise tells them the link is gone for good.
+func (s *shortenURLServiceImpl) expired(w http.ResponseWriter, r *http.Request, requestedURL string, destination string, fallback string) {
+	if fallback == "" {
+		if u, err := url.Parse(destination); err == nil {
+			fallback = s.fallbacks[strings.ToLower(u.Hostname())]
+		}
+	}
+	if fallback == "" {
+		fallback = s.conf.ExpiryFallback
+	}
+	if fallback == "" {
+		WriteHTMLPageWithCode(w, r, s.logger, s.pages.Expired, linkPageData{ShortURL: requestedURL}, http.StatusGone)
 		return
 	}
 
-	http.Redirect(w, r, shortURL.URL.String(), http.StatusFound)
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.Redirect(w, r, fallback, http.StatusFound)
+}
+
+// publish sends event about link to the webhook endpoints, if there are
+// webhooks.
+func (s *shortenURLServiceImpl) publish(r *http.Request, event string, link *models.ShortenedURL) {
+	if s.webhooks != nil {
+		s.webhooks.Publish(r.Context(), event, models.PresentJsonShortenedURLModel(link))
+	}
+}
+
+// unfurl fetches the metadata of link's destination and stores it with the
+// link. Links keep no metadata if fetching fails.
+func (s *shortenURLServiceImpl) unfurl(r *http.Request, link *models.ShortenedURL) {
+	if s.unfurler == nil || link.Metadata != nil {
+		return
+	}
+
+	metadata, err := s.unfurler.Unfurl(r.Context(), link.URL.String())
+	if err != nil {
+		s.logger.WarnContext(r.Context(), "Failed to unfurl url", slog.Any("url", link.URL), slog.Any("error", err))
+		return
+	}
+
+	link.Metadata = metadata
+	if err := s.storage.UpdateShortURL(r.Context(), link); err != nil {
+		s.logger.ErrorContext(r.Context(), "Failed to store link metadata", slog.Any("short_url", link.ShortURL), slog.Any("error", err))
+		link.Metadata = nil
+	}
+}
+
+// redirect sends the visitor on to the destination of shortURL.
+func (s *shortenURLServiceImpl) redirect(w http.ResponseWriter, r *http.Request, code string, requestedURL string, shortURL *models.ShortenedURL, status int) {
+	if shortURL.MaxClicks > 0 {
+		if r.Method == http.MethodHead {
+			// Link checkers probing with HEAD must not use up clicks
+			w.Header().Set("Cache-Control", "private, no-store")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		clicks, err := s.storage.ConsumeClick(r.Context(), shortURL)
+		if errors.Is(err, util.ErrExhausted) {
+			WriteHTMLPageWithCode(w, r, s.logger, s.pages.Exhausted, linkPageData{ShortURL: requestedURL}, http.StatusGone)
+			return
+		}
+		if err != nil {
+			WriteError(w, r, s.logger, err, "Failed to count click")
+			return
+		}
+		if s.webhooks != nil && clicks == s.webhooks.ClickLimitThreshold(shortURL.MaxClicks) {
+			shortURL.Clicks = clicks
+			s.publish(r, models.WebhookEventClickLimitReached, shortURL)
+		}
+	}
+
+	visit := s.classifier.NewVisit(r, code, r.PathValue("rest"))
+	destination := redirect.Destination(shortURL, visit)
+	if visit.Variant != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     redirect.VariantCookie(code),
+			Value:    visit.Variant,
+			Path:     "/" + code,
+			MaxAge:   int(variantCookieMaxAge / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	if s.clicks != nil {
+		s.clicks.Record(visit.Click(requestedURL))
+	}
+
+	w.Header().Set("Cache-Control", s.cacheControl(status, shortURL))
+	if len(shortURL.Rules) > 0 || len(shortURL.Variants) > 0 {
+		w.Header().Set("Vary", "User-Agent, Accept-Language")
+	}
+	http.Redirect(w, r, destination.String(), status)
+}
+
+// cacheControl lets clients cache permanent redirects only briefly since the
+// link may still be edited, and keeps temporary redirects out of caches so
+// every visit reaches the service. Links whose destination depends on the
+// visitor's address are never stored in shared caches.
+func (s *shortenURLServiceImpl) cacheControl(code int, link *models.ShortenedURL) string {
+	if link.PasswordHash != "" || link.MaxClicks > 0 || len(link.Windows) > 0 {
+		return "private, no-store"
+	}
+
+	switch code {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		scope := "public"
+		if len(link.Geo) > 0 || len(link.Variants) > 0 {
+			scope = "private"
+		}
+		return fmt.Sprintf("%s, max-age=%d", scope, int(s.conf.PermanentMaxAge/time.Second))
+	default:
+		return "private, no-store"
+	}
 }
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/audit"
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockAuditStorage(ctrl)
+	auditService := service.NewAuditService(shortenMock, storageMock, slog.Default())
+
+	req := httptest.NewRequest("GET", "/api/audit?action=link.delete&actor=alice&code=sniper&since=2025-01-01T00:00:00Z&limit=10", nil)
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return("https://snipr.com/sniper")
+	storageMock.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, filter *models.AuditFilter) ([]*models.AuditEvent, error) {
+		require.Equal(t, &models.AuditFilter{
+			Action:   audit.ActionDelete,
+			Actor:    "alice",
+			ShortURL: "https://snipr.com/sniper",
+			Since:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Limit:    10,
+		}, filter)
+		return []*models.AuditEvent{{ID: 1, Action: audit.ActionDelete, Actor: "alice"}}, nil
+	})
+	auditService.Events(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &service.AuditResponse{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, 1, resp.Count)
+	require.Equal(t, "alice", resp.Items[0].Actor)
+
+	req = httptest.NewRequest("GET", "/api/audit?until=yesterday", nil)
+	respWriter = httptest.NewRecorder()
+	auditService.Events(respWriter, req)
+	require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+}
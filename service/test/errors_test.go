@@ -0,0 +1,113 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/access"
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		code service.ErrorCode
+	}{
+		{util.ErrNotFound, service.CodeNotFound},
+		{fmt.Errorf("get link: %w", util.ErrNotFound), service.CodeNotFound},
+		{shorten.ErrNotAvailable, service.CodeConflict},
+		{fmt.Errorf("%w: too short", shorten.ErrInvalidCustomCode), service.CodeValidation},
+		{fmt.Errorf("%w: bad weight", models.ErrInvalidLinkSettings), service.CodeValidation},
+		{util.ErrExhausted, service.CodeExpired},
+		{&access.ThrottledError{RetryAfter: time.Second}, service.CodeRateLimited},
+		{service.Validation(errors.New("invalid limit")), service.CodeValidation},
+		{fmt.Errorf("wrapped: %w", service.Expired(errors.New("link expired"))), service.CodeExpired},
+		{errors.New("connection refused"), service.CodeInternal},
+	} {
+		require.Equal(t, test.code, service.ErrorCodeOf(test.err), test.err.Error())
+	}
+
+	require.Equal(t, http.StatusNotFound, service.CodeNotFound.Status())
+	require.Equal(t, http.StatusGone, service.CodeExpired.Status())
+	require.Equal(t, http.StatusTooManyRequests, service.CodeRateLimited.Status())
+	require.Equal(t, http.StatusInternalServerError, service.CodeInternal.Status())
+}
+
+func TestWriteError(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/links/abc", nil)
+	respWriter := httptest.NewRecorder()
+	service.WriteError(respWriter, req, slog.Default(), service.Validation(errors.New("invalid limit")), "Limit should be between 1 and 500")
+
+	result := respWriter.Result()
+	require.Equal(t, http.StatusBadRequest, result.StatusCode)
+	require.Equal(t, service.ProblemContentType, result.Header.Get("Content-Type"))
+
+	resp := &service.ErrorResponse{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, service.ErrorResponse{
+		Type:     "about:blank",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   "invalid limit",
+		Instance: "/api/links/abc",
+		Code:     service.CodeValidation,
+		Error:    "invalid limit",
+		Message:  "Limit should be between 1 and 500",
+	}, *resp)
+}
+
+func TestWriteErrorHidesInternalErrors(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/archive", nil)
+	respWriter := httptest.NewRecorder()
+	logs := &bytes.Buffer{}
+	service.WriteError(respWriter, req, slog.New(slog.NewTextHandler(logs, nil)), errors.New(`pq: relation "archived_url" does not exist`), "Failed to list archived links")
+	require.Equal(t, http.StatusInternalServerError, respWriter.Result().StatusCode)
+	require.NotContains(t, respWriter.Body.String(), "archived_url")
+	// The cause only goes to the log
+	require.Contains(t, logs.String(), "archived_url")
+
+	resp := &service.ErrorResponse{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, service.CodeInternal, resp.Code)
+	require.Equal(t, "internal error", resp.Error)
+	require.Equal(t, "Failed to list archived links", resp.Message)
+}
+
+func TestRedirectUnknownCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage}, nil, slog.Default())
+
+	req := httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(nil, util.ErrNotFound)
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusNotFound, respWriter.Result().StatusCode)
+
+	resp := &service.ErrorResponse{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, service.CodeNotFound, resp.Code)
+	require.Equal(t, "Failed to get link", resp.Message)
+}
@@ -0,0 +1,84 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/health"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveness(t *testing.T) {
+	checker := health.NewChecker(0)
+	checker.Register("postgres", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	healthService := service.NewHealthService(checker, slog.Default())
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	respWriter := httptest.NewRecorder()
+	healthService.Liveness(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+}
+
+func TestReadiness(t *testing.T) {
+	checker := health.NewChecker(0)
+	checker.Register("postgres", func(ctx context.Context) error { return nil })
+	checker.Register("redis", func(ctx context.Context) error { return nil })
+	healthService := service.NewHealthService(checker, slog.Default())
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	respWriter := httptest.NewRecorder()
+	healthService.Readiness(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &health.Report{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, health.StatusOK, resp.Status)
+	require.Len(t, resp.Checks, 2)
+	require.Equal(t, health.StatusOK, resp.Checks["redis"].Status)
+}
+
+func TestReadinessFailingDependency(t *testing.T) {
+	checker := health.NewChecker(0)
+	checker.Register("postgres", func(ctx context.Context) error { return errors.New("connection refused") })
+	checker.Register("redis", func(ctx context.Context) error { return nil })
+	healthService := service.NewHealthService(checker, slog.Default())
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	respWriter := httptest.NewRecorder()
+	healthService.Readiness(respWriter, req)
+	require.Equal(t, http.StatusServiceUnavailable, respWriter.Result().StatusCode)
+
+	resp := &health.Report{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, health.StatusFailing, resp.Status)
+	require.Equal(t, health.StatusFailing, resp.Checks["postgres"].Status)
+	require.NotContains(t, respWriter.Body.String(), "connection refused")
+	require.Equal(t, health.StatusOK, resp.Checks["redis"].Status)
+}
+
+func TestReadinessDuringShutdown(t *testing.T) {
+	checker := health.NewChecker(0)
+	checker.Register("postgres", func(ctx context.Context) error { return nil })
+	healthService := service.NewHealthService(checker, slog.Default())
+	checker.MarkShuttingDown()
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	respWriter := httptest.NewRecorder()
+	healthService.Readiness(respWriter, req)
+	require.Equal(t, http.StatusServiceUnavailable, respWriter.Result().StatusCode)
+
+	resp := &health.Report{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, health.StatusShuttingDown, resp.Status)
+}
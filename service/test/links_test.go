@@ -0,0 +1,599 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/audit"
+	"github.com/sri-shubham/snipr/internal/auth"
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, nil, nil, nil, nil, slog.Default())
+
+	oURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PATCH", "/api/links/sniper", bytes.NewBufferString(`{"redirect_type": 308}`))
+	req.SetPathValue("code", "sniper")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:          oURL,
+		ShortURL:     sURL,
+		TTLInSeconds: 1000,
+		LinkSettings: models.LinkSettings{RedirectType: http.StatusFound},
+	}, nil)
+	storageMock.EXPECT().UpdateShortURL(gomock.Any(), &models.ShortenedURL{
+		URL:          oURL,
+		ShortURL:     sURL,
+		TTLInSeconds: 1000,
+		LinkSettings: models.LinkSettings{RedirectType: http.StatusPermanentRedirect},
+	}).Return(nil)
+	linkService.UpdateLink(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &models.JSONShortenedURL{}
+	err = json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusPermanentRedirect, resp.RedirectType)
+}
+
+func TestUpdateLinkReplacesMaps(t *testing.T) {
+	oURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	for _, test := range []struct {
+		body     string
+		geo      map[string]string
+		template map[string]string
+	}{
+		// Maps left out keep their value
+		{`{"redirect_type": 308}`, map[string]string{"DE": "https://example.de", "FR": "https://example.fr"}, map[string]string{"utm_source": "snipr"}},
+		// Maps that are set replace the old ones instead of merging into them
+		{`{"geo": {"US": "https://example.com"}}`, map[string]string{"US": "https://example.com"}, map[string]string{"utm_source": "snipr"}},
+		{`{"param_template": {"utm_medium": "qr"}}`, map[string]string{"DE": "https://example.de", "FR": "https://example.fr"}, map[string]string{"utm_medium": "qr"}},
+		// null clears them
+		{`{"geo": null, "param_template": null}`, nil, nil},
+	} {
+		t.Run(test.body, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			shortenMock := shorten.NewMockShortener(ctrl)
+			storageMock := storage.NewMockURLStorage(ctrl)
+			linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, nil, nil, nil, nil, slog.Default())
+
+			shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+			storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+				URL:          oURL,
+				ShortURL:     sURL,
+				TTLInSeconds: 1000,
+				LinkSettings: models.LinkSettings{
+					Geo:           map[string]string{"DE": "https://example.de", "FR": "https://example.fr"},
+					ParamTemplate: map[string]string{"utm_source": "snipr"},
+				},
+			}, nil)
+			var updated *models.ShortenedURL
+			storageMock.EXPECT().UpdateShortURL(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, link *models.ShortenedURL) error {
+				updated = link
+				return nil
+			})
+
+			req := httptest.NewRequest("PATCH", "/api/links/sniper", bytes.NewBufferString(test.body))
+			req.SetPathValue("code", "sniper")
+			respWriter := httptest.NewRecorder()
+			linkService.UpdateLink(respWriter, req)
+			require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+			require.Equal(t, test.geo, updated.Geo)
+			require.Equal(t, test.template, updated.ParamTemplate)
+		})
+	}
+}
+
+func TestUpdateLinkReplacesSlicesAndPointers(t *testing.T) {
+	oURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	current := func() models.LinkSettings {
+		return models.LinkSettings{
+			Passthrough: &models.Passthrough{Query: true},
+			Rules:       []models.TargetRule{{Language: "de", URL: "https://example.de"}},
+			Variants:    []models.Variant{{Name: "a", URL: "https://example.com/a", Weight: 3}},
+			Windows:     []models.Window{{Days: []string{"mon"}, Start: "09:00", End: "17:00", TimeZone: "Europe/Berlin"}},
+		}
+	}
+
+	for _, test := range []struct {
+		body     string
+		expected func(settings *models.LinkSettings)
+	}{
+		{`{"passthrough": {"path": true}}`, func(settings *models.LinkSettings) {
+			settings.Passthrough = &models.Passthrough{Path: true}
+		}},
+		{`{"rules": [{"platform": "ios", "url": "https://apps.apple.com/app/id1"}]}`, func(settings *models.LinkSettings) {
+			settings.Rules = []models.TargetRule{{Platform: "ios", URL: "https://apps.apple.com/app/id1"}}
+		}},
+		{`{"variants": [{"name": "b", "url": "https://example.com/b", "weight": 1}]}`, func(settings *models.LinkSettings) {
+			settings.Variants = []models.Variant{{Name: "b", URL: "https://example.com/b", Weight: 1}}
+		}},
+		{`{"windows": [{"start": "18:00", "end": "22:00"}]}`, func(settings *models.LinkSettings) {
+			settings.Windows = []models.Window{{Start: "18:00", End: "22:00"}}
+		}},
+		{`{"passthrough": null, "rules": null}`, func(settings *models.LinkSettings) {
+			settings.Passthrough = nil
+			settings.Rules = nil
+		}},
+	} {
+		t.Run(test.body, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			shortenMock := shorten.NewMockShortener(ctrl)
+			storageMock := storage.NewMockURLStorage(ctrl)
+			linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, nil, nil, nil, nil, slog.Default())
+
+			shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+			storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+				URL:          oURL,
+				ShortURL:     sURL,
+				TTLInSeconds: 1000,
+				LinkSettings: current(),
+			}, nil)
+			var updated *models.ShortenedURL
+			storageMock.EXPECT().UpdateShortURL(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, link *models.ShortenedURL) error {
+				updated = link
+				return nil
+			})
+
+			req := httptest.NewRequest("PATCH", "/api/links/sniper", bytes.NewBufferString(test.body))
+			req.SetPathValue("code", "sniper")
+			respWriter := httptest.NewRecorder()
+			linkService.UpdateLink(respWriter, req)
+			require.Equal(t, http.StatusOK, respWriter.Result().StatusCode, respWriter.Body.String())
+
+			expected := current()
+			test.expected(&expected)
+			require.Equal(t, expected, updated.LinkSettings)
+		})
+	}
+}
+
+func TestUpdateLinkInvalidRedirectType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, nil, nil, nil, nil, slog.Default())
+
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PATCH", "/api/links/sniper", bytes.NewBufferString(`{"redirect_type": 200}`))
+	req.SetPathValue("code", "sniper")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:      sURL,
+		ShortURL: sURL,
+	}, nil)
+	linkService.UpdateLink(respWriter, req)
+	require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+}
+
+func TestUpdateLinkNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, nil, nil, nil, nil, slog.Default())
+
+	req := httptest.NewRequest("PATCH", "/api/links/missing", bytes.NewBufferString(`{"redirect_type": 301}`))
+	req.SetPathValue("code", "missing")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("missing").Return("https://snipr.com/missing")
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://snipr.com/missing").Return(nil, util.ErrNotFound)
+	linkService.UpdateLink(respWriter, req)
+	require.Equal(t, http.StatusNotFound, respWriter.Result().StatusCode)
+}
+
+func TestUpdateLinkUnknownPlaceholder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, nil, nil, nil, nil, slog.Default())
+
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PATCH", "/api/links/sniper", bytes.NewBufferString(`{"param_template": {"utm_source": "{user_agent}"}}`))
+	req.SetPathValue("code", "sniper")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:      sURL,
+		ShortURL: sURL,
+	}, nil)
+	linkService.UpdateLink(respWriter, req)
+	require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+}
+
+func TestLinkStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	clicksMock := storage.NewMockClickStorage(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, clicksMock, nil, nil, nil, nil, nil, slog.Default())
+
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("GET", "/api/links/sniper/stats", nil)
+	req.SetPathValue("code", "sniper")
+	respWriter := httptest.NewRecorder()
+
+	stats := &models.JSONLinkStats{
+		ShortURL: sURL.String(),
+		Clicks:   5,
+		Variants: []*models.JSONVariantStats{
+			{Variant: "a", Clicks: 3},
+			{Variant: "b", Clicks: 2},
+		},
+	}
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:      sURL,
+		ShortURL: sURL,
+	}, nil)
+	clicksMock.EXPECT().ClickStats(gomock.Any(), sURL.String(), gomock.Any()).Return(stats, nil)
+	linkService.Stats(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &models.JSONLinkStats{}
+	err = json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, stats, resp)
+}
+
+func TestUpdateLinkPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, nil, nil, nil, nil, slog.Default())
+
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PATCH", "/api/links/sniper", bytes.NewBufferString(`{"password": "correct horse"}`))
+	req.SetPathValue("code", "sniper")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:      sURL,
+		ShortURL: sURL,
+	}, nil)
+	storageMock.EXPECT().UpdateShortURL(gomock.Any(), gomock.Any()).Do(func(_ any, link *models.ShortenedURL) {
+		require.Equal(t, "", link.Password)
+		require.NotEmpty(t, link.PasswordHash)
+	}).Return(nil)
+	linkService.UpdateLink(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.NotContains(t, respWriter.Body.String(), "password_hash")
+	require.NotContains(t, respWriter.Body.String(), "$2a$")
+
+	resp := &models.JSONShortenedURL{}
+	err = json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.True(t, resp.PasswordProtected)
+}
+
+func TestDeleteLinkArchives(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	archiveMock := storage.NewMockURLArchive(ctrl)
+	events := &bytes.Buffer{}
+	auditLog := audit.New(nil, slog.Default(), audit.NewJSONLinesSink(events))
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, archiveMock, nil, nil, auditLog, nil, slog.Default())
+
+	oURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	for _, tc := range []struct {
+		getErr     error
+		archiveErr error
+		code       int
+	}{
+		{nil, nil, http.StatusNoContent},
+		{util.ErrNotFound, nil, http.StatusNotFound},
+		{nil, util.ErrNotFound, http.StatusNotFound},
+	} {
+		req := httptest.NewRequest("DELETE", "/api/links/sniper", nil)
+		req.SetPathValue("code", "sniper")
+		req = req.WithContext(auth.WithIdentity(req.Context(), "alice"))
+		respWriter := httptest.NewRecorder()
+
+		shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+		if tc.getErr != nil {
+			storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(nil, tc.getErr)
+		} else {
+			storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+				URL:          oURL,
+				ShortURL:     sURL,
+				TTLInSeconds: 1000,
+			}, nil)
+			archiveMock.EXPECT().ArchiveShortURL(gomock.Any(), sURL.String(), models.ArchiveReasonDeleted).Return(tc.archiveErr)
+		}
+		linkService.DeleteLink(respWriter, req)
+		require.Equal(t, tc.code, respWriter.Result().StatusCode)
+	}
+
+	// Only the successful delete is audited
+	event := &models.AuditEvent{}
+	decoder := json.NewDecoder(events)
+	require.Nil(t, decoder.Decode(event))
+	require.False(t, decoder.More())
+	require.Equal(t, audit.ActionDelete, event.Action)
+	require.Equal(t, "alice", event.Actor)
+	require.Equal(t, sURL.String(), event.ShortURL)
+	require.JSONEq(t, `"https://en.wikipedia.org/wiki/URL_shortening"`, string(event.Diff["url"].Old))
+	require.JSONEq(t, `null`, string(event.Diff["url"].New))
+}
+
+func TestArchived(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	archiveMock := storage.NewMockURLArchive(ctrl)
+	linkService := service.NewLinkService(shortenMock, nil, nil, archiveMock, nil, nil, nil, nil, slog.Default())
+
+	req := httptest.NewRequest("GET", "/api/archive?code=sniper&limit=10&offset=20", nil)
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return("https://snipr.com/sniper")
+	archiveMock.EXPECT().ListArchived(gomock.Any(), "https://snipr.com/sniper", 10, 20).Return([]*models.JSONArchivedURL{
+		{URL: "https://example.com", ShortURL: "https://snipr.com/sniper", Reason: models.ArchiveReasonExpired},
+	}, nil)
+	linkService.Archived(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &service.ArchiveResponse{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, 1, resp.Count)
+	require.Equal(t, models.ArchiveReasonExpired, resp.Items[0].Reason)
+
+	req = httptest.NewRequest("GET", "/api/archive?limit=100000", nil)
+	respWriter = httptest.NewRecorder()
+	linkService.Archived(respWriter, req)
+	require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+}
+
+func TestUpdateLinkRecordsRevision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	historyMock := storage.NewMockLinkHistory(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, historyMock, nil, nil, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/spring")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	req := httptest.NewRequest("PATCH", "/api/links/sniper", bytes.NewBufferString(
+		`{"url": "example.com/summer", "expires": "2031-01-01T00:00:00Z", "geo": {"FR": "https://example.fr/summer"}}`))
+	req.SetPathValue("code", "sniper")
+	req = req.WithContext(auth.WithIdentity(req.Context(), "alice"))
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:          oURL,
+		ShortURL:     sURL,
+		Expires:      expires,
+		LinkSettings: models.LinkSettings{Geo: map[string]string{"FR": "https://example.fr/spring"}},
+	}, nil)
+	storageMock.EXPECT().UpdateShortURL(gomock.Any(), gomock.Any()).Return(nil)
+	historyMock.EXPECT().RecordRevision(gomock.Any(), gomock.Any()).Do(func(_ any, revision *models.LinkRevision) {
+		require.Equal(t, "alice", revision.Actor)
+		require.Equal(t, models.RevisionActionUpdate, revision.Action)
+		require.Equal(t, "https://example.com/spring", revision.Old.URL)
+		require.Equal(t, expires, revision.Old.Expires)
+		require.Equal(t, "https://example.fr/spring", revision.Old.Geo["FR"])
+		require.Equal(t, "https://example.com/summer", revision.New.URL)
+		require.Equal(t, expires.AddDate(1, 0, 0), revision.New.Expires)
+		require.Equal(t, "https://example.fr/summer", revision.New.Geo["FR"])
+	}).Return(nil)
+	linkService.UpdateLink(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &models.JSONShortenedURL{}
+	err = json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, "https://example.com/summer", resp.URL)
+}
+
+func TestRollbackLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	historyMock := storage.NewMockLinkHistory(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, historyMock, nil, nil, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/summer")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+	created := time.Now().Add(-time.Hour)
+	expires := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	req := httptest.NewRequest("POST", "/api/links/sniper/rollback", bytes.NewBufferString(`{"revision": 7}`))
+	req.SetPathValue("code", "sniper")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:       oURL,
+		ShortURL:  sURL,
+		CreatedAt: created,
+		Expires:   expires,
+	}, nil)
+	historyMock.EXPECT().GetRevision(gomock.Any(), sURL.String(), int64(7)).Return(&models.LinkRevision{
+		ID:   7,
+		Time: created.Add(time.Minute),
+		Old: &models.LinkState{
+			URL:          "https://example.com/spring",
+			Expires:      expires,
+			LinkSettings: models.LinkSettings{RedirectType: http.StatusMovedPermanently},
+		},
+	}, nil)
+	storageMock.EXPECT().UpdateShortURL(gomock.Any(), gomock.Any()).Do(func(_ any, link *models.ShortenedURL) {
+		require.Equal(t, "https://example.com/spring", link.URL.String())
+		require.Equal(t, http.StatusMovedPermanently, link.RedirectType)
+	}).Return(nil)
+	historyMock.EXPECT().RecordRevision(gomock.Any(), gomock.Any()).Do(func(_ any, revision *models.LinkRevision) {
+		require.Equal(t, models.RevisionActionRollback, revision.Action)
+		require.Equal(t, int64(7), revision.RolledBack)
+		require.Equal(t, "anonymous", revision.Actor)
+	}).Return(nil)
+	linkService.Rollback(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	// Revisions from before the link was created belong to an earlier link
+	req = httptest.NewRequest("POST", "/api/links/sniper/rollback", bytes.NewBufferString(`{"revision": 3}`))
+	req.SetPathValue("code", "sniper")
+	respWriter = httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:       oURL,
+		ShortURL:  sURL,
+		CreatedAt: created,
+	}, nil)
+	historyMock.EXPECT().GetRevision(gomock.Any(), sURL.String(), int64(3)).Return(&models.LinkRevision{
+		ID:   3,
+		Time: created.Add(-time.Hour),
+		Old:  &models.LinkState{URL: "https://example.com/other"},
+	}, nil)
+	linkService.Rollback(respWriter, req)
+	require.Equal(t, http.StatusNotFound, respWriter.Result().StatusCode)
+}
+
+func TestLinkHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	historyMock := storage.NewMockLinkHistory(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, historyMock, nil, nil, nil, slog.Default())
+
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+	created := time.Now().Add(-time.Hour)
+
+	req := httptest.NewRequest("GET", "/api/links/sniper/history?limit=5", nil)
+	req.SetPathValue("code", "sniper")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{
+		URL:       sURL,
+		ShortURL:  sURL,
+		CreatedAt: created,
+	}, nil)
+	historyMock.EXPECT().ListRevisions(gomock.Any(), sURL.String(), created, 5, 0).Return([]*models.LinkRevision{
+		{ID: 2, Action: models.RevisionActionUpdate, Old: &models.LinkState{}, New: &models.LinkState{}},
+	}, nil)
+	linkService.History(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &service.HistoryResponse{}
+	err = json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, 1, resp.Count)
+	require.Equal(t, int64(2), resp.Items[0].ID)
+}
+
+func TestUpdateLinkFailsWhenRevisionIsNotRecorded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	historyMock := storage.NewMockLinkHistory(ctrl)
+	txMock := storage.NewMockTransactor(ctrl)
+	linkService := service.NewLinkService(shortenMock, storageMock, nil, nil, historyMock, txMock, nil, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/spring")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/sniper")
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PATCH", "/api/links/sniper", bytes.NewBufferString(`{"url": "example.com/summer"}`))
+	req.SetPathValue("code", "sniper")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("sniper").Return(sURL.String())
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), sURL.String()).Return(&models.ShortenedURL{URL: oURL, ShortURL: sURL}, nil)
+	// The update and its revision share a transaction, rolled back when
+	// either fails
+	txMock.EXPECT().RunInTx(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+		return fn(ctx)
+	})
+	storageMock.EXPECT().UpdateShortURL(gomock.Any(), gomock.Any()).Return(nil)
+	historyMock.EXPECT().RecordRevision(gomock.Any(), gomock.Any()).Return(errors.New("connection reset"))
+	linkService.UpdateLink(respWriter, req)
+	require.Equal(t, http.StatusInternalServerError, respWriter.Result().StatusCode)
+}
@@ -0,0 +1,216 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sri-shubham/snipr/internal/auth"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/stretchr/testify/require"
+)
+
+// validated serves req, sent with an API token, through the validation
+// middleware and reports whether it reached the handler, and the body the
+// handler read.
+func validated(t *testing.T, req *http.Request) (*httptest.ResponseRecorder, bool, string) {
+	openAPIService, err := service.NewOpenAPIService(slog.Default())
+	require.Nil(t, err)
+
+	reached := false
+	var body []byte
+	handler := openAPIService.Validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		body, err = io.ReadAll(r.Body)
+		require.Nil(t, err)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	respWriter := httptest.NewRecorder()
+	handler.ServeHTTP(respWriter, req.WithContext(auth.WithIdentity(req.Context(), "ci")))
+	return respWriter, reached, string(body)
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	openAPIService, err := service.NewOpenAPIService(slog.Default())
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	respWriter := httptest.NewRecorder()
+	openAPIService.Document(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	doc := struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}{}
+	err = json.Unmarshal(respWriter.Body.Bytes(), &doc)
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(doc.OpenAPI, "3."))
+	for _, schema := range []string{"ShortenRequest", "ShortenCustomRequest", "ReportResponse", "ErrorResponse"} {
+		require.Contains(t, doc.Components.Schemas, schema)
+	}
+
+	// Every route main.go serves is described
+	source, err := os.ReadFile("../../main.go")
+	require.Nil(t, err)
+	routes := regexp.MustCompile(`(?:handle|handleAPI|mux\.HandleFunc)\("([A-Z]+) ([^"]+)"`).FindAllStringSubmatch(string(source), -1)
+	require.NotEmpty(t, routes)
+	// QR codes are served from the trailing path route
+	routes = append(routes, []string{"", "GET", "/{code}/qr"})
+	for _, route := range routes {
+		method, path := strings.ToLower(route[1]), strings.ReplaceAll(route[2], "...}", "}")
+		require.Contains(t, doc.Paths, path, route[0])
+		require.Contains(t, doc.Paths[path], method, route[0])
+	}
+}
+
+func TestValidateValidRequest(t *testing.T) {
+	body := `{"url": "https://en.wikipedia.org/wiki/URL_shortening", "expires": "2030-01-02T15:04:05Z", "redirect_type": 301}`
+	req := httptest.NewRequest("POST", "/shorten", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	respWriter, reached, read := validated(t, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.True(t, reached)
+	require.Equal(t, body, read)
+}
+
+func TestValidateInvalidBody(t *testing.T) {
+	for name, body := range map[string]string{
+		"missing url":        `{"expires": "2030-01-02T15:04:05Z"}`,
+		"wrong type":         `{"url": 42}`,
+		"bad redirect type":  `{"url": "example.com", "redirect_type": 303}`,
+		"bad date":           `{"url": "example.com", "expires": "tomorrow"}`,
+		"bad variant weight": `{"url": "example.com", "variants": [{"name": "a", "url": "example.com", "weight": 0}]}`,
+		"not json":           `{"url": `,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/shorten", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			respWriter, reached, _ := validated(t, req)
+			require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+			require.False(t, reached)
+			require.Equal(t, service.ProblemContentType, respWriter.Header().Get("Content-Type"))
+
+			resp := &service.ErrorResponse{}
+			err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+			require.Nil(t, err)
+			require.Equal(t, service.CodeValidation, resp.Code)
+			require.True(t, strings.HasPrefix(resp.Error, "request body"), resp.Error)
+		})
+	}
+}
+
+func TestValidateCustomCode(t *testing.T) {
+	req := httptest.NewRequest("POST", "/shorten/custom", strings.NewReader(`{"url": "example.com", "custom_code": "no-dashes"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	respWriter, reached, _ := validated(t, req)
+	require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+	require.False(t, reached)
+	require.Contains(t, respWriter.Body.String(), "/custom_code")
+}
+
+func TestValidateJSONSentAsForm(t *testing.T) {
+	// curl -d sends bodies as form data
+	body := `{"url": "example.com"}`
+	req := httptest.NewRequest("POST", "/shorten", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	respWriter, reached, read := validated(t, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.True(t, reached)
+	require.Equal(t, body, read)
+}
+
+func TestValidateParameters(t *testing.T) {
+	for target, valid := range map[string]bool{
+		"/report/5":                                true,
+		"/report/five":                             false,
+		"/api/links/broken?limit=10":               true,
+		"/api/links/broken?limit=0":                false,
+		"/api/archive?offset=-1":                   false,
+		"/api/audit?since=2024-01-02T00:00:00Z":    true,
+		"/api/audit?since=yesterday":               false,
+		"/api/webhooks/12/deliveries":              true,
+		"/api/webhooks/abc/deliveries":             false,
+		"/api/webhooks/12/deliveries?status=lost":  false,
+		"/api/links/abc123/qr?format=svg&margin=2": true,
+		"/api/links/abc123/qr?margin=41":           false,
+		"/abc123/qr?format=svg&margin=2":           true,
+		"/abc123/qr?margin=41":                     false,
+		"/api/links/abc123/qr?fg=zzz":              false,
+		"/abc123/docs":                             true,
+	} {
+		t.Run(target, func(t *testing.T) {
+			req := httptest.NewRequest("GET", target, nil)
+
+			respWriter, reached, _ := validated(t, req)
+			require.Equal(t, valid, reached)
+			if !valid {
+				require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestValidateUnknownRoute(t *testing.T) {
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/abc123/deep/path", nil),
+		httptest.NewRequest("PUT", "/shorten", bytes.NewBufferString("anything")),
+	} {
+		respWriter, reached, _ := validated(t, req)
+		require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+		require.True(t, reached)
+	}
+}
+
+func TestShortenCustomHTTPHandlerInvalidJSON(t *testing.T) {
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{}, nil, slog.Default())
+
+	req := httptest.NewRequest("POST", "/shorten/custom", bytes.NewBufferString(`{"url": `))
+	respWriter := httptest.NewRecorder()
+	shortenService.ShortenCustom(respWriter, req)
+	require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+
+	resp := &service.ErrorResponse{}
+	err := json.Unmarshal(respWriter.Body.Bytes(), resp)
+	require.Nil(t, err)
+	require.Equal(t, "Failed to unmarshal JSON", resp.Message)
+}
+
+func TestValidateLeavesUnauthenticatedRequestsToRequireIdentity(t *testing.T) {
+	openAPIService, err := service.NewOpenAPIService(slog.Default())
+	require.Nil(t, err)
+
+	reached := false
+	handler := openAPIService.Validate(service.RequireIdentity(slog.Default(), func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	// Invalid in both query and body, but the caller has no token
+	req := httptest.NewRequest("PATCH", "/api/links/abc123?limit=0", strings.NewReader(`{"redirect_type": 200}`))
+	req.Header.Set("Content-Type", "application/json")
+	respWriter := httptest.NewRecorder()
+	handler.ServeHTTP(respWriter, req)
+	require.False(t, reached)
+	require.Equal(t, http.StatusUnauthorized, respWriter.Result().StatusCode)
+	require.NotContains(t, respWriter.Body.String(), "redirect_type")
+
+	req = httptest.NewRequest("GET", "/api/links/broken?limit=0", nil)
+	respWriter = httptest.NewRecorder()
+	handler.ServeHTTP(respWriter, req)
+	require.Equal(t, http.StatusUnauthorized, respWriter.Result().StatusCode)
+}
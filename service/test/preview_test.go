@@ -0,0 +1,214 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/internal/unfurl"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortenUnfurls(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	unfurler := unfurl.NewMockUnfurler(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage, Unfurler: unfurler}, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/release-notes")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/5rt3fv")
+	require.Nil(t, err)
+	metadata := &models.LinkMetadata{Title: "Release notes", Image: "https://example.com/cover.png", FetchedAt: time.Now().UTC()}
+
+	shortenMock.EXPECT().Shorten(gomock.Any(), oURL, gomock.Any(), models.LinkSettings{}).Return(&models.ShortenedURL{
+		URL:          oURL,
+		ShortURL:     sURL,
+		TTLInSeconds: 1000,
+	}, nil)
+	unfurler.EXPECT().Unfurl(gomock.Any(), "https://example.com/release-notes").Return(metadata, nil)
+	storage.EXPECT().UpdateShortURL(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, link *models.ShortenedURL) error {
+		require.Equal(t, metadata, link.Metadata)
+		return nil
+	})
+
+	bodyBytes, err := json.Marshal(&service.ShortenRequest{OriginalURL: oURL.String(), Unfurl: true})
+	require.Nil(t, err)
+	respWriter := httptest.NewRecorder()
+	shortenService.Shorten(respWriter, httptest.NewRequest("POST", "/shorten", bytes.NewBuffer(bodyBytes)))
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &models.JSONShortenedURL{}
+	require.Nil(t, json.Unmarshal(respWriter.Body.Bytes(), resp))
+	require.NotNil(t, resp.Metadata)
+	require.Equal(t, "Release notes", resp.Metadata.Title)
+}
+
+func TestShortenUnfurlFailureKeepsLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	unfurler := unfurl.NewMockUnfurler(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Unfurler: unfurler}, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/down")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://snipr.com/5rt3fv")
+	require.Nil(t, err)
+
+	shortenMock.EXPECT().ShortenCustom(gomock.Any(), oURL, "down", gomock.Any(), models.LinkSettings{}).Return(&models.ShortenedURL{
+		URL:          oURL,
+		ShortURL:     sURL,
+		TTLInSeconds: 1000,
+	}, nil)
+	unfurler.EXPECT().Unfurl(gomock.Any(), "https://example.com/down").Return(nil, errors.New("connection refused"))
+
+	bodyBytes, err := json.Marshal(&service.ShortenCustomRequest{OriginalURL: oURL.String(), CustomCode: "down", Unfurl: true})
+	require.Nil(t, err)
+	respWriter := httptest.NewRecorder()
+	shortenService.ShortenCustom(respWriter, httptest.NewRequest("POST", "/shorten/custom", bytes.NewBuffer(bodyBytes)))
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &models.JSONShortenedURL{}
+	require.Nil(t, json.Unmarshal(respWriter.Body.Bytes(), resp))
+	require.Nil(t, resp.Metadata)
+}
+
+func TestRedirectPreview(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage}, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/release-notes")
+	require.Nil(t, err)
+	link := &models.ShortenedURL{
+		URL:          oURL,
+		TTLInSeconds: 1000,
+		Metadata: &models.LinkMetadata{
+			Title:       "Release notes",
+			Description: "What's new <this> week",
+			Image:       "https://example.com/cover.png",
+		},
+	}
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da").AnyTimes()
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(link, nil).AnyTimes()
+
+	req := httptest.NewRequest("GET", "/re45da+", nil)
+	req.SetPathValue("code", "re45da+")
+	respWriter := httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.Empty(t, respWriter.Header().Get("Location"))
+	body := respWriter.Body.String()
+	require.Contains(t, body, `<meta property="og:title" content="Release notes">`)
+	require.Contains(t, body, `<meta property="og:image" content="https://example.com/cover.png">`)
+	require.Contains(t, body, `<meta property="og:url" content="https://example.com/release-notes">`)
+	require.Contains(t, body, "What&#39;s new &lt;this&gt; week")
+	require.Contains(t, body, `<a href="https://localhost:8080/re45da">Continue</a>`)
+
+	// Preview fetchers get the same page at the link itself
+	req = httptest.NewRequest("GET", "/re45da", nil)
+	req.Header.Set("User-Agent", "facebookexternalhit/1.1")
+	req.SetPathValue("code", "re45da")
+	respWriter = httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.Contains(t, respWriter.Body.String(), `<meta property="og:title" content="Release notes">`)
+}
+
+func TestRedirectPreviewOfClickLimitedLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage}, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/one-time-secret")
+	require.Nil(t, err)
+	link := &models.ShortenedURL{
+		URL:          oURL,
+		TTLInSeconds: 1000,
+		Metadata:     &models.LinkMetadata{Title: "Secret plans", Image: "https://example.com/secret.png"},
+		LinkSettings: models.LinkSettings{MaxClicks: 1},
+	}
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da").AnyTimes()
+	// Previews use up no clicks
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(link, nil).AnyTimes()
+
+	for name, req := range map[string]*http.Request{
+		"preview suffix": httptest.NewRequest("GET", "/re45da+", nil),
+		"preview fetcher": func() *http.Request {
+			req := httptest.NewRequest("GET", "/re45da", nil)
+			req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0")
+			return req
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			req.SetPathValue("code", strings.TrimPrefix(req.URL.Path, "/"))
+			respWriter := httptest.NewRecorder()
+			shortenService.Redirect(respWriter, req)
+			require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+			require.Empty(t, respWriter.Header().Get("Location"))
+			body := respWriter.Body.String()
+			require.NotContains(t, body, "one-time-secret")
+			require.NotContains(t, body, "Secret plans")
+			require.NotContains(t, body, "secret.png")
+			require.Contains(t, body, `<a href="https://localhost:8080/re45da">Continue</a>`)
+		})
+	}
+}
+
+func TestRedirectPreviewWithoutMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage}, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/plain")
+	require.Nil(t, err)
+	link := &models.ShortenedURL{URL: oURL, TTLInSeconds: 1000}
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da").AnyTimes()
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(link, nil).AnyTimes()
+
+	req := httptest.NewRequest("GET", "/re45da+", nil)
+	req.SetPathValue("code", "re45da+")
+	respWriter := httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.Contains(t, respWriter.Body.String(), "https://example.com/plain")
+	require.NotContains(t, respWriter.Body.String(), "og:title")
+
+	// Preview fetchers are redirected to links that were not unfurled
+	req = httptest.NewRequest("GET", "/re45da", nil)
+	req.Header.Set("User-Agent", "facebookexternalhit/1.1")
+	req.SetPathValue("code", "re45da")
+	respWriter = httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusFound, respWriter.Result().StatusCode)
+	require.Equal(t, "https://example.com/plain", respWriter.Header().Get("Location"))
+}
@@ -0,0 +1,37 @@
+package test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokenLinks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockProbeStorage(ctrl)
+	probeService := service.NewProbeService(storageMock, slog.Default())
+
+	storageMock.EXPECT().ListBroken(gomock.Any(), 20, 40).Return([]*models.LinkProbe{
+		{ShortURL: "https://snipr.com/sniper", URL: "https://example.com/old", StatusCode: http.StatusNotFound, Failures: 3, Broken: true},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/api/links/broken?limit=20&offset=40", nil)
+	respWriter := httptest.NewRecorder()
+	probeService.Broken(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &service.BrokenLinksResponse{}
+	require.Nil(t, json.Unmarshal(respWriter.Body.Bytes(), resp))
+	require.Equal(t, 1, resp.Count)
+	require.Equal(t, http.StatusNotFound, resp.Items[0].StatusCode)
+}
@@ -0,0 +1,208 @@
+package test
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/stretchr/testify/require"
+)
+
+func qrRequest(code string, query string) *http.Request {
+	req := httptest.NewRequest("GET", "/api/links/"+code+"/qr?"+query, nil)
+	req.SetPathValue("code", code)
+	return req
+}
+
+func TestQRCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oURL, err := url.Parse("https://example.com/menu")
+	require.Nil(t, err)
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da").AnyTimes()
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{URL: oURL, TTLInSeconds: 1000}, nil).AnyTimes()
+	qrService := service.NewQRService(shortenMock, storage, nil, nil, slog.Default())
+
+	respWriter := httptest.NewRecorder()
+	qrService.Code(respWriter, qrRequest("re45da", "size=300&fg=c00&bg=%23ffffff00&margin=2&level=q"))
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.Equal(t, "image/png", respWriter.Header().Get("Content-Type"))
+	require.Equal(t, "private, max-age=1000", respWriter.Header().Get("Cache-Control"))
+
+	img, err := png.Decode(respWriter.Body)
+	require.Nil(t, err)
+	require.Equal(t, image.Rect(0, 0, 300, 300), img.Bounds())
+	// The background is transparent
+	require.Equal(t, uint8(0), color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA).A)
+
+	respWriter = httptest.NewRecorder()
+	qrService.Code(respWriter, qrRequest("re45da", "format=svg"))
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.Equal(t, "image/svg+xml", respWriter.Header().Get("Content-Type"))
+	require.True(t, strings.HasPrefix(respWriter.Body.String(), "<svg "))
+}
+
+func TestPublicQRCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oURL, err := url.Parse("https://example.com/menu")
+	require.Nil(t, err)
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{URL: oURL, TTLInSeconds: 7200}, nil)
+	qrService := service.NewQRService(shortenMock, storage, nil, nil, slog.Default())
+
+	req := httptest.NewRequest("GET", "/re45da/qr", nil)
+	req.SetPathValue("code", "re45da")
+	req.SetPathValue("rest", "qr")
+	respWriter := httptest.NewRecorder()
+	qrService.PublicCode(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.Equal(t, "public, max-age=3600", respWriter.Header().Get("Cache-Control"))
+}
+
+func TestQRCodeInvalidParameters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	qrService := service.NewQRService(shorten.NewMockShortener(ctrl), storage.NewMockURLStorage(ctrl), nil, &config.QRConfig{MaxSize: 1000}, slog.Default())
+
+	for _, query := range []string{
+		"format=gif",
+		"size=1001",
+		"size=-1",
+		"margin=41",
+		"fg=red",
+		"bg=12345",
+		"level=X",
+		"logo=maybe",
+		// No logo is configured
+		"logo=true",
+	} {
+		respWriter := httptest.NewRecorder()
+		qrService.Code(respWriter, qrRequest("re45da", query))
+		require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode, query)
+	}
+}
+
+func TestQRCodeLogo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oURL, err := url.Parse("https://example.com/menu")
+	require.Nil(t, err)
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{URL: oURL, TTLInSeconds: 1000}, nil)
+
+	green := color.NRGBA{G: 0xff, A: 0xff}
+	logo := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := range 8 {
+		for x := range 8 {
+			logo.SetNRGBA(x, y, green)
+		}
+	}
+	qrService := service.NewQRService(shortenMock, storage, logo, nil, slog.Default())
+
+	respWriter := httptest.NewRecorder()
+	qrService.Code(respWriter, qrRequest("re45da", "logo=true&size=512"))
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	img, err := png.Decode(respWriter.Body)
+	require.Nil(t, err)
+	require.Equal(t, green, color.NRGBAModel.Convert(img.At(256, 256)))
+}
+
+func TestQRCodeLogoLevel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oURL, err := url.Parse("https://example.com/menu")
+	require.Nil(t, err)
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da").Times(3)
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{URL: oURL, TTLInSeconds: 1000}, nil).Times(3)
+	logo := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	qrService := service.NewQRService(shortenMock, storage, logo, nil, slog.Default())
+
+	// Level L can not recover the modules under the logo, so Q is used
+	withLogo := httptest.NewRecorder()
+	qrService.Code(withLogo, qrRequest("re45da", "logo=true&level=L&format=svg"))
+	require.Equal(t, http.StatusOK, withLogo.Result().StatusCode)
+	levelQ := httptest.NewRecorder()
+	qrService.Code(levelQ, qrRequest("re45da", "level=Q&format=svg"))
+	require.Equal(t, http.StatusOK, levelQ.Result().StatusCode)
+
+	require.Equal(t, svgModules(levelQ.Body.String()), svgModules(withLogo.Body.String()))
+
+	// Without a logo level L is kept
+	levelL := httptest.NewRecorder()
+	qrService.Code(levelL, qrRequest("re45da", "level=L&format=svg"))
+	require.Equal(t, http.StatusOK, levelL.Result().StatusCode)
+	require.NotEqual(t, svgModules(levelQ.Body.String()), svgModules(levelL.Body.String()))
+}
+
+// svgModules returns the dark modules of an SVG code, leaving out the logo.
+func svgModules(svg string) string {
+	start := strings.Index(svg, "<path")
+	end := strings.Index(svg[start:], "/>")
+	return svg[start : start+end]
+}
+
+func TestQRCodeExpiredLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oURL, err := url.Parse("https://example.com/menu")
+	require.Nil(t, err)
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{URL: oURL, TTLInSeconds: 0}, nil)
+	qrService := service.NewQRService(shortenMock, storage, nil, nil, slog.Default())
+
+	respWriter := httptest.NewRecorder()
+	qrService.Code(respWriter, qrRequest("re45da", ""))
+	require.Equal(t, http.StatusGone, respWriter.Result().StatusCode)
+	require.Empty(t, respWriter.Header().Get("Cache-Control"))
+}
+
+func TestQRCodeUnknownLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenMock.EXPECT().ShortURL("nope").Return("https://localhost:8080/nope")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/nope").Return(nil, util.ErrNotFound)
+	qrService := service.NewQRService(shortenMock, storage, nil, nil, slog.Default())
+
+	respWriter := httptest.NewRecorder()
+	qrService.Code(respWriter, qrRequest("nope", ""))
+	require.Equal(t, http.StatusNotFound, respWriter.Result().StatusCode)
+}
@@ -2,18 +2,32 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/access"
+	"github.com/sri-shubham/snipr/internal/analytics"
+	"github.com/sri-shubham/snipr/internal/clientip"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/geoip"
+	"github.com/sri-shubham/snipr/internal/redirect"
 	"github.com/sri-shubham/snipr/internal/shorten"
+	"github.com/sri-shubham/snipr/internal/webhook"
 	"github.com/sri-shubham/snipr/service"
 	"github.com/sri-shubham/snipr/storage"
 	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,7 +37,7 @@ func TestShortenHTTPHandler(t *testing.T) {
 
 	shortenMock := shorten.NewMockShortener(ctrl)
 
-	shortenService := service.NewShortenURLService(shortenMock, nil, nil)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock}, nil, slog.Default())
 
 	reqBody := &service.ShortenRequest{
 		OriginalURL: "https://en.wikipedia.org/wiki/URL_shortening",
@@ -42,7 +56,7 @@ func TestShortenHTTPHandler(t *testing.T) {
 	req := httptest.NewRequest("POST", "/shorten", bytes.NewBuffer(bodyBytes))
 	respWriter := httptest.NewRecorder()
 
-	shortenMock.EXPECT().Shorten(gomock.Any(), oURL, time.Until(reqBody.Expires)).Return(&models.ShortenedURL{
+	shortenMock.EXPECT().Shorten(gomock.Any(), oURL, time.Until(reqBody.Expires), models.LinkSettings{}).Return(&models.ShortenedURL{
 		URL:          oURL,
 		ShortURL:     sURL,
 		TTLInSeconds: 1000,
@@ -66,7 +80,7 @@ func TestShortenHTTPHandlerInvalidURL(t *testing.T) {
 
 	shortenMock := shorten.NewMockShortener(ctrl)
 
-	shortenService := service.NewShortenURLService(shortenMock, nil, nil)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock}, nil, slog.Default())
 
 	reqBody := &service.ShortenRequest{
 		OriginalURL: "https://en.wiki pedia.org/wiki/URL_shortening",
@@ -97,7 +111,7 @@ func TestShortenCustomHTTPHandler(t *testing.T) {
 
 	shortenMock := shorten.NewMockShortener(ctrl)
 
-	shortenService := service.NewShortenURLService(shortenMock, nil, nil)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock}, nil, slog.Default())
 
 	reqBody := &service.ShortenCustomRequest{
 		OriginalURL: "https://en.wikipedia.org/wiki/URL_shortening",
@@ -117,7 +131,7 @@ func TestShortenCustomHTTPHandler(t *testing.T) {
 	req := httptest.NewRequest("POST", "/shorten", bytes.NewBuffer(bodyBytes))
 	respWriter := httptest.NewRecorder()
 
-	shortenMock.EXPECT().ShortenCustom(gomock.Any(), oURL, "sniper", time.Until(reqBody.Expires)).Return(&models.ShortenedURL{
+	shortenMock.EXPECT().ShortenCustom(gomock.Any(), oURL, "sniper", time.Until(reqBody.Expires), models.LinkSettings{}).Return(&models.ShortenedURL{
 		URL:          oURL,
 		ShortURL:     sURL,
 		TTLInSeconds: 1000,
@@ -141,7 +155,7 @@ func TestShortenCustomHTTPHandlerWhenCodeIsAlreadyUsed(t *testing.T) {
 
 	shortenMock := shorten.NewMockShortener(ctrl)
 
-	shortenService := service.NewShortenURLService(shortenMock, nil, nil)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock}, nil, slog.Default())
 
 	reqBody := &service.ShortenCustomRequest{
 		OriginalURL: "https://en.wikipedia.org/wiki/URL_shortening",
@@ -158,7 +172,7 @@ func TestShortenCustomHTTPHandlerWhenCodeIsAlreadyUsed(t *testing.T) {
 	req := httptest.NewRequest("POST", "/shorten", bytes.NewBuffer(bodyBytes))
 	respWriter := httptest.NewRecorder()
 
-	shortenMock.EXPECT().ShortenCustom(gomock.Any(), oURL, "sniper", time.Until(reqBody.Expires)).Return(nil, shorten.ErrNotAvailable)
+	shortenMock.EXPECT().ShortenCustom(gomock.Any(), oURL, "sniper", time.Until(reqBody.Expires), models.LinkSettings{}).Return(nil, shorten.ErrNotAvailable)
 	shortenService.ShortenCustom(respWriter, req)
 	require.Equal(t, respWriter.Result().StatusCode, http.StatusConflict)
 
@@ -176,7 +190,7 @@ func TestDomainReport(t *testing.T) {
 	defer ctrl.Finish()
 
 	storage := storage.NewMockURLReport(ctrl)
-	shortenService := service.NewShortenURLService(nil, storage, nil)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Report: storage}, nil, slog.Default())
 
 	req := httptest.NewRequest("GET", "/report/1", nil)
 	req.SetPathValue("count", "5")
@@ -199,18 +213,19 @@ func TestRedirect(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	shortenMock := shorten.NewMockShortener(ctrl)
 	storage := storage.NewMockURLStorage(ctrl)
-	shortenService := service.NewShortenURLService(nil, nil, storage)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage}, nil, slog.Default())
 
 	req := httptest.NewRequest("GET", "/re45da", nil)
-	req.SetPathValue("count", "5")
-	req.URL, _ = url.Parse("localhost:8080/re45da")
+	req.SetPathValue("code", "re45da")
 	respWriter := httptest.NewRecorder()
 
 	sURL, err := url.Parse("https://snipr.com/sniper")
 	require.Nil(t, err)
 
-	storage.EXPECT().GetOriginalURL(gomock.Any(), "localhost:8080/re45da").Return(&models.ShortenedURL{
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
 		URL:          req.URL,
 		ShortURL:     sURL,
 		TTLInSeconds: 1000,
@@ -223,22 +238,490 @@ func TestRedirectExpired(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	shortenMock := shorten.NewMockShortener(ctrl)
 	storage := storage.NewMockURLStorage(ctrl)
-	shortenService := service.NewShortenURLService(nil, nil, storage)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage}, nil, slog.Default())
 
 	req := httptest.NewRequest("GET", "/re45da", nil)
-	req.SetPathValue("count", "5")
-	req.URL, _ = url.Parse("localhost:8080/re45da")
+	req.SetPathValue("code", "re45da")
 	respWriter := httptest.NewRecorder()
 
 	sURL, err := url.Parse("https://snipr.com/sniper")
 	require.Nil(t, err)
 
-	storage.EXPECT().GetOriginalURL(gomock.Any(), "localhost:8080/re45da").Return(&models.ShortenedURL{
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
 		URL:          req.URL,
 		ShortURL:     sURL,
 		TTLInSeconds: -1000,
 	}, nil)
 	shortenService.Redirect(respWriter, req)
-	require.Equal(t, respWriter.Result().StatusCode, http.StatusNotFound)
+	require.Equal(t, respWriter.Result().StatusCode, http.StatusGone)
+	require.Contains(t, respWriter.Body.String(), "Link expired")
+}
+
+func TestRedirectExpiryFallback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(
+		service.ShortenURLDeps{
+			Shortener: shortenMock,
+			Storage:   storageMock,
+		},
+		&config.RedirectConfig{
+			ExpiryFallback: "https://snipr.com/",
+			DomainFallbacks: []config.DomainFallback{
+				{Domain: "Shop.example.com", URL: "https://shop.example.com/"},
+			},
+		},
+		slog.Default(),
+	)
+
+	for _, tc := range []struct {
+		destination string
+		fallback    string
+		location    string
+	}{
+		{"https://example.com/campaign", "", "https://snipr.com/"},
+		{"https://example.com/campaign", "https://example.com/campaign-over", "https://example.com/campaign-over"},
+		{"https://shop.example.com/sale", "", "https://shop.example.com/"},
+		{"https://shop.example.com/sale", "https://shop.example.com/next-sale", "https://shop.example.com/next-sale"},
+	} {
+		oURL, err := url.Parse(tc.destination)
+		require.Nil(t, err)
+
+		req := httptest.NewRequest("GET", "/re45da", nil)
+		req.SetPathValue("code", "re45da")
+		respWriter := httptest.NewRecorder()
+
+		shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+		storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
+			URL:          oURL,
+			TTLInSeconds: 0,
+			LinkSettings: models.LinkSettings{ExpiryFallback: tc.fallback},
+		}, nil)
+		shortenService.Redirect(respWriter, req)
+
+		require.Equal(t, http.StatusFound, respWriter.Result().StatusCode)
+		require.Equal(t, tc.location, respWriter.Header().Get("Location"))
+		require.Equal(t, "private, no-store", respWriter.Header().Get("Cache-Control"))
+	}
+}
+
+func TestRedirectArchivedLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	archiveMock := storage.NewMockURLArchive(ctrl)
+	shortenService := service.NewShortenURLService(
+		service.ShortenURLDeps{
+			Shortener: shortenMock,
+			Storage:   storageMock,
+			Archive:   archiveMock,
+		},
+		&config.RedirectConfig{
+			DomainFallbacks: []config.DomainFallback{
+				{Domain: "shop.example.com", URL: "https://shop.example.com/"},
+			},
+		},
+		slog.Default(),
+	)
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da").AnyTimes()
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(nil, util.ErrNotFound).AnyTimes()
+
+	redirectTo := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/re45da", nil)
+		req.SetPathValue("code", "re45da")
+		respWriter := httptest.NewRecorder()
+		shortenService.Redirect(respWriter, req)
+		return respWriter
+	}
+
+	// Swept after expiring, the link still answers as expired
+	archiveMock.EXPECT().ListArchived(gomock.Any(), "https://localhost:8080/re45da", 1, 0).Return([]*models.JSONArchivedURL{
+		{URL: "https://example.com/campaign", Reason: models.ArchiveReasonExpired},
+	}, nil)
+	respWriter := redirectTo()
+	require.Equal(t, http.StatusGone, respWriter.Result().StatusCode)
+	require.Contains(t, respWriter.Body.String(), "Link expired")
+
+	archiveMock.EXPECT().ListArchived(gomock.Any(), "https://localhost:8080/re45da", 1, 0).Return([]*models.JSONArchivedURL{
+		{URL: "https://shop.example.com/sale", Reason: models.ArchiveReasonExpired},
+	}, nil)
+	respWriter = redirectTo()
+	require.Equal(t, http.StatusFound, respWriter.Result().StatusCode)
+	require.Equal(t, "https://shop.example.com/", respWriter.Header().Get("Location"))
+
+	archiveMock.EXPECT().ListArchived(gomock.Any(), "https://localhost:8080/re45da", 1, 0).Return([]*models.JSONArchivedURL{
+		{URL: "https://example.com/campaign", Reason: models.ArchiveReasonExpired, LinkSettings: models.LinkSettings{ExpiryFallback: "https://example.com/over"}},
+	}, nil)
+	respWriter = redirectTo()
+	require.Equal(t, http.StatusFound, respWriter.Result().StatusCode)
+	require.Equal(t, "https://example.com/over", respWriter.Header().Get("Location"))
+
+	// Deleted links and codes never used are not found
+	archiveMock.EXPECT().ListArchived(gomock.Any(), "https://localhost:8080/re45da", 1, 0).Return([]*models.JSONArchivedURL{
+		{URL: "https://example.com/campaign", Reason: models.ArchiveReasonDeleted},
+	}, nil)
+	require.Equal(t, http.StatusNotFound, redirectTo().Result().StatusCode)
+
+	archiveMock.EXPECT().ListArchived(gomock.Any(), "https://localhost:8080/re45da", 1, 0).Return([]*models.JSONArchivedURL{}, nil)
+	require.Equal(t, http.StatusNotFound, redirectTo().Result().StatusCode)
+}
+
+func TestRedirectPermanent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(
+		service.ShortenURLDeps{
+			Shortener: shortenMock,
+			Storage:   storage,
+		},
+		&config.RedirectConfig{
+			PermanentMaxAge: 5 * time.Minute,
+		},
+		slog.Default(),
+	)
+
+	req := httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	respWriter := httptest.NewRecorder()
+
+	oURL, err := url.Parse("https://en.wikipedia.org/wiki/URL_shortening")
+	require.Nil(t, err)
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
+		URL:          oURL,
+		TTLInSeconds: 1000,
+		LinkSettings: models.LinkSettings{RedirectType: http.StatusMovedPermanently},
+	}, nil)
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusMovedPermanently, respWriter.Result().StatusCode)
+	require.Equal(t, oURL.String(), respWriter.Header().Get("Location"))
+	require.Equal(t, "public, max-age=300", respWriter.Header().Get("Cache-Control"))
+}
+
+func TestRedirectUsesConfiguredDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(
+		service.ShortenURLDeps{
+			Shortener: shortenMock,
+			Storage:   storage,
+		},
+		&config.RedirectConfig{
+			DefaultType: http.StatusTemporaryRedirect,
+		},
+		slog.Default(),
+	)
+
+	req := httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
+		URL:          req.URL,
+		TTLInSeconds: 1000,
+	}, nil)
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusTemporaryRedirect, respWriter.Result().StatusCode)
+	require.Equal(t, "private, no-store", respWriter.Header().Get("Cache-Control"))
+}
+
+func TestShortenHTTPHandlerInvalidRedirectType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock}, nil, slog.Default())
+
+	bodyBytes := []byte(`{"url": "https://en.wikipedia.org/wiki/URL_shortening", "redirect_type": 303}`)
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBuffer(bodyBytes))
+	respWriter := httptest.NewRecorder()
+
+	settings := models.LinkSettings{RedirectType: http.StatusSeeOther}
+	shortenMock.EXPECT().Shorten(gomock.Any(), gomock.Any(), gomock.Any(), settings).Return(nil, settings.Validate())
+	shortenService.Shorten(respWriter, req)
+	require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+}
+
+func TestRedirectPassthrough(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage}, nil, slog.Default())
+
+	req := httptest.NewRequest("GET", "/re45da/guide?utm_source=x", nil)
+	req.SetPathValue("code", "re45da")
+	req.SetPathValue("rest", "guide")
+	respWriter := httptest.NewRecorder()
+
+	oURL, err := url.Parse("https://example.com/docs")
+	require.Nil(t, err)
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
+		URL:          oURL,
+		TTLInSeconds: 1000,
+		LinkSettings: models.LinkSettings{
+			Passthrough: &models.Passthrough{Query: true, Path: true},
+		},
+	}, nil)
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusFound, respWriter.Result().StatusCode)
+	require.Equal(t, "https://example.com/docs/guide?utm_source=x", respWriter.Header().Get("Location"))
+}
+
+func TestRedirectGeo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	geoMock := geoip.NewMockResolver(ctrl)
+	clicksMock := analytics.NewMockRecorder(ctrl)
+
+	proxies, err := clientip.New([]string{"10.0.0.0/8"})
+	require.Nil(t, err)
+	classifier := redirect.NewClassifier(proxies, geoMock)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage, Classifier: classifier, Clicks: clicksMock}, nil, slog.Default())
+
+	req := httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	req.RemoteAddr = "10.0.0.1:5555"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	req.Header.Set("Referer", "https://news.example.org/item")
+	respWriter := httptest.NewRecorder()
+
+	oURL, err := url.Parse("https://example.com/")
+	require.Nil(t, err)
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
+		URL:          oURL,
+		TTLInSeconds: 1000,
+		LinkSettings: models.LinkSettings{
+			Geo: map[string]string{"DE": "https://example.de/"},
+		},
+	}, nil)
+	geoMock.EXPECT().Country(netip.MustParseAddr("203.0.113.9")).Return("DE")
+
+	var click *models.Click
+	clicksMock.EXPECT().Record(gomock.Any()).Do(func(c *models.Click) { click = c })
+
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusFound, respWriter.Result().StatusCode)
+	require.Equal(t, "https://example.de/", respWriter.Header().Get("Location"))
+	require.Equal(t, "private, no-store", respWriter.Header().Get("Cache-Control"))
+
+	require.NotNil(t, click)
+	require.Equal(t, "https://localhost:8080/re45da", click.ShortURL)
+	require.Equal(t, "DE", click.Country)
+	require.Equal(t, "news.example.org", click.ReferrerHost)
+}
+
+func TestRedirectVariantSetsCookie(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	clicksMock := analytics.NewMockRecorder(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage, Clicks: clicksMock}, nil, slog.Default())
+
+	req := httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	req.AddCookie(&http.Cookie{Name: redirect.VariantCookie("re45da"), Value: "b"})
+	respWriter := httptest.NewRecorder()
+
+	oURL, err := url.Parse("https://example.com/")
+	require.Nil(t, err)
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
+		URL:          oURL,
+		TTLInSeconds: 1000,
+		LinkSettings: models.LinkSettings{
+			Variants: []models.Variant{
+				{Name: "a", URL: "https://example.com/a", Weight: 1},
+				{Name: "b", URL: "https://example.com/b", Weight: 1},
+			},
+		},
+	}, nil)
+	clicksMock.EXPECT().Record(gomock.Any()).Do(func(c *models.Click) {
+		require.Equal(t, "b", c.Variant)
+	})
+
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, "https://example.com/b", respWriter.Header().Get("Location"))
+
+	cookies := respWriter.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, redirect.VariantCookie("re45da"), cookies[0].Name)
+	require.Equal(t, "b", cookies[0].Value)
+	require.Equal(t, "/re45da", cookies[0].Path)
+}
+
+func TestRedirectPasswordProtected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	guard, err := access.NewGuard(&config.PasswordConfig{CookieSecret: "secret", CookieTTL: time.Minute, MaxAttempts: 1, Lockout: time.Minute})
+	require.Nil(t, err)
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storage := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storage, Guard: guard}, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/internal-doc")
+	require.Nil(t, err)
+	settings := models.LinkSettings{Password: "correct horse"}
+	require.Nil(t, settings.HashPassword())
+	link := &models.ShortenedURL{URL: oURL, TTLInSeconds: 1000, LinkSettings: settings}
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da").AnyTimes()
+	storage.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(link, nil).AnyTimes()
+
+	// Visiting shows the form
+	req := httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	respWriter := httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.Contains(t, respWriter.Header().Get("Content-Type"), "text/html")
+	require.Contains(t, respWriter.Body.String(), `<form method="post">`)
+	require.Empty(t, respWriter.Header().Get("Location"))
+
+	// The right password redirects and sets an access cookie
+	req = httptest.NewRequest("POST", "/re45da", strings.NewReader("password=correct+horse"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("code", "re45da")
+	respWriter = httptest.NewRecorder()
+	shortenService.Unlock(respWriter, req)
+	require.Equal(t, http.StatusSeeOther, respWriter.Result().StatusCode)
+	require.Equal(t, "https://example.com/internal-doc", respWriter.Header().Get("Location"))
+	require.Equal(t, "private, no-store", respWriter.Header().Get("Cache-Control"))
+	cookies := respWriter.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	// The cookie lets the visitor straight through
+	req = httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	req.AddCookie(cookies[0])
+	respWriter = httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusFound, respWriter.Result().StatusCode)
+
+	// A wrong password shows the form again, then the client is throttled
+	for _, status := range []int{http.StatusForbidden, http.StatusTooManyRequests} {
+		req = httptest.NewRequest("POST", "/re45da", strings.NewReader("password=guess"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("code", "re45da")
+		respWriter = httptest.NewRecorder()
+		shortenService.Unlock(respWriter, req)
+		require.Equal(t, status, respWriter.Result().StatusCode)
+		require.Empty(t, respWriter.Header().Get("Location"))
+	}
+	require.NotEmpty(t, respWriter.Header().Get("Retry-After"))
+}
+
+func TestRedirectOneTimeLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := filepath.Join(t.TempDir(), "gone.html")
+	require.Nil(t, os.WriteFile(page, []byte(`<p>{{.ShortURL}} was already used</p>`), 0o600))
+	pages, err := service.LoadPages(&config.PagesConfig{Exhausted: page})
+	require.Nil(t, err)
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	webhooks := webhook.NewMockPublisher(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storageMock, Pages: pages, Webhooks: webhooks}, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/download")
+	require.Nil(t, err)
+	sURL, err := url.Parse("https://localhost:8080/re45da")
+	require.Nil(t, err)
+	link := &models.ShortenedURL{URL: oURL, ShortURL: sURL, TTLInSeconds: 1000, LinkSettings: models.LinkSettings{MaxClicks: 1}}
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da").AnyTimes()
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(link, nil).AnyTimes()
+	gomock.InOrder(
+		storageMock.EXPECT().ConsumeClick(gomock.Any(), link).Return(int64(1), nil),
+		storageMock.EXPECT().ConsumeClick(gomock.Any(), link).Return(int64(0), util.ErrExhausted),
+	)
+	// The last click tells webhooks the link is used up
+	webhooks.EXPECT().ClickLimitThreshold(int64(1)).Return(int64(1))
+	webhooks.EXPECT().Publish(gomock.Any(), models.WebhookEventClickLimitReached, gomock.Any()).Do(func(_ context.Context, _ string, data any) {
+		require.Equal(t, int64(1), data.(*models.JSONShortenedURL).Clicks)
+	})
+
+	// HEAD does not use up the link
+	req := httptest.NewRequest("HEAD", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	respWriter := httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusNoContent, respWriter.Result().StatusCode)
+
+	req = httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	respWriter = httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusFound, respWriter.Result().StatusCode)
+	require.Equal(t, "https://example.com/download", respWriter.Header().Get("Location"))
+	require.Equal(t, "private, no-store", respWriter.Header().Get("Cache-Control"))
+
+	respWriter = httptest.NewRecorder()
+	shortenService.Redirect(respWriter, req)
+	require.Equal(t, http.StatusGone, respWriter.Result().StatusCode)
+	require.Empty(t, respWriter.Header().Get("Location"))
+	require.Equal(t, "<p>https://localhost:8080/re45da was already used</p>", respWriter.Body.String())
+}
+
+func TestRedirectNotYetAvailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shortenMock := shorten.NewMockShortener(ctrl)
+	storageMock := storage.NewMockURLStorage(ctrl)
+	shortenService := service.NewShortenURLService(service.ShortenURLDeps{Shortener: shortenMock, Storage: storageMock}, nil, slog.Default())
+
+	oURL, err := url.Parse("https://example.com/secret-launch")
+	require.Nil(t, err)
+	launch := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	req := httptest.NewRequest("GET", "/re45da", nil)
+	req.SetPathValue("code", "re45da")
+	respWriter := httptest.NewRecorder()
+
+	shortenMock.EXPECT().ShortURL("re45da").Return("https://localhost:8080/re45da")
+	storageMock.EXPECT().GetOriginalURL(gomock.Any(), "https://localhost:8080/re45da").Return(&models.ShortenedURL{
+		URL:          oURL,
+		TTLInSeconds: 1000,
+		LinkSettings: models.LinkSettings{NotBefore: &launch},
+	}, nil)
+	shortenService.Redirect(respWriter, req)
+
+	require.Equal(t, http.StatusForbidden, respWriter.Result().StatusCode)
+	require.Empty(t, respWriter.Header().Get("Location"))
+	require.NotContains(t, respWriter.Body.String(), "secret-launch")
+	require.Equal(t, launch.UTC().Format(http.TimeFormat), respWriter.Header().Get("Retry-After"))
+	require.Equal(t, "private, no-store", respWriter.Header().Get("Cache-Control"))
 }
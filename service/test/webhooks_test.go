@@ -0,0 +1,135 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sri-shubham/snipr/internal/audit"
+	"github.com/sri-shubham/snipr/internal/auth"
+	"github.com/sri-shubham/snipr/service"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhookEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	webhookService := service.NewWebhookService(storageMock, nil, nil, nil, slog.Default())
+
+	for _, body := range []string{
+		`{"url": "ftp://example.com/hook"}`,
+		`{"url": "/hook"}`,
+		`{"url": "http://169.254.169.254/latest"}`,
+		`{"url": "http://localhost:8080/hook"}`,
+		`{"url": "https://example.com/hook", "events": ["link.renamed"]}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(body))
+		respWriter := httptest.NewRecorder()
+		webhookService.CreateEndpoint(respWriter, req)
+		require.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode, body)
+	}
+
+	storageMock.EXPECT().CreateEndpoint(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, endpoint *models.WebhookEndpoint) error {
+		require.Equal(t, "https://example.com/hook", endpoint.URL)
+		require.Equal(t, []string{models.WebhookEventLinkCreated}, endpoint.Events)
+		require.Len(t, endpoint.Secret, 64)
+		endpoint.ID = 3
+		return nil
+	})
+
+	req := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(`{"url": "https://example.com/hook", "events": ["link.created"]}`))
+	respWriter := httptest.NewRecorder()
+	webhookService.CreateEndpoint(respWriter, req)
+	require.Equal(t, http.StatusCreated, respWriter.Result().StatusCode)
+
+	resp := &models.WebhookEndpoint{}
+	require.Nil(t, json.Unmarshal(respWriter.Body.Bytes(), resp))
+	require.Equal(t, int64(3), resp.ID)
+	require.NotEmpty(t, resp.Secret)
+}
+
+func TestListWebhookEndpointsHidesSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	webhookService := service.NewWebhookService(storageMock, nil, nil, nil, slog.Default())
+
+	storageMock.EXPECT().ListEndpoints(gomock.Any()).Return([]*models.WebhookEndpoint{
+		{ID: 1, URL: "https://example.com/hook", Secret: "secret"},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/api/webhooks", nil)
+	respWriter := httptest.NewRecorder()
+	webhookService.ListEndpoints(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+	require.NotContains(t, respWriter.Body.String(), "secret")
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	webhookService := service.NewWebhookService(storageMock, nil, nil, nil, slog.Default())
+
+	storageMock.EXPECT().GetEndpoint(gomock.Any(), int64(1)).Return(&models.WebhookEndpoint{ID: 1}, nil)
+	storageMock.EXPECT().ListDeliveries(gomock.Any(), int64(1), models.WebhookDeliveryDead, 10, 0).Return([]*models.WebhookDelivery{
+		{ID: 9, EndpointID: 1, Status: models.WebhookDeliveryDead, Attempts: 8},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/api/webhooks/1/deliveries?status=dead&limit=10", nil)
+	req.SetPathValue("id", "1")
+	respWriter := httptest.NewRecorder()
+	webhookService.Deliveries(respWriter, req)
+	require.Equal(t, http.StatusOK, respWriter.Result().StatusCode)
+
+	resp := &service.WebhookDeliveriesResponse{}
+	require.Nil(t, json.Unmarshal(respWriter.Body.Bytes(), resp))
+	require.Equal(t, 1, resp.Count)
+	require.Equal(t, 8, resp.Items[0].Attempts)
+
+	storageMock.EXPECT().GetEndpoint(gomock.Any(), int64(2)).Return(nil, util.ErrNotFound)
+	req = httptest.NewRequest("GET", "/api/webhooks/2/deliveries", nil)
+	req.SetPathValue("id", "2")
+	respWriter = httptest.NewRecorder()
+	webhookService.Deliveries(respWriter, req)
+	require.Equal(t, http.StatusNotFound, respWriter.Result().StatusCode)
+}
+
+func TestDeleteWebhookEndpointIsAudited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageMock := storage.NewMockWebhookStorage(ctrl)
+	events := &bytes.Buffer{}
+	auditLog := audit.New(nil, slog.Default(), audit.NewJSONLinesSink(events))
+	webhookService := service.NewWebhookService(storageMock, nil, auditLog, nil, slog.Default())
+
+	storageMock.EXPECT().GetEndpoint(gomock.Any(), int64(4)).Return(&models.WebhookEndpoint{ID: 4, URL: "https://example.com/hook", Secret: "s3cret"}, nil)
+	storageMock.EXPECT().DeleteEndpoint(gomock.Any(), int64(4)).Return(nil)
+
+	req := httptest.NewRequest("DELETE", "/api/webhooks/4", nil)
+	req.SetPathValue("id", "4")
+	req = req.WithContext(auth.WithIdentity(req.Context(), "alice"))
+	respWriter := httptest.NewRecorder()
+	webhookService.DeleteEndpoint(respWriter, req)
+	require.Equal(t, http.StatusNoContent, respWriter.Result().StatusCode)
+
+	event := &models.AuditEvent{}
+	require.Nil(t, json.Unmarshal(events.Bytes(), event))
+	require.Equal(t, audit.ActionWebhookDelete, event.Action)
+	require.Equal(t, "alice", event.Actor)
+	require.JSONEq(t, `"https://example.com/hook"`, string(event.Diff["url"].Old))
+	require.NotContains(t, events.String(), "s3cret")
+}
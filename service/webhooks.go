@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sri-shubham/snipr/internal/audit"
+	"github.com/sri-shubham/snipr/internal/config"
+	"github.com/sri-shubham/snipr/internal/egress"
+	"github.com/sri-shubham/snipr/internal/webhook"
+	"github.com/sri-shubham/snipr/storage"
+	"github.com/sri-shubham/snipr/storage/models"
+)
+
+// WebhookService manages webhook endpoints and shows their deliveries.
+type WebhookService interface {
+	CreateEndpoint(w http.ResponseWriter, r *http.Request)
+	ListEndpoints(w http.ResponseWriter, r *http.Request)
+	DeleteEndpoint(w http.ResponseWriter, r *http.Request)
+	Deliveries(w http.ResponseWriter, r *http.Request)
+}
+
+type webhookServiceImpl struct {
+	storage storage.WebhookStorage
+	tx      storage.Transactor
+	audit   *audit.Log
+	conf    config.WebhookConfig
+	logger  *slog.Logger
+}
+
+func NewWebhookService(storage storage.WebhookStorage, tx storage.Transactor, auditLog *audit.Log, conf *config.WebhookConfig, logger *slog.Logger) WebhookService {
+	webhookConf := config.WebhookConfig{}
+	if conf != nil {
+		webhookConf = *conf
+	}
+
+	return &webhookServiceImpl{
+		storage: storage,
+		tx:      tx,
+		audit:   auditLog,
+		conf:    webhookConf,
+		logger:  logger,
+	}
+}
+
+type CreateWebhookRequest struct {
+	URL string `json:"url"`
+	// Events subscribes to some events only; all by default.
+	Events []string `json:"events"`
+	// Secret signs deliveries. One is generated if it is empty.
+	Secret string `json:"secret"`
+}
+
+// CreateEndpoint implements WebhookService. The response is the only one
+// carrying the endpoint secret. Endpoints on internal addresses are refused
+// unless AllowPrivateNetworks is set; deliveries refuse them regardless of
+// what the name resolves to later.
+func (s *webhookServiceImpl) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	var requestBody CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		WriteError(w, r, s.logger, Validation(err), "Failed to unmarshal JSON")
+		return
+	}
+
+	endpointURL, err := url.Parse(requestBody.URL)
+	if err != nil || (endpointURL.Scheme != "http" && endpointURL.Scheme != "https") || endpointURL.Host == "" {
+		WriteError(w, r, s.logger, Validation(errors.New("invalid url")), "Webhook url should be an absolute http or https url")
+		return
+	}
+	if !s.conf.AllowPrivateNetworks {
+		if err := egress.CheckHost(endpointURL.Hostname()); err != nil {
+			WriteError(w, r, s.logger, Validation(err), "Webhook url should not point at an internal address")
+			return
+		}
+	}
+	for _, event := range requestBody.Events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			WriteError(w, r, s.logger, Validation(fmt.Errorf("unknown event %q", event)), "Unknown webhook event")
+			return
+		}
+	}
+	if requestBody.Secret == "" {
+		requestBody.Secret = webhook.NewSecret()
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:       endpointURL.String(),
+		Events:    requestBody.Events,
+		Secret:    requestBody.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	err = inTx(r.Context(), s.tx, func(ctx context.Context) error {
+		if err := s.storage.CreateEndpoint(ctx, endpoint); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, r, audit.ActionWebhookCreate, "", nil, auditedEndpoint(endpoint))
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to create webhook endpoint")
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "Created webhook endpoint", slog.Int64("endpoint", endpoint.ID))
+
+	out, err := json.Marshal(endpoint)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusCreated)
+}
+
+type WebhookEndpointsResponse struct {
+	Items []*models.WebhookEndpoint `json:"items"`
+	Count int                       `json:"count"`
+}
+
+// ListEndpoints implements WebhookService.
+func (s *webhookServiceImpl) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := s.storage.ListEndpoints(r.Context())
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to list webhook endpoints")
+		return
+	}
+	for _, endpoint := range endpoints {
+		endpoint.Secret = ""
+	}
+
+	out, err := json.Marshal(WebhookEndpointsResponse{
+		Items: endpoints,
+		Count: len(endpoints),
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
+
+// DeleteEndpoint implements WebhookService. Its queued deliveries and
+// delivery log are dropped with it.
+func (s *webhookServiceImpl) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	id, ok := endpointID(w, r, s.logger)
+	if !ok {
+		return
+	}
+
+	endpoint, err := s.storage.GetEndpoint(r.Context(), id)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to get webhook endpoint")
+		return
+	}
+
+	err = inTx(r.Context(), s.tx, func(ctx context.Context) error {
+		if err := s.storage.DeleteEndpoint(ctx, id); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, r, audit.ActionWebhookDelete, "", auditedEndpoint(endpoint), nil)
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to delete webhook endpoint")
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "Deleted webhook endpoint", slog.Int64("endpoint", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type WebhookDeliveriesResponse struct {
+	Items []*models.WebhookDelivery `json:"items"`
+	Count int                       `json:"count"`
+}
+
+// Deliveries implements WebhookService. It pages through the deliveries to
+// an endpoint, newest first, with the limit and offset query parameters;
+// status narrows the list to pending, delivered or dead deliveries.
+func (s *webhookServiceImpl) Deliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := endpointID(w, r, s.logger)
+	if !ok {
+		return
+	}
+	limit, offset, ok := page(w, r, s.logger)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		WriteError(w, r, s.logger, Validation(errors.New("invalid status")), "Status should be pending, delivered or dead")
+		return
+	}
+
+	_, err := s.storage.GetEndpoint(r.Context(), id)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to get webhook endpoint")
+		return
+	}
+
+	items, err := s.storage.ListDeliveries(r.Context(), id, status, limit, offset)
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to list webhook deliveries")
+		return
+	}
+
+	out, err := json.Marshal(WebhookDeliveriesResponse{
+		Items: items,
+		Count: len(items),
+	})
+	if err != nil {
+		WriteError(w, r, s.logger, err, "Failed to marshal response")
+		return
+	}
+
+	WriteJsonResponseWithCode(w, out, http.StatusOK)
+}
+
+// endpointID reads the id path value, writing an error response if it is
+// invalid.
+func endpointID(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		WriteError(w, r, logger, Validation(errors.New("invalid id")), "Id should be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// auditedEndpoint is what the audit log keeps of endpoint: everything but
+// its secret.
+func auditedEndpoint(endpoint *models.WebhookEndpoint) *models.WebhookEndpoint {
+	audited := *endpoint
+	audited.Secret = ""
+	return &audited
+}
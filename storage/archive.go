@@ -0,0 +1,41 @@
+//go:generate mockgen -source=archive.go -destination archive_mock.go -package storage
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/storage/persist/postgres"
+	"github.com/uptrace/bun"
+)
+
+// URLArchive keeps links that were removed, so their codes are not handed
+// out again too soon and they can still be audited.
+type URLArchive interface {
+	// ArchiveShortURL moves the link served under shortURL out of the live
+	// tables, recording how often it was clicked. Its click rows are kept.
+	// It returns util.ErrNotFound if there is no such link.
+	ArchiveShortURL(ctx context.Context, shortURL string, reason string) error
+	// ArchiveExpired archives up to limit links that expired before cutoff
+	// and returns them as archived. It returns util.ErrLocked while another
+	// instance is archiving.
+	ArchiveExpired(ctx context.Context, cutoff time.Time, limit int) ([]*models.JSONArchivedURL, error)
+	// ClaimExpired marks up to limit live links that expired before now as
+	// reported and returns them. A link is returned once per expiry, again
+	// only if its expiry is changed and passes.
+	ClaimExpired(ctx context.Context, now time.Time, limit int) ([]*models.ShortenedURL, error)
+	// ArchivedSince reports whether shortURL was archived after since.
+	ArchivedSince(ctx context.Context, shortURL string, since time.Time) (bool, error)
+	// ListArchived returns archived links, most recently archived first.
+	// An empty shortURL lists every link.
+	ListArchived(ctx context.Context, shortURL string, limit int, offset int) ([]*models.JSONArchivedURL, error)
+}
+
+func NewPGURLArchive(db *bun.DB, logger *slog.Logger) URLArchive {
+	return &postgres.PGURLArchive{
+		DB:     db,
+		Logger: logger,
+	}
+}
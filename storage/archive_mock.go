@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: archive.go
+
+// Package storage is a generated GoMock package.
+package storage
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sri-shubham/snipr/storage/models"
+)
+
+// MockURLArchive is a mock of URLArchive interface.
+type MockURLArchive struct {
+	ctrl     *gomock.Controller
+	recorder *MockURLArchiveMockRecorder
+}
+
+// MockURLArchiveMockRecorder is the mock recorder for MockURLArchive.
+type MockURLArchiveMockRecorder struct {
+	mock *MockURLArchive
+}
+
+// NewMockURLArchive creates a new mock instance.
+func NewMockURLArchive(ctrl *gomock.Controller) *MockURLArchive {
+	mock := &MockURLArchive{ctrl: ctrl}
+	mock.recorder = &MockURLArchiveMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockURLArchive) EXPECT() *MockURLArchiveMockRecorder {
+	return m.recorder
+}
+
+// ArchiveExpired mocks base method.
+func (m *MockURLArchive) ArchiveExpired(ctx context.Context, cutoff time.Time, limit int) ([]*models.JSONArchivedURL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchiveExpired", ctx, cutoff, limit)
+	ret0, _ := ret[0].([]*models.JSONArchivedURL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ArchiveExpired indicates an expected call of ArchiveExpired.
+func (mr *MockURLArchiveMockRecorder) ArchiveExpired(ctx, cutoff, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveExpired", reflect.TypeOf((*MockURLArchive)(nil).ArchiveExpired), ctx, cutoff, limit)
+}
+
+// ArchiveShortURL mocks base method.
+func (m *MockURLArchive) ArchiveShortURL(ctx context.Context, shortURL, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchiveShortURL", ctx, shortURL, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ArchiveShortURL indicates an expected call of ArchiveShortURL.
+func (mr *MockURLArchiveMockRecorder) ArchiveShortURL(ctx, shortURL, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveShortURL", reflect.TypeOf((*MockURLArchive)(nil).ArchiveShortURL), ctx, shortURL, reason)
+}
+
+// ArchivedSince mocks base method.
+func (m *MockURLArchive) ArchivedSince(ctx context.Context, shortURL string, since time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchivedSince", ctx, shortURL, since)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ArchivedSince indicates an expected call of ArchivedSince.
+func (mr *MockURLArchiveMockRecorder) ArchivedSince(ctx, shortURL, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchivedSince", reflect.TypeOf((*MockURLArchive)(nil).ArchivedSince), ctx, shortURL, since)
+}
+
+// ClaimExpired mocks base method.
+func (m *MockURLArchive) ClaimExpired(ctx context.Context, now time.Time, limit int) ([]*models.ShortenedURL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimExpired", ctx, now, limit)
+	ret0, _ := ret[0].([]*models.ShortenedURL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimExpired indicates an expected call of ClaimExpired.
+func (mr *MockURLArchiveMockRecorder) ClaimExpired(ctx, now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimExpired", reflect.TypeOf((*MockURLArchive)(nil).ClaimExpired), ctx, now, limit)
+}
+
+// ListArchived mocks base method.
+func (m *MockURLArchive) ListArchived(ctx context.Context, shortURL string, limit, offset int) ([]*models.JSONArchivedURL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListArchived", ctx, shortURL, limit, offset)
+	ret0, _ := ret[0].([]*models.JSONArchivedURL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListArchived indicates an expected call of ListArchived.
+func (mr *MockURLArchiveMockRecorder) ListArchived(ctx, shortURL, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListArchived", reflect.TypeOf((*MockURLArchive)(nil).ListArchived), ctx, shortURL, limit, offset)
+}
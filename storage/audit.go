@@ -0,0 +1,26 @@
+//go:generate mockgen -source=audit.go -destination audit_mock.go -package storage
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/storage/persist/postgres"
+	"github.com/uptrace/bun"
+)
+
+// AuditStorage is an append only store of audit events.
+type AuditStorage interface {
+	// StoreAuditEvent appends event, setting its ID.
+	StoreAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	// ListAuditEvents returns the events matching filter, newest first.
+	ListAuditEvents(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEvent, error)
+}
+
+func NewPGAuditStorage(db *bun.DB, logger *slog.Logger) AuditStorage {
+	return &postgres.PGAuditStorage{
+		DB:     db,
+		Logger: logger,
+	}
+}
@@ -1,6 +1,7 @@
 package rediscache
 
 import (
+	"log/slog"
 	"sync"
 
 	"github.com/redis/go-redis/v9"
@@ -11,10 +12,13 @@ import (
 var rDB *redis.Client
 var once *sync.Once = &sync.Once{}
 
-func GetDB(config *config.RedisConfig) (*redis.Client, error) {
+func GetDB(config *config.RedisConfig, logger *slog.Logger) (*redis.Client, error) {
 	var err error
 	once.Do(func() {
-		rDB, err = util.OpenRedisConn(config)
+		rDB, err = util.OpenRedisConn(config, logger)
+		if err == nil {
+			rDB.AddHook(TracingHook{})
+		}
 	})
 	if err != nil {
 		return nil, err
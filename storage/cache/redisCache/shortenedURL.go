@@ -3,51 +3,139 @@ package rediscache
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
+	"strconv"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sri-shubham/snipr/storage/models"
 	"github.com/sri-shubham/snipr/util"
 )
 
+// consumeClickScript counts a click in KEYS[2] unless the limit in ARGV[1]
+// is reached, returning the clicks used or 0 at the limit. The counter
+// expires together with the link in KEYS[1].
+var consumeClickScript = redis.NewScript(`
+local clicks = redis.call("INCR", KEYS[2])
+if clicks > tonumber(ARGV[1]) then
+	redis.call("DECR", KEYS[2])
+	return 0
+end
+if clicks == 1 then
+	local ttl = redis.call("PTTL", KEYS[1])
+	if ttl > 0 then
+		redis.call("PEXPIRE", KEYS[2], ttl)
+	end
+end
+return clicks
+`)
+
+// redisShortenedURL is the cached form of a link: its API model plus the
+// password hash, which the API model never carries.
+type redisShortenedURL struct {
+	models.JSONShortenedURL
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+func presentRedisShortenedURL(in *models.ShortenedURL) *redisShortenedURL {
+	return &redisShortenedURL{
+		JSONShortenedURL: *models.PresentJsonShortenedURLModel(in),
+		PasswordHash:     in.PasswordHash,
+	}
+}
+
 type RedisShortenedURLStorage struct {
-	Redis *redis.Client
+	Redis  *redis.Client
+	Logger *slog.Logger
 }
 
 // GetOriginalURL implements storage.URLStorage.
 func (p RedisShortenedURLStorage) GetOriginalURL(ctx context.Context, shortURL string) (*models.ShortenedURL, error) {
-	value, err := p.Redis.Get(ctx, shortURL).Result()
+	values, err := p.Redis.MGet(ctx, shortURL, clicksKey(shortURL)).Result()
 	if err != nil {
 		return nil, util.PresentStorageErrors(err)
 	}
+	value, ok := values[0].(string)
+	if !ok {
+		return nil, util.ErrNotFound
+	}
 
-	rShortenedURL := &models.JSONShortenedURL{}
+	rShortenedURL := &redisShortenedURL{}
 	err = json.Unmarshal([]byte(value), rShortenedURL)
 	if err != nil {
 		return nil, util.PresentStorageErrors(err)
 	}
+	if clicks, ok := values[1].(string); ok {
+		rShortenedURL.Clicks, _ = strconv.ParseInt(clicks, 10, 64)
+	}
 
-	shortenedURL, err := models.MapJsonShortenedURLModel(rShortenedURL)
+	shortenedURL, err := models.MapJsonShortenedURLModel(&rShortenedURL.JSONShortenedURL)
 	if err != nil {
 		return nil, err
 	}
+	shortenedURL.PasswordHash = rShortenedURL.PasswordHash
 
 	return shortenedURL, nil
 }
 
 // StoreShortURL implements storage.URLStorage.
 func (p RedisShortenedURLStorage) StoreShortURL(ctx context.Context, shortenedURL *models.ShortenedURL) error {
-	redisShortenedURL := models.PresentJsonShortenedURLModel(shortenedURL)
-	jsonBytes, err := json.Marshal(redisShortenedURL)
+	jsonBytes, err := json.Marshal(presentRedisShortenedURL(shortenedURL))
 	if err != nil {
 		return err
 	}
 
-	_, err = p.Redis.Set(ctx, shortenedURL.ShortURL.String(), string(jsonBytes),
-		util.JitteredCacheDuration(util.DEFAULT_MIN_CACHE_TIME, util.DEFAULT_MAX_CACHE_TIME)).
+	expiry := util.JitteredCacheDuration(util.DEFAULT_MIN_CACHE_TIME, util.DEFAULT_MAX_CACHE_TIME)
+	_, err = p.Redis.Set(ctx, shortenedURL.ShortURL.String(), string(jsonBytes), expiry).
 		Result()
 	if err != nil {
 		return err
 	}
 
+	p.Logger.DebugContext(ctx, "Cached short url",
+		slog.String("short_url", shortenedURL.ShortURL.String()),
+		slog.Duration("expiry", expiry))
+
+	return nil
+}
+
+// UpdateShortURL implements storage.URLStorage. The cached entry keeps its
+// remaining expiry.
+func (p RedisShortenedURLStorage) UpdateShortURL(ctx context.Context, shortenedURL *models.ShortenedURL) error {
+	jsonBytes, err := json.Marshal(presentRedisShortenedURL(shortenedURL))
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Redis.SetArgs(ctx, shortenedURL.ShortURL.String(), string(jsonBytes), redis.SetArgs{
+		Mode:    "XX",
+		KeepTTL: true,
+	}).Result()
+	if err != nil {
+		// XX makes redis reply nil when the key does not exist
+		return util.PresentStorageErrors(err)
+	}
+
 	return nil
 }
+
+// ConsumeClick implements storage.URLStorage. Clicks are counted in a
+// separate key so the cached link is never rewritten on the redirect path.
+func (p RedisShortenedURLStorage) ConsumeClick(ctx context.Context, shortenedURL *models.ShortenedURL) (int64, error) {
+	key := shortenedURL.ShortURL.String()
+	if shortenedURL.MaxClicks <= 0 {
+		return 0, nil
+	}
+
+	clicks, err := consumeClickScript.Run(ctx, p.Redis, []string{key, clicksKey(key)}, shortenedURL.MaxClicks).Int64()
+	if err != nil {
+		return 0, util.PresentStorageErrors(err)
+	}
+	if clicks == 0 {
+		return 0, util.ErrExhausted
+	}
+	return clicks, nil
+}
+
+func clicksKey(shortURL string) string {
+	return shortURL + ":clicks"
+}
@@ -2,12 +2,14 @@ package test
 
 import (
 	"context"
+	"log/slog"
 	"net/url"
 	"testing"
 
 	"github.com/sri-shubham/snipr/internal/config"
 	rediscache "github.com/sri-shubham/snipr/storage/cache/redisCache"
 	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,13 +21,14 @@ func init() {
 		panic(err)
 	}
 
-	rDB, err := rediscache.GetDB(config.Redis)
+	rDB, err := rediscache.GetDB(config.Redis, slog.Default())
 	if err != nil {
 		panic(err)
 	}
 
 	storage = &rediscache.RedisShortenedURLStorage{
-		Redis: rDB,
+		Redis:  rDB,
+		Logger: slog.Default(),
 	}
 
 	err = rDB.FlushDB(context.Background()).Err()
@@ -53,3 +56,27 @@ func TestStoreShortURL(t *testing.T) {
 	require.Equal(t, returnedShortUrl.TTLInSeconds, shortendedURL.TTLInSeconds)
 	require.NotNil(t, shortendedURL.CreatedAt)
 }
+
+func TestConsumeClick(t *testing.T) {
+	origUrl, _ := url.Parse("github.com/sri-shubham/Snipr")
+	shortUrl, _ := url.Parse("snipr.com/onetime")
+	shortendedURL := &models.ShortenedURL{
+		URL:          origUrl,
+		ShortURL:     shortUrl,
+		TTLInSeconds: 10000,
+		LinkSettings: models.LinkSettings{MaxClicks: 1},
+	}
+
+	err := storage.StoreShortURL(context.Background(), shortendedURL)
+	require.Nil(t, err)
+
+	clicks, err := storage.ConsumeClick(context.Background(), shortendedURL)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), clicks)
+	_, err = storage.ConsumeClick(context.Background(), shortendedURL)
+	require.ErrorIs(t, err, util.ErrExhausted)
+
+	returnedShortUrl, err := storage.GetOriginalURL(context.Background(), shortUrl.String())
+	require.Nil(t, err)
+	require.Equal(t, int64(1), returnedShortUrl.Clicks)
+}
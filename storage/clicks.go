@@ -0,0 +1,27 @@
+//go:generate mockgen -source=clicks.go -destination clicks_mock.go -package storage
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/storage/persist/postgres"
+	"github.com/uptrace/bun"
+)
+
+type ClickStorage interface {
+	StoreClicks(ctx context.Context, clicks []*models.Click) error
+	// ClickStats counts the clicks of shortURL since the link was created
+	// per variant, leaving out clicks of archived links that had the same
+	// code. Clicks served without a variant are counted under "".
+	ClickStats(ctx context.Context, shortURL string, since time.Time) (*models.JSONLinkStats, error)
+}
+
+func NewPGClickStorage(db *bun.DB, logger *slog.Logger) ClickStorage {
+	return &postgres.PGClickStorage{
+		DB:     db,
+		Logger: logger,
+	}
+}
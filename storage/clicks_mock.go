@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: clicks.go
+
+// Package storage is a generated GoMock package.
+package storage
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sri-shubham/snipr/storage/models"
+)
+
+// MockClickStorage is a mock of ClickStorage interface.
+type MockClickStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockClickStorageMockRecorder
+}
+
+// MockClickStorageMockRecorder is the mock recorder for MockClickStorage.
+type MockClickStorageMockRecorder struct {
+	mock *MockClickStorage
+}
+
+// NewMockClickStorage creates a new mock instance.
+func NewMockClickStorage(ctrl *gomock.Controller) *MockClickStorage {
+	mock := &MockClickStorage{ctrl: ctrl}
+	mock.recorder = &MockClickStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClickStorage) EXPECT() *MockClickStorageMockRecorder {
+	return m.recorder
+}
+
+// ClickStats mocks base method.
+func (m *MockClickStorage) ClickStats(ctx context.Context, shortURL string, since time.Time) (*models.JSONLinkStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClickStats", ctx, shortURL, since)
+	ret0, _ := ret[0].(*models.JSONLinkStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClickStats indicates an expected call of ClickStats.
+func (mr *MockClickStorageMockRecorder) ClickStats(ctx, shortURL, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClickStats", reflect.TypeOf((*MockClickStorage)(nil).ClickStats), ctx, shortURL, since)
+}
+
+// StoreClicks mocks base method.
+func (m *MockClickStorage) StoreClicks(ctx context.Context, clicks []*models.Click) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreClicks", ctx, clicks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreClicks indicates an expected call of StoreClicks.
+func (mr *MockClickStorageMockRecorder) StoreClicks(ctx, clicks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreClicks", reflect.TypeOf((*MockClickStorage)(nil).StoreClicks), ctx, clicks)
+}
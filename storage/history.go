@@ -0,0 +1,30 @@
+//go:generate mockgen -source=history.go -destination history_mock.go -package storage
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/storage/persist/postgres"
+	"github.com/uptrace/bun"
+)
+
+// LinkHistory keeps an append only log of changes to links.
+type LinkHistory interface {
+	// RecordRevision appends revision, setting its ID.
+	RecordRevision(ctx context.Context, revision *models.LinkRevision) error
+	// ListRevisions returns the revisions of shortURL recorded after since,
+	// newest first.
+	ListRevisions(ctx context.Context, shortURL string, since time.Time, limit int, offset int) ([]*models.LinkRevision, error)
+	// GetRevision returns revision id of shortURL or util.ErrNotFound.
+	GetRevision(ctx context.Context, shortURL string, id int64) (*models.LinkRevision, error)
+}
+
+func NewPGLinkHistory(db *bun.DB, logger *slog.Logger) LinkHistory {
+	return &postgres.PGLinkHistory{
+		DB:     db,
+		Logger: logger,
+	}
+}
@@ -0,0 +1,81 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: history.go
+
+// Package storage is a generated GoMock package.
+package storage
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sri-shubham/snipr/storage/models"
+)
+
+// MockLinkHistory is a mock of LinkHistory interface.
+type MockLinkHistory struct {
+	ctrl     *gomock.Controller
+	recorder *MockLinkHistoryMockRecorder
+}
+
+// MockLinkHistoryMockRecorder is the mock recorder for MockLinkHistory.
+type MockLinkHistoryMockRecorder struct {
+	mock *MockLinkHistory
+}
+
+// NewMockLinkHistory creates a new mock instance.
+func NewMockLinkHistory(ctrl *gomock.Controller) *MockLinkHistory {
+	mock := &MockLinkHistory{ctrl: ctrl}
+	mock.recorder = &MockLinkHistoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLinkHistory) EXPECT() *MockLinkHistoryMockRecorder {
+	return m.recorder
+}
+
+// GetRevision mocks base method.
+func (m *MockLinkHistory) GetRevision(ctx context.Context, shortURL string, id int64) (*models.LinkRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevision", ctx, shortURL, id)
+	ret0, _ := ret[0].(*models.LinkRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevision indicates an expected call of GetRevision.
+func (mr *MockLinkHistoryMockRecorder) GetRevision(ctx, shortURL, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevision", reflect.TypeOf((*MockLinkHistory)(nil).GetRevision), ctx, shortURL, id)
+}
+
+// ListRevisions mocks base method.
+func (m *MockLinkHistory) ListRevisions(ctx context.Context, shortURL string, since time.Time, limit, offset int) ([]*models.LinkRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRevisions", ctx, shortURL, since, limit, offset)
+	ret0, _ := ret[0].([]*models.LinkRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRevisions indicates an expected call of ListRevisions.
+func (mr *MockLinkHistoryMockRecorder) ListRevisions(ctx, shortURL, since, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRevisions", reflect.TypeOf((*MockLinkHistory)(nil).ListRevisions), ctx, shortURL, since, limit, offset)
+}
+
+// RecordRevision mocks base method.
+func (m *MockLinkHistory) RecordRevision(ctx context.Context, revision *models.LinkRevision) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordRevision", ctx, revision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordRevision indicates an expected call of RecordRevision.
+func (mr *MockLinkHistoryMockRecorder) RecordRevision(ctx, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordRevision", reflect.TypeOf((*MockLinkHistory)(nil).RecordRevision), ctx, revision)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Reasons a link was archived.
+const (
+	ArchiveReasonExpired = "expired"
+	ArchiveReasonDeleted = "deleted"
+)
+
+// JSONArchivedURL is a link as it was when it was archived. Clicks are
+// the clicks recorded while it was live.
+type JSONArchivedURL struct {
+	URL               string    `json:"url"`
+	ShortURL          string    `json:"short_url"`
+	CreatedAt         time.Time `json:"created_at"`
+	Expires           time.Time `json:"expires"`
+	Reason            string    `json:"reason"`
+	ArchivedAt        time.Time `json:"archived_at"`
+	Clicks            int64     `json:"clicks"`
+	PasswordProtected bool      `json:"password_protected"`
+	LinkSettings
+}
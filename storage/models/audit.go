@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent records one mutating operation.
+type AuditEvent struct {
+	ID        int64     `json:"id,omitempty"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	SourceIP  string    `json:"source_ip"`
+	RequestID string    `json:"request_id,omitempty"`
+	// ShortURL is the link operated on.
+	ShortURL string `json:"short_url,omitempty"`
+	// Diff holds the fields the operation changed.
+	Diff map[string]*AuditChange `json:"diff,omitempty"`
+}
+
+// AuditChange is the JSON value of a field before and after an operation.
+// Old is null for created fields and New for removed ones.
+type AuditChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+// AuditFilter selects audit events. Zero fields match every event.
+type AuditFilter struct {
+	Action   string
+	Actor    string
+	ShortURL string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	Offset   int
+}
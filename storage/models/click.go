@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Click is a single redirect served for a short url.
+type Click struct {
+	ShortURL string
+	Time     time.Time
+	// Country is the visitor's ISO 3166-1 alpha-2 country code, empty when
+	// no GeoIP database is configured or the address is unknown.
+	Country  string
+	Platform string
+	Bot      bool
+	// ReferrerHost is the host of the Referer header; the full referrer
+	// is not stored.
+	ReferrerHost string
+	// Variant is the A/B variant the visitor was sent to, if any.
+	Variant string
+}
+
+// JSONLinkStats summarises the clicks of a link.
+type JSONLinkStats struct {
+	ShortURL string              `json:"short_url"`
+	Clicks   int                 `json:"clicks"`
+	Variants []*JSONVariantStats `json:"variants"`
+}
+
+type JSONVariantStats struct {
+	Variant string `json:"variant"`
+	Clicks  int    `json:"clicks"`
+}
@@ -0,0 +1,377 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidLinkSettings = errors.New("invalid link settings")
+
+const (
+	// QueryConflictRequest lets incoming query parameters replace
+	// parameters of the same name on the destination.
+	QueryConflictRequest = "request"
+	// QueryConflictDestination keeps the destination's parameters and only
+	// adds incoming parameters it does not have.
+	QueryConflictDestination = "destination"
+	// QueryConflictAppend keeps both values.
+	QueryConflictAppend = "append"
+)
+
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformDesktop = "desktop"
+)
+
+// ParamPlaceholders lists the placeholders a ParamTemplate value may use,
+// written as {name}.
+var ParamPlaceholders = []string{
+	"referrer_host",
+	"referrer",
+	"date",
+	"code",
+}
+
+// ParamPlaceholderRegexp matches a placeholder in a ParamTemplate value.
+var ParamPlaceholderRegexp = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+var countryCodeRegexp = regexp.MustCompile(`^[A-Z]{2}$`)
+
+var variantNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+var languageTagRegexp = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+const (
+	maxTemplateParams = 32
+	maxTargetRules    = 32
+	maxGeoTargets     = 250
+	maxVariants       = 16
+	maxVariantWeight  = 10000
+	maxWindows        = 16
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordLength = 72
+)
+
+// LinkSettings holds the per link behaviour chosen when a link is created
+// and changeable afterwards. Zero values mean "use the service default".
+type LinkSettings struct {
+	RedirectType int          `json:"redirect_type,omitempty"`
+	Passthrough  *Passthrough `json:"passthrough,omitempty"`
+	// ParamTemplate sets query parameters on the destination at redirect
+	// time, e.g. {"utm_source": "{referrer_host}"}. Parameters expanding to
+	// an empty value are left out.
+	ParamTemplate map[string]string `json:"param_template,omitempty"`
+	// Rules send matching visitors to an alternative destination. The first
+	// matching rule wins; visitors matching none go to the link's url.
+	Rules []TargetRule `json:"rules,omitempty"`
+	// Geo maps ISO 3166-1 alpha-2 country codes to destinations for
+	// visitors from that country. It applies when no rule matches.
+	Geo map[string]string `json:"geo,omitempty"`
+	// Variants split visitors between weighted destinations in place of
+	// the link's url. Visitors keep the variant they were first assigned.
+	Variants []Variant `json:"variants,omitempty"`
+	// Password is only accepted in requests. HashPassword replaces it with
+	// PasswordHash, which is never part of API responses.
+	Password     string `json:"password,omitempty"`
+	PasswordHash string `json:"-"`
+	// MaxClicks stops the link from resolving after that many redirects;
+	// 1 makes a one-time link. Zero means unlimited.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+	// NotBefore keeps the link from resolving until the given time.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// Windows restrict the link to recurring periods. A link with windows
+	// only resolves while one of them is open.
+	Windows []Window `json:"windows,omitempty"`
+	// ExpiryFallback is where visitors are sent once the link expired, in
+	// place of the service default.
+	ExpiryFallback string `json:"expiry_fallback,omitempty"`
+}
+
+// Window is a recurring period in which a link resolves, e.g. weekdays from
+// 09:00 to 17:00 in Europe/Berlin. A window ending before it starts runs
+// past midnight into the next day; one ending when it starts lasts all day.
+type Window struct {
+	// Days are mon to sun; no days means every day.
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	// TimeZone is an IANA time zone name, UTC by default.
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ActiveAt reports whether the link may be resolved at t.
+func (s *LinkSettings) ActiveAt(t time.Time) bool {
+	if s.NotBefore != nil && t.Before(*s.NotBefore) {
+		return false
+	}
+	if len(s.Windows) == 0 {
+		return true
+	}
+	for i := range s.Windows {
+		if s.Windows[i].Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether the window is open at t. Invalid windows are
+// never open.
+func (w *Window) Contains(t time.Time) bool {
+	start, end, loc, err := w.parse()
+	if err != nil {
+		return false
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	switch {
+	case start == end:
+		return w.on(local.Weekday())
+	case start < end:
+		return w.on(local.Weekday()) && minute >= start && minute < end
+	default:
+		// Open from start until midnight, and from midnight until end on
+		// the day after a listed day
+		return (w.on(local.Weekday()) && minute >= start) ||
+			(w.on((local.Weekday()+6)%7) && minute < end)
+	}
+}
+
+func (w *Window) on(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, weekdays[day])
+}
+
+// parse returns start and end as minutes into the day.
+func (w *Window) parse() (int, int, *time.Location, error) {
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return 0, 0, nil, errors.New("start must be a time such as 09:00")
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return 0, 0, nil, errors.New("end must be a time such as 17:30")
+	}
+	loc, err := loadLocation(w.TimeZone)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("unknown time zone %s", w.TimeZone)
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), loc, nil
+}
+
+// locations caches time zones by name. time.LoadLocation reads the zone
+// database on every call, which is too slow for the redirect path. Only
+// known zones are cached, so the cache is bounded by the database.
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// Variant is one arm of an A/B split. A variant with weight 2 receives
+// twice the visitors of one with weight 1.
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// TargetRule matches visitors on every condition it sets.
+type TargetRule struct {
+	// Platform is one of ios, android or desktop.
+	Platform string `json:"platform,omitempty"`
+	// Language matches the visitor's preferred Accept-Language tag, either
+	// exactly or by its primary subtag ("pt" matches "pt-BR").
+	Language string `json:"language,omitempty"`
+	// Bot matches crawlers and link preview fetchers.
+	Bot bool   `json:"bot,omitempty"`
+	URL string `json:"url"`
+}
+
+// Passthrough controls which parts of the visited short url are carried
+// over to the destination.
+type Passthrough struct {
+	// Query merges the incoming query string into the destination.
+	Query bool `json:"query,omitempty"`
+	// QueryConflict decides which value wins when both define a parameter.
+	QueryConflict string `json:"query_conflict,omitempty"`
+	// Path appends any path segments following the code.
+	Path bool `json:"path,omitempty"`
+}
+
+// Validate reports settings that can not be applied at redirect time.
+func (s *LinkSettings) Validate() error {
+	switch s.RedirectType {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("%w: redirect type must be one of 301, 302, 307 or 308", ErrInvalidLinkSettings)
+	}
+
+	if s.Passthrough != nil {
+		switch s.Passthrough.QueryConflict {
+		case "", QueryConflictRequest, QueryConflictDestination, QueryConflictAppend:
+		default:
+			return fmt.Errorf("%w: query conflict must be one of request, destination or append", ErrInvalidLinkSettings)
+		}
+	}
+
+	if len(s.ParamTemplate) > maxTemplateParams {
+		return fmt.Errorf("%w: at most %d template parameters are allowed", ErrInvalidLinkSettings, maxTemplateParams)
+	}
+	for key, value := range s.ParamTemplate {
+		if key == "" {
+			return fmt.Errorf("%w: template parameter name can not be empty", ErrInvalidLinkSettings)
+		}
+		for _, match := range ParamPlaceholderRegexp.FindAllStringSubmatch(value, -1) {
+			if !slices.Contains(ParamPlaceholders, match[1]) {
+				return fmt.Errorf("%w: unknown placeholder %s in template parameter %s", ErrInvalidLinkSettings, match[0], key)
+			}
+		}
+	}
+
+	if len(s.Rules) > maxTargetRules {
+		return fmt.Errorf("%w: at most %d rules are allowed", ErrInvalidLinkSettings, maxTargetRules)
+	}
+	for i, rule := range s.Rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("%w: rule %d: %s", ErrInvalidLinkSettings, i, err)
+		}
+	}
+
+	if len(s.Geo) > maxGeoTargets {
+		return fmt.Errorf("%w: at most %d geo targets are allowed", ErrInvalidLinkSettings, maxGeoTargets)
+	}
+	for country, dest := range s.Geo {
+		if !countryCodeRegexp.MatchString(country) {
+			return fmt.Errorf("%w: geo target %q is not an upper case ISO 3166-1 alpha-2 country code", ErrInvalidLinkSettings, country)
+		}
+		if !absoluteURL(dest) {
+			return fmt.Errorf("%w: geo target %s: url must be an absolute http or https url", ErrInvalidLinkSettings, country)
+		}
+	}
+
+	if len(s.Variants) > maxVariants {
+		return fmt.Errorf("%w: at most %d variants are allowed", ErrInvalidLinkSettings, maxVariants)
+	}
+	names := map[string]bool{}
+	for i, variant := range s.Variants {
+		if !variantNameRegexp.MatchString(variant.Name) {
+			return fmt.Errorf("%w: variant %d: name must be 1 to 32 letters, digits, - or _", ErrInvalidLinkSettings, i)
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("%w: variant %s is defined twice", ErrInvalidLinkSettings, variant.Name)
+		}
+		names[variant.Name] = true
+		if variant.Weight < 1 || variant.Weight > maxVariantWeight {
+			return fmt.Errorf("%w: variant %s: weight must be between 1 and %d", ErrInvalidLinkSettings, variant.Name, maxVariantWeight)
+		}
+		if !absoluteURL(variant.URL) {
+			return fmt.Errorf("%w: variant %s: url must be an absolute http or https url", ErrInvalidLinkSettings, variant.Name)
+		}
+	}
+
+	if s.MaxClicks < 0 {
+		return fmt.Errorf("%w: max clicks can not be negative", ErrInvalidLinkSettings)
+	}
+
+	if len(s.Windows) > maxWindows {
+		return fmt.Errorf("%w: at most %d windows are allowed", ErrInvalidLinkSettings, maxWindows)
+	}
+	for i := range s.Windows {
+		for _, day := range s.Windows[i].Days {
+			if !slices.Contains(weekdays, day) {
+				return fmt.Errorf("%w: window %d: days must be mon, tue, wed, thu, fri, sat or sun", ErrInvalidLinkSettings, i)
+			}
+		}
+		if _, _, _, err := s.Windows[i].parse(); err != nil {
+			return fmt.Errorf("%w: window %d: %s", ErrInvalidLinkSettings, i, err)
+		}
+	}
+
+	if s.ExpiryFallback != "" && !absoluteURL(s.ExpiryFallback) {
+		return fmt.Errorf("%w: expiry fallback must be an absolute http or https url", ErrInvalidLinkSettings)
+	}
+
+	if s.Password != "" && (len(s.Password) < minPasswordLength || len(s.Password) > maxPasswordLength) {
+		return fmt.Errorf("%w: password must be between %d and %d bytes", ErrInvalidLinkSettings, minPasswordLength, maxPasswordLength)
+	}
+
+	return nil
+}
+
+// Clone returns a deep copy of the settings, so decoding a request on top
+// of one leaves the other untouched.
+func (s *LinkSettings) Clone() LinkSettings {
+	clone := LinkSettings{}
+	if raw, err := json.Marshal(s); err == nil {
+		_ = json.Unmarshal(raw, &clone)
+	}
+	clone.PasswordHash = s.PasswordHash
+	return clone
+}
+
+// HashPassword moves a password set in a request into PasswordHash.
+func (s *LinkSettings) HashPassword() error {
+	if s.Password == "" {
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	s.PasswordHash = string(hash)
+	s.Password = ""
+	return nil
+}
+
+func (r *TargetRule) validate() error {
+	switch r.Platform {
+	case "", PlatformIOS, PlatformAndroid, PlatformDesktop:
+	default:
+		return errors.New("platform must be one of ios, android or desktop")
+	}
+
+	if r.Language != "" && !languageTagRegexp.MatchString(r.Language) {
+		return errors.New("language must be a language tag such as en or pt-BR")
+	}
+
+	if r.Platform == "" && r.Language == "" && !r.Bot {
+		return errors.New("at least one of platform, language or bot must be set")
+	}
+
+	if !absoluteURL(r.URL) {
+		return errors.New("url must be an absolute http or https url")
+	}
+
+	return nil
+}
+
+// absoluteURL reports whether s is a URL visitors can be sent to. Other
+// schemes, such as javascript: or data:, would run in the browser of
+// whoever follows the link.
+func absoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LinkMetadata describes a link's destination page, as fetched when the
+// link was created. It feeds the preview page and its OpenGraph tags.
+type LinkMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Image is the destination's OpenGraph image.
+	Image     string    `json:"image,omitempty"`
+	Favicon   string    `json:"favicon,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
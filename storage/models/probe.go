@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LinkProbe is the latest check of a link's destination.
+type LinkProbe struct {
+	ShortURL string `json:"short_url"`
+	// URL is the destination that was checked.
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	// Redirects lists the URLs the destination redirected through.
+	Redirects []string `json:"redirects,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	// Failures counts consecutive failed checks. Links are broken once it
+	// reaches the configured threshold.
+	Failures  int       `json:"failures"`
+	Broken    bool      `json:"broken"`
+	CheckedAt time.Time `json:"checked_at"`
+}
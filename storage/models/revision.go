@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Revision actions.
+const (
+	RevisionActionUpdate   = "update"
+	RevisionActionRollback = "rollback"
+)
+
+// LinkState is what a revision records of a link before and after a
+// change.
+type LinkState struct {
+	URL               string    `json:"url"`
+	Expires           time.Time `json:"expires"`
+	PasswordProtected bool      `json:"password_protected,omitempty"`
+	LinkSettings
+}
+
+// NewLinkState captures the state of link. Links without a known expiry
+// are taken to expire TTLInSeconds after now.
+func NewLinkState(link *ShortenedURL, now time.Time) *LinkState {
+	expires := link.Expires
+	if expires.IsZero() {
+		expires = now.Add(time.Duration(link.TTLInSeconds) * time.Second)
+	}
+	return &LinkState{
+		URL:               link.URL.String(),
+		Expires:           expires.UTC().Truncate(time.Second),
+		PasswordProtected: link.PasswordHash != "",
+		LinkSettings:      link.LinkSettings.Clone(),
+	}
+}
+
+// LinkRevision is one recorded change of a link. Revisions are never
+// changed once recorded.
+type LinkRevision struct {
+	ID       int64     `json:"id"`
+	ShortURL string    `json:"short_url"`
+	Actor    string    `json:"actor"`
+	Action   string    `json:"action"`
+	Time     time.Time `json:"time"`
+	// RolledBack is the revision a rollback undid.
+	RolledBack int64      `json:"rolled_back,omitempty"`
+	Old        *LinkState `json:"old"`
+	New        *LinkState `json:"new"`
+}
@@ -10,13 +10,25 @@ type ShortenedURL struct {
 	ShortURL     *url.URL  `json:"short_url"`
 	TTLInSeconds int64     `json:"ttl_in_seconds,string"`
 	CreatedAt    time.Time `json:"created_at"`
+	// Expires is the exact expiry where the storage keeps it. Storages
+	// writing a link without it expire it TTLInSeconds from now.
+	Expires time.Time `json:"-"`
+	// Clicks counts redirects served, kept for links with MaxClicks.
+	Clicks int64 `json:"clicks,omitempty"`
+	// Metadata is the destination's title and images, if it was unfurled.
+	Metadata *LinkMetadata `json:"metadata,omitempty"`
+	LinkSettings
 }
 
 type JSONShortenedURL struct {
-	URL          string    `json:"url"`
-	ShortURL     string    `json:"short_url"`
-	TTLInSeconds int64     `json:"ttl_in_seconds,string"`
-	CreatedAt    time.Time `json:"created_at"`
+	URL               string        `json:"url"`
+	ShortURL          string        `json:"short_url"`
+	TTLInSeconds      int64         `json:"ttl_in_seconds,string"`
+	CreatedAt         time.Time     `json:"created_at"`
+	Clicks            int64         `json:"clicks,omitempty"`
+	PasswordProtected bool          `json:"password_protected,omitempty"`
+	Metadata          *LinkMetadata `json:"metadata,omitempty"`
+	LinkSettings
 }
 
 type JSONDomainReport struct {
@@ -24,12 +36,20 @@ type JSONDomainReport struct {
 	Count  int    `json:"count"`
 }
 
+// PresentJsonShortenedURLModel builds the API model of in. The password
+// hash is left out; API responses only report whether there is one.
 func PresentJsonShortenedURLModel(in *ShortenedURL) *JSONShortenedURL {
+	settings := in.LinkSettings
+	settings.PasswordHash = ""
 	return &JSONShortenedURL{
-		URL:          in.URL.String(),
-		ShortURL:     in.ShortURL.String(),
-		TTLInSeconds: in.TTLInSeconds,
-		CreatedAt:    in.CreatedAt,
+		URL:               in.URL.String(),
+		ShortURL:          in.ShortURL.String(),
+		TTLInSeconds:      in.TTLInSeconds,
+		CreatedAt:         in.CreatedAt,
+		Clicks:            in.Clicks,
+		PasswordProtected: in.PasswordHash != "",
+		Metadata:          in.Metadata,
+		LinkSettings:      settings,
 	}
 }
 
@@ -44,10 +64,14 @@ func MapJsonShortenedURLModel(in *JSONShortenedURL) (*ShortenedURL, error) {
 		return nil, err
 	}
 
-	return &ShortenedURL{
+	out := &ShortenedURL{
 		URL:          origUrl,
 		ShortURL:     shortUrl,
 		TTLInSeconds: in.TTLInSeconds,
 		CreatedAt:    in.CreatedAt,
-	}, nil
+		Clicks:       in.Clicks,
+		Metadata:     in.Metadata,
+		LinkSettings: in.LinkSettings,
+	}
+	return out, nil
 }
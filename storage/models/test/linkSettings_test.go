@@ -0,0 +1,102 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveAtNotBefore(t *testing.T) {
+	launch := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	settings := models.LinkSettings{NotBefore: &launch}
+
+	require.False(t, settings.ActiveAt(launch.Add(-time.Second)))
+	require.True(t, settings.ActiveAt(launch))
+	require.True(t, (&models.LinkSettings{}).ActiveAt(launch))
+}
+
+func TestActiveAtWindows(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.Nil(t, err)
+
+	settings := models.LinkSettings{Windows: []models.Window{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00", TimeZone: "Europe/Berlin"},
+		// Friday night until Saturday morning
+		{Days: []string{"fri"}, Start: "22:00", End: "02:00", TimeZone: "Europe/Berlin"},
+	}}
+	require.Nil(t, settings.Validate())
+
+	tests := []struct {
+		time   time.Time
+		active bool
+	}{
+		{time.Date(2024, 6, 3, 9, 0, 0, 0, berlin), true},    // Monday opening
+		{time.Date(2024, 6, 3, 16, 59, 0, 0, berlin), true},  // Monday before closing
+		{time.Date(2024, 6, 3, 17, 0, 0, 0, berlin), false},  // Monday closing
+		{time.Date(2024, 6, 3, 7, 30, 0, 0, time.UTC), true}, // 09:30 in Berlin
+		{time.Date(2024, 6, 8, 12, 0, 0, 0, berlin), false},  // Saturday
+		{time.Date(2024, 6, 7, 23, 0, 0, 0, berlin), true},   // Friday night
+		{time.Date(2024, 6, 8, 1, 0, 0, 0, berlin), true},    // Saturday after midnight
+		{time.Date(2024, 6, 8, 2, 0, 0, 0, berlin), false},   // Saturday closing
+		{time.Date(2024, 6, 9, 1, 0, 0, 0, berlin), false},   // Sunday after midnight
+	}
+	for _, test := range tests {
+		require.Equal(t, test.active, settings.ActiveAt(test.time), test.time.String())
+	}
+
+	allDay := models.LinkSettings{Windows: []models.Window{{Days: []string{"sun"}, Start: "00:00", End: "00:00"}}}
+	require.True(t, allDay.ActiveAt(time.Date(2024, 6, 9, 23, 59, 0, 0, time.UTC)))
+	require.False(t, allDay.ActiveAt(time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestWindowDoesNotReloadTimeZone(t *testing.T) {
+	window := models.Window{Start: "09:00", End: "17:00", TimeZone: "America/New_York"}
+	now := time.Now()
+	window.Contains(now)
+
+	// Loading a zone reads and decodes the zone database
+	allocs := testing.AllocsPerRun(100, func() { window.Contains(now) })
+	require.Zero(t, allocs)
+}
+
+func TestWindowValidation(t *testing.T) {
+	invalid := []models.Window{
+		{Days: []string{"monday"}, Start: "09:00", End: "17:00"},
+		{Start: "9am", End: "17:00"},
+		{Start: "09:00", End: "24:00"},
+		{Start: "09:00", End: "17:00", TimeZone: "Mars/Olympus"},
+	}
+	for _, window := range invalid {
+		settings := models.LinkSettings{Windows: []models.Window{window}}
+		require.ErrorIs(t, settings.Validate(), models.ErrInvalidLinkSettings, window)
+	}
+}
+
+func TestDestinationValidation(t *testing.T) {
+	for _, dest := range []string{
+		"javascript:alert(document.cookie)",
+		"data:text/html,<script>alert(1)</script>",
+		"ftp://example.com/file",
+		"https:///path",
+		"/relative",
+	} {
+		invalid := []models.LinkSettings{
+			{Geo: map[string]string{"DE": dest}},
+			{Variants: []models.Variant{{Name: "a", Weight: 1, URL: dest}}},
+			{Rules: []models.TargetRule{{Platform: "ios", URL: dest}}},
+			{ExpiryFallback: dest},
+		}
+		for _, settings := range invalid {
+			require.ErrorIs(t, settings.Validate(), models.ErrInvalidLinkSettings, dest)
+		}
+	}
+
+	valid := models.LinkSettings{
+		Geo:            map[string]string{"DE": "https://example.de/"},
+		Variants:       []models.Variant{{Name: "a", Weight: 1, URL: "HTTP://example.com/a"}},
+		ExpiryFallback: "https://example.com/sold-out",
+	}
+	require.Nil(t, valid.Validate())
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types.
+const (
+	WebhookEventLinkCreated       = "link.created"
+	WebhookEventLinkUpdated       = "link.updated"
+	WebhookEventLinkDeleted       = "link.deleted"
+	WebhookEventLinkExpired       = "link.expired"
+	WebhookEventClickLimitReached = "link.click_limit_reached"
+)
+
+// WebhookEvents lists every webhook event type.
+var WebhookEvents = []string{
+	WebhookEventLinkCreated,
+	WebhookEventLinkUpdated,
+	WebhookEventLinkDeleted,
+	WebhookEventLinkExpired,
+	WebhookEventClickLimitReached,
+}
+
+// Webhook delivery states.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryDead deliveries ran out of attempts and are not retried.
+	WebhookDeliveryDead = "dead"
+)
+
+// WebhookEndpoint receives the events it subscribes to, or every event if
+// Events is empty.
+type WebhookEndpoint struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret signs deliveries. It is only returned when the endpoint is
+	// created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscribed reports whether e receives event.
+func (e *WebhookEndpoint) Subscribed(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range e.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the body posted to webhook endpoints. ID is shared by
+// the deliveries of one event, so receivers can drop duplicates.
+type WebhookPayload struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// WebhookDelivery is one event queued for or delivered to an endpoint.
+type WebhookDelivery struct {
+	ID         int64           `json:"id"`
+	EndpointID int64           `json:"endpoint_id"`
+	Event      string          `json:"event"`
+	Payload    json.RawMessage `json:"payload"`
+	Status     string          `json:"status"`
+	Attempts   int             `json:"attempts"`
+	// NextAttempt is when a pending delivery is tried next.
+	NextAttempt time.Time `json:"next_attempt"`
+	// LastStatusCode and LastError describe the latest failed attempt.
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,261 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/uptrace/bun"
+)
+
+// archiveLockKey identifies the advisory lock held while archiving expired
+// links; it is "snipr" in ASCII followed by a lock number.
+const archiveLockKey int64 = 0x736e697072_01
+
+type PGArchivedURL struct {
+	bun.BaseModel `bun:"table:short_url_archive,alias:sua"`
+	ID            int64               `bun:"id,pk,autoincrement"`
+	ShortURL      string              `bun:"short_url,notnull"`
+	URL           string              `bun:"url,notnull"`
+	Domain        string              `bun:"domain,notnull"`
+	CreatedAt     time.Time           `bun:"created_at"`
+	Expires       time.Time           `bun:"expires"`
+	Settings      models.LinkSettings `bun:"settings,type:jsonb"`
+	Reason        string              `bun:"reason,notnull"`
+	ArchivedAt    time.Time           `bun:"archived_at,notnull"`
+	Clicks        int64               `bun:"clicks,notnull,default:0"`
+	// PasswordHash is kept apart from Settings, which do not serialize it.
+	PasswordHash string `bun:"password_hash,notnull,default:''"`
+}
+
+type PGURLArchive struct {
+	DB     *bun.DB
+	Logger *slog.Logger
+}
+
+// ArchiveShortURL implements storage.URLArchive.
+func (p *PGURLArchive) ArchiveShortURL(ctx context.Context, shortURL string, reason string) error {
+	err := conn(ctx, p.DB).RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		links := []*PGShortenedURL{}
+		err := tx.NewSelect().Model(&links).
+			Where("short_url = ?", shortURL).
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			return util.ErrNotFound
+		}
+		_, err = archive(ctx, tx, links, reason)
+		return err
+	})
+	return util.PresentStorageErrors(err)
+}
+
+// ArchiveExpired implements storage.URLArchive. The transaction level
+// advisory lock elects one instance to archive and is released on commit.
+func (p *PGURLArchive) ArchiveExpired(ctx context.Context, cutoff time.Time, limit int) ([]*models.JSONArchivedURL, error) {
+	var archived []*PGArchivedURL
+	err := conn(ctx, p.DB).RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var locked bool
+		err := tx.NewRaw("SELECT pg_try_advisory_xact_lock(?)", archiveLockKey).Scan(ctx, &locked)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return util.ErrLocked
+		}
+
+		links := []*PGShortenedURL{}
+		err = tx.NewSelect().Model(&links).
+			Where("expires < ?", cutoff).
+			OrderExpr("expires").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			return nil
+		}
+
+		archived, err = archive(ctx, tx, links, models.ArchiveReasonExpired)
+		return err
+	})
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.JSONArchivedURL, 0, len(archived))
+	for _, item := range archived {
+		out = append(out, presentPGArchivedURLModel(item))
+	}
+	return out, nil
+}
+
+// ClaimExpired implements storage.URLArchive. Links locked by another
+// instance are skipped, so replicas claim different links.
+func (p *PGURLArchive) ClaimExpired(ctx context.Context, now time.Time, limit int) ([]*models.ShortenedURL, error) {
+	due := conn(ctx, p.DB).NewSelect().Model((*PGShortenedURL)(nil)).
+		Column("short_url").
+		Where("expires < ?", now).
+		Where("expiry_reported IS DISTINCT FROM expires").
+		OrderExpr("expires").
+		Limit(limit).
+		For("UPDATE SKIP LOCKED")
+
+	links := []*PGShortenedURL{}
+	err := conn(ctx, p.DB).NewUpdate().Model(&links).
+		Set("expiry_reported = expires").
+		Where("short_url IN (?)", due).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.ShortenedURL, 0, len(links))
+	for _, link := range links {
+		shortenedURL, err := presentPGShortenedURLModel(link)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, shortenedURL)
+	}
+	return out, nil
+}
+
+// ArchivedSince implements storage.URLArchive.
+func (p *PGURLArchive) ArchivedSince(ctx context.Context, shortURL string, since time.Time) (bool, error) {
+	exists, err := conn(ctx, p.DB).NewSelect().Model((*PGArchivedURL)(nil)).
+		Where("short_url = ?", shortURL).
+		Where("archived_at > ?", since).
+		Exists(ctx)
+	if err != nil {
+		return false, util.PresentStorageErrors(err)
+	}
+	return exists, nil
+}
+
+// ListArchived implements storage.URLArchive.
+func (p *PGURLArchive) ListArchived(ctx context.Context, shortURL string, limit int, offset int) ([]*models.JSONArchivedURL, error) {
+	archived := []*PGArchivedURL{}
+	query := conn(ctx, p.DB).NewSelect().Model(&archived)
+	if shortURL != "" {
+		query = query.Where("short_url = ?", shortURL)
+	}
+	err := query.OrderExpr("archived_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.JSONArchivedURL, 0, len(archived))
+	for _, item := range archived {
+		out = append(out, presentPGArchivedURLModel(item))
+	}
+	return out, nil
+}
+
+// archive copies links with their click counts into the archive and
+// deletes them and their probe results, returning the archived rows. Click
+// rows are kept for reporting. The links must have been selected for
+// update in tx.
+func archive(ctx context.Context, tx bun.Tx, links []*PGShortenedURL, reason string) ([]*PGArchivedURL, error) {
+	shortURLs := make([]string, 0, len(links))
+	for _, link := range links {
+		shortURLs = append(shortURLs, link.ShortURL)
+	}
+
+	// Clicks from before a link was created belong to an earlier link that
+	// had the same code
+	counts := []*PGClickCount{}
+	err := tx.NewSelect().Model(&counts).
+		Join("JOIN short_url AS surl ON surl.short_url = c.short_url AND c.clicked_at >= surl.created_at").
+		ColumnExpr("c.short_url, count(1) count").
+		Where("c.short_url IN (?)", bun.In(shortURLs)).
+		GroupExpr("c.short_url").
+		Scan(ctx, &counts)
+	if err != nil {
+		return nil, err
+	}
+	clicks := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		clicks[count.ShortURL] = count.Count
+	}
+
+	now := time.Now()
+	archived := make([]*PGArchivedURL, 0, len(links))
+	for _, link := range links {
+		item := mapPGArchivedURLModel(link, reason, now)
+		// Click limited links count every click as it happens, while
+		// recorded clicks may have been dropped under load
+		item.Clicks = max(clicks[link.ShortURL], link.Clicks)
+		archived = append(archived, item)
+	}
+
+	_, err = tx.NewInsert().Model(&archived).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.NewDelete().Model((*PGShortenedURL)(nil)).
+		Where("short_url IN (?)", bun.In(shortURLs)).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.NewDelete().Model((*PGLinkProbe)(nil)).
+		Where("short_url IN (?)", bun.In(shortURLs)).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return archived, nil
+}
+
+func mapPGArchivedURLModel(in *PGShortenedURL, reason string, archivedAt time.Time) *PGArchivedURL {
+	return &PGArchivedURL{
+		ShortURL:  in.ShortURL,
+		URL:       in.URL,
+		Domain:    in.Domain,
+		CreatedAt: in.CreatedAt,
+		Expires:   in.Expires,
+		Settings: models.LinkSettings{
+			RedirectType:   in.RedirectType,
+			Passthrough:    in.Passthrough,
+			ParamTemplate:  in.ParamTemplate,
+			Rules:          in.Rules,
+			Geo:            in.Geo,
+			Variants:       in.Variants,
+			MaxClicks:      in.MaxClicks,
+			NotBefore:      in.NotBefore,
+			Windows:        in.Windows,
+			ExpiryFallback: in.ExpiryFallback,
+		},
+		Reason:       reason,
+		ArchivedAt:   archivedAt,
+		PasswordHash: in.PasswordHash,
+	}
+}
+
+func presentPGArchivedURLModel(in *PGArchivedURL) *models.JSONArchivedURL {
+	return &models.JSONArchivedURL{
+		URL:               in.URL,
+		ShortURL:          in.ShortURL,
+		CreatedAt:         in.CreatedAt,
+		Expires:           in.Expires,
+		Reason:            in.Reason,
+		ArchivedAt:        in.ArchivedAt,
+		Clicks:            in.Clicks,
+		PasswordProtected: in.PasswordHash != "",
+		LinkSettings:      in.Settings,
+	}
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/uptrace/bun"
+)
+
+type PGAuditEvent struct {
+	bun.BaseModel `bun:"table:audit_event,alias:ae"`
+	ID            int64                          `bun:"id,pk,autoincrement"`
+	Time          time.Time                      `bun:"time,notnull"`
+	Action        string                         `bun:"action,notnull"`
+	Actor         string                         `bun:"actor,notnull"`
+	SourceIP      string                         `bun:"source_ip,notnull"`
+	RequestID     string                         `bun:"request_id,notnull,default:''"`
+	ShortURL      string                         `bun:"short_url,notnull,default:''"`
+	Diff          map[string]*models.AuditChange `bun:"diff,type:jsonb"`
+}
+
+type PGAuditStorage struct {
+	DB     *bun.DB
+	Logger *slog.Logger
+}
+
+// StoreAuditEvent implements storage.AuditStorage.
+func (p *PGAuditStorage) StoreAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	pgEvent := mapPGAuditEventModel(event)
+	_, err := conn(ctx, p.DB).NewInsert().Model(pgEvent).Exec(ctx)
+	if err != nil {
+		return util.PresentStorageErrors(err)
+	}
+
+	event.ID = pgEvent.ID
+	return nil
+}
+
+// ListAuditEvents implements storage.AuditStorage.
+func (p *PGAuditStorage) ListAuditEvents(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEvent, error) {
+	events := []*PGAuditEvent{}
+	query := conn(ctx, p.DB).NewSelect().Model(&events)
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.ShortURL != "" {
+		query = query.Where("short_url = ?", filter.ShortURL)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("time >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("time < ?", filter.Until)
+	}
+	err := query.OrderExpr("id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.AuditEvent, 0, len(events))
+	for _, event := range events {
+		out = append(out, presentPGAuditEventModel(event))
+	}
+	return out, nil
+}
+
+func mapPGAuditEventModel(in *models.AuditEvent) *PGAuditEvent {
+	return &PGAuditEvent{
+		Time:      in.Time,
+		Action:    in.Action,
+		Actor:     in.Actor,
+		SourceIP:  in.SourceIP,
+		RequestID: in.RequestID,
+		ShortURL:  in.ShortURL,
+		Diff:      in.Diff,
+	}
+}
+
+func presentPGAuditEventModel(in *PGAuditEvent) *models.AuditEvent {
+	return &models.AuditEvent{
+		ID:        in.ID,
+		Time:      in.Time,
+		Action:    in.Action,
+		Actor:     in.Actor,
+		SourceIP:  in.SourceIP,
+		RequestID: in.RequestID,
+		ShortURL:  in.ShortURL,
+		Diff:      in.Diff,
+	}
+}
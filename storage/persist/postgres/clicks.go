@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/uptrace/bun"
+)
+
+type PGClick struct {
+	bun.BaseModel `bun:"table:click,alias:c"`
+	ID            int64     `bun:"id,pk,autoincrement"`
+	ShortURL      string    `bun:"short_url,notnull"`
+	ClickedAt     time.Time `bun:"clicked_at,notnull"`
+	Country       string    `bun:"country,notnull,default:''"`
+	Platform      string    `bun:"platform,notnull,default:''"`
+	Bot           bool      `bun:"bot,notnull,default:false"`
+	ReferrerHost  string    `bun:"referrer_host,notnull,default:''"`
+	Variant       string    `bun:"variant,notnull,default:''"`
+}
+
+type PGClickCount struct {
+	bun.BaseModel `bun:"table:click,alias:c"`
+	ShortURL      string `bun:"short_url"`
+	Count         int64  `bun:"count"`
+}
+
+type PGVariantStats struct {
+	bun.BaseModel `bun:"table:click,alias:c"`
+	Variant       string `bun:"variant"`
+	Count         int    `bun:"count"`
+}
+
+type PGClickStorage struct {
+	DB     *bun.DB
+	Logger *slog.Logger
+}
+
+// StoreClicks implements storage.ClickStorage.
+func (p *PGClickStorage) StoreClicks(ctx context.Context, clicks []*models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	pgClicks := make([]*PGClick, 0, len(clicks))
+	for _, click := range clicks {
+		pgClicks = append(pgClicks, mapPGClickModel(click))
+	}
+
+	_, err := conn(ctx, p.DB).NewInsert().Model(&pgClicks).Exec(ctx)
+	if err != nil {
+		return util.PresentStorageErrors(err)
+	}
+	return nil
+}
+
+// ClickStats implements storage.ClickStorage.
+func (p *PGClickStorage) ClickStats(ctx context.Context, shortURL string, since time.Time) (*models.JSONLinkStats, error) {
+	variants := []*PGVariantStats{}
+	err := conn(ctx, p.DB).NewSelect().Model(&variants).
+		ColumnExpr("variant, count(1) count").
+		Where("short_url = ?", shortURL).
+		Where("clicked_at >= ?", since).
+		Group("variant").OrderExpr("variant").
+		Scan(ctx, &variants)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	return presentPGLinkStats(shortURL, variants), nil
+}
+
+func mapPGClickModel(in *models.Click) *PGClick {
+	return &PGClick{
+		ShortURL:     in.ShortURL,
+		ClickedAt:    in.Time,
+		Country:      in.Country,
+		Platform:     in.Platform,
+		Bot:          in.Bot,
+		ReferrerHost: in.ReferrerHost,
+		Variant:      in.Variant,
+	}
+}
+
+func presentPGLinkStats(shortURL string, in []*PGVariantStats) *models.JSONLinkStats {
+	out := &models.JSONLinkStats{
+		ShortURL: shortURL,
+		Variants: make([]*models.JSONVariantStats, 0, len(in)),
+	}
+	for _, item := range in {
+		out.Clicks += item.Count
+		out.Variants = append(out.Variants, &models.JSONVariantStats{
+			Variant: item.Variant,
+			Clicks:  item.Count,
+		})
+	}
+	return out
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"log/slog"
 	"sync"
 
 	"github.com/sri-shubham/snipr/internal/config"
@@ -11,10 +12,13 @@ import (
 var db *bun.DB
 var once *sync.Once = &sync.Once{}
 
-func GetDB(config *config.PostgresConfig) (*bun.DB, error) {
+func GetDB(config *config.PostgresConfig, logger *slog.Logger) (*bun.DB, error) {
 	var err error
 	once.Do(func() {
-		db, err = util.OpenPostgresConn(config)
+		db, err = util.OpenPostgresConn(config, logger)
+		if err == nil {
+			db.AddQueryHook(TracingQueryHook{})
+		}
 	})
 	if err != nil {
 
@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/uptrace/bun"
+)
+
+type PGLinkRevision struct {
+	bun.BaseModel `bun:"table:short_url_revision,alias:surv"`
+	ID            int64        `bun:"id,pk,autoincrement"`
+	ShortURL      string       `bun:"short_url,notnull"`
+	Actor         string       `bun:"actor,notnull"`
+	Action        string       `bun:"action,notnull"`
+	RevisedAt     time.Time    `bun:"revised_at,notnull"`
+	RolledBack    int64        `bun:"rolled_back,notnull,default:0"`
+	Old           *PGLinkState `bun:"old,type:jsonb"`
+	New           *PGLinkState `bun:"new,type:jsonb"`
+}
+
+// PGLinkState is the stored form of models.LinkState. It keeps the
+// password hash, which the API model leaves out, so rollbacks can restore
+// it.
+type PGLinkState struct {
+	URL          string              `json:"url"`
+	Expires      time.Time           `json:"expires"`
+	PasswordHash string              `json:"password_hash,omitempty"`
+	Settings     models.LinkSettings `json:"settings"`
+}
+
+type PGLinkHistory struct {
+	DB     *bun.DB
+	Logger *slog.Logger
+}
+
+// RecordRevision implements storage.LinkHistory.
+func (p *PGLinkHistory) RecordRevision(ctx context.Context, revision *models.LinkRevision) error {
+	pgRevision := mapPGLinkRevisionModel(revision)
+	_, err := conn(ctx, p.DB).NewInsert().Model(pgRevision).Exec(ctx)
+	if err != nil {
+		return util.PresentStorageErrors(err)
+	}
+
+	revision.ID = pgRevision.ID
+	return nil
+}
+
+// ListRevisions implements storage.LinkHistory.
+func (p *PGLinkHistory) ListRevisions(ctx context.Context, shortURL string, since time.Time, limit int, offset int) ([]*models.LinkRevision, error) {
+	revisions := []*PGLinkRevision{}
+	err := conn(ctx, p.DB).NewSelect().Model(&revisions).
+		Where("short_url = ?", shortURL).
+		Where("revised_at >= ?", since).
+		OrderExpr("id DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.LinkRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		out = append(out, presentPGLinkRevisionModel(revision))
+	}
+	return out, nil
+}
+
+// GetRevision implements storage.LinkHistory.
+func (p *PGLinkHistory) GetRevision(ctx context.Context, shortURL string, id int64) (*models.LinkRevision, error) {
+	revision := &PGLinkRevision{}
+	err := conn(ctx, p.DB).NewSelect().Model(revision).
+		Where("short_url = ?", shortURL).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	return presentPGLinkRevisionModel(revision), nil
+}
+
+func mapPGLinkRevisionModel(in *models.LinkRevision) *PGLinkRevision {
+	return &PGLinkRevision{
+		ShortURL:   in.ShortURL,
+		Actor:      in.Actor,
+		Action:     in.Action,
+		RevisedAt:  in.Time,
+		RolledBack: in.RolledBack,
+		Old:        mapPGLinkStateModel(in.Old),
+		New:        mapPGLinkStateModel(in.New),
+	}
+}
+
+func mapPGLinkStateModel(in *models.LinkState) *PGLinkState {
+	if in == nil {
+		return nil
+	}
+	return &PGLinkState{
+		URL:          in.URL,
+		Expires:      in.Expires,
+		PasswordHash: in.PasswordHash,
+		Settings:     in.LinkSettings,
+	}
+}
+
+func presentPGLinkRevisionModel(in *PGLinkRevision) *models.LinkRevision {
+	return &models.LinkRevision{
+		ID:         in.ID,
+		ShortURL:   in.ShortURL,
+		Actor:      in.Actor,
+		Action:     in.Action,
+		Time:       in.RevisedAt,
+		RolledBack: in.RolledBack,
+		Old:        presentPGLinkStateModel(in.Old),
+		New:        presentPGLinkStateModel(in.New),
+	}
+}
+
+func presentPGLinkStateModel(in *PGLinkState) *models.LinkState {
+	if in == nil {
+		return nil
+	}
+	out := &models.LinkState{
+		URL:               in.URL,
+		Expires:           in.Expires,
+		PasswordProtected: in.PasswordHash != "",
+		LinkSettings:      in.Settings,
+	}
+	out.PasswordHash = in.PasswordHash
+	return out
+}
@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/uptrace/bun"
+)
+
+// probeLockKey identifies the advisory lock held while claiming links to
+// probe.
+const probeLockKey int64 = 0x736e697072_02
+
+type PGLinkProbe struct {
+	bun.BaseModel `bun:"table:link_probe,alias:lp"`
+	ShortURL      string    `bun:"short_url,pk"`
+	URL           string    `bun:"url,notnull"`
+	StatusCode    int       `bun:"status_code,notnull,default:0"`
+	Redirects     []string  `bun:"redirects,array"`
+	Error         string    `bun:"error,notnull,default:''"`
+	Failures      int       `bun:"failures,notnull,default:0"`
+	Broken        bool      `bun:"broken,notnull,default:false"`
+	CheckedAt     time.Time `bun:"checked_at,nullzero"`
+	NextCheckAt   time.Time `bun:"next_check_at,notnull"`
+}
+
+type PGProbeStorage struct {
+	DB     *bun.DB
+	Logger *slog.Logger
+}
+
+// ClaimDueLinks implements storage.ProbeStorage. The transaction level
+// advisory lock keeps instances from claiming the same links.
+func (p *PGProbeStorage) ClaimDueLinks(ctx context.Context, now time.Time, limit int, interval time.Duration) ([]*models.LinkProbe, error) {
+	due := []*PGLinkProbe{}
+	err := conn(ctx, p.DB).RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var locked bool
+		err := tx.NewRaw("SELECT pg_try_advisory_xact_lock(?)", probeLockKey).Scan(ctx, &locked)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return util.ErrLocked
+		}
+
+		err = tx.NewRaw(`SELECT surl.short_url, surl.url,
+				CASE WHEN lp.url = surl.url THEN lp.failures ELSE 0 END AS failures
+			FROM short_url AS surl
+			LEFT JOIN link_probe AS lp ON lp.short_url = surl.short_url
+			WHERE surl.expires > ?
+				AND (lp.short_url IS NULL OR lp.url <> surl.url OR lp.next_check_at <= ?)
+			ORDER BY lp.next_check_at NULLS FIRST
+			LIMIT ?`, now, now, limit).
+			Scan(ctx, &due)
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		for _, probe := range due {
+			probe.NextCheckAt = now.Add(interval)
+		}
+		_, err = tx.NewInsert().Model(&due).
+			On("CONFLICT (short_url) DO UPDATE").
+			Set("next_check_at = EXCLUDED.next_check_at").
+			Returning("NULL").
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.LinkProbe, 0, len(due))
+	for _, probe := range due {
+		out = append(out, presentPGLinkProbeModel(probe))
+	}
+	return out, nil
+}
+
+// StoreProbe implements storage.ProbeStorage.
+func (p *PGProbeStorage) StoreProbe(ctx context.Context, probe *models.LinkProbe) error {
+	_, err := conn(ctx, p.DB).NewInsert().Model(mapPGLinkProbeModel(probe)).
+		On("CONFLICT (short_url) DO UPDATE").
+		Set("url = EXCLUDED.url").
+		Set("status_code = EXCLUDED.status_code").
+		Set("redirects = EXCLUDED.redirects").
+		Set("error = EXCLUDED.error").
+		Set("failures = EXCLUDED.failures").
+		Set("broken = EXCLUDED.broken").
+		Set("checked_at = EXCLUDED.checked_at").
+		Exec(ctx)
+	return util.PresentStorageErrors(err)
+}
+
+// ListBroken implements storage.ProbeStorage. Results for destinations a
+// link no longer has, or links that are gone, are left out.
+func (p *PGProbeStorage) ListBroken(ctx context.Context, limit int, offset int) ([]*models.LinkProbe, error) {
+	probes := []*PGLinkProbe{}
+	err := conn(ctx, p.DB).NewSelect().Model(&probes).
+		Join("JOIN short_url AS surl ON surl.short_url = lp.short_url AND surl.url = lp.url").
+		Where("lp.broken").
+		OrderExpr("lp.checked_at DESC, lp.short_url").
+		Limit(limit).
+		Offset(offset).
+		Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.LinkProbe, 0, len(probes))
+	for _, probe := range probes {
+		out = append(out, presentPGLinkProbeModel(probe))
+	}
+	return out, nil
+}
+
+func mapPGLinkProbeModel(in *models.LinkProbe) *PGLinkProbe {
+	return &PGLinkProbe{
+		ShortURL:   in.ShortURL,
+		URL:        in.URL,
+		StatusCode: in.StatusCode,
+		Redirects:  in.Redirects,
+		Error:      in.Error,
+		Failures:   in.Failures,
+		Broken:     in.Broken,
+		CheckedAt:  in.CheckedAt,
+		// Only used when the link was not claimed first; checks are
+		// scheduled by ClaimDueLinks
+		NextCheckAt: in.CheckedAt,
+	}
+}
+
+func presentPGLinkProbeModel(in *PGLinkProbe) *models.LinkProbe {
+	return &models.LinkProbe{
+		ShortURL:   in.ShortURL,
+		URL:        in.URL,
+		StatusCode: in.StatusCode,
+		Redirects:  in.Redirects,
+		Error:      in.Error,
+		Failures:   in.Failures,
+		Broken:     in.Broken,
+		CheckedAt:  in.CheckedAt,
+	}
+}
@@ -2,7 +2,9 @@ package postgres
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
+	"errors"
+	"log/slog"
 	"net/url"
 	"time"
 
@@ -12,12 +14,27 @@ import (
 )
 
 type PGShortenedURL struct {
-	bun.BaseModel `bun:"table:short_url,alias:surl"`
-	URL           string    `bun:"url"`
-	Domain        string    `bun:"domain"`
-	ShortURL      string    `bun:"short_url,pk"`
-	Expires       time.Time `bun:"expires"`
-	CreatedAt     time.Time `bun:"created_at"`
+	bun.BaseModel  `bun:"table:short_url,alias:surl"`
+	URL            string               `bun:"url"`
+	Domain         string               `bun:"domain"`
+	ShortURL       string               `bun:"short_url,pk"`
+	Expires        time.Time            `bun:"expires"`
+	CreatedAt      time.Time            `bun:"created_at"`
+	RedirectType   int                  `bun:"redirect_type,notnull,default:0"`
+	Passthrough    *models.Passthrough  `bun:"passthrough,type:jsonb"`
+	ParamTemplate  map[string]string    `bun:"param_template,type:jsonb"`
+	Rules          []models.TargetRule  `bun:"rules,type:jsonb"`
+	Geo            map[string]string    `bun:"geo,type:jsonb"`
+	Variants       []models.Variant     `bun:"variants,type:jsonb"`
+	PasswordHash   string               `bun:"password_hash,notnull,default:''"`
+	MaxClicks      int64                `bun:"max_clicks,notnull,default:0"`
+	Clicks         int64                `bun:"clicks,notnull,default:0"`
+	NotBefore      *time.Time           `bun:"not_before,nullzero"`
+	Windows        []models.Window      `bun:"windows,type:jsonb"`
+	ExpiryFallback string               `bun:"expiry_fallback,notnull,default:''"`
+	Metadata       *models.LinkMetadata `bun:"metadata,type:jsonb"`
+	// ExpiryReported is the expiry link.expired was last sent for.
+	ExpiryReported *time.Time `bun:"expiry_reported,nullzero"`
 }
 
 type PGShortenedURLDomainReport struct {
@@ -27,13 +44,14 @@ type PGShortenedURLDomainReport struct {
 }
 
 type PGShortenedURLStorage struct {
-	DB *bun.DB
+	DB     *bun.DB
+	Logger *slog.Logger
 }
 
 // GetOriginalURL implements storage.URLStorage.
 func (p *PGShortenedURLStorage) GetOriginalURL(ctx context.Context, shortURL string) (*models.ShortenedURL, error) {
 	pgShortenedURL := new(PGShortenedURL)
-	err := p.DB.NewSelect().Model(pgShortenedURL).Where("short_url = ?", shortURL).Scan(ctx)
+	err := conn(ctx, p.DB).NewSelect().Model(pgShortenedURL).Where("short_url = ?", shortURL).Scan(ctx)
 	if err != nil {
 		return nil, util.PresentStorageErrors(err)
 	}
@@ -50,19 +68,61 @@ func (p *PGShortenedURLStorage) GetOriginalURL(ctx context.Context, shortURL str
 func (p *PGShortenedURLStorage) StoreShortURL(ctx context.Context, shortenedURL *models.ShortenedURL) error {
 	pgShortendedURL := mapPGShortenedURLModel(shortenedURL)
 	pgShortendedURL.CreatedAt = time.Now()
-	_, err := p.DB.NewInsert().Model(pgShortendedURL).
+	res, err := conn(ctx, p.DB).NewInsert().Model(pgShortendedURL).
 		On("Conflict (short_url) do nothing").
 		Returning("*").
 		Exec(ctx)
 	if err != nil {
 		return util.PresentStorageErrors(err)
 	}
+
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		p.Logger.DebugContext(ctx, "Short url already stored", slog.String("short_url", pgShortendedURL.ShortURL))
+	}
 	return nil
 }
 
+// UpdateShortURL implements storage.URLStorage. The destination, expiry and
+// settings are replaced; the click counter is left alone.
+func (p *PGShortenedURLStorage) UpdateShortURL(ctx context.Context, shortenedURL *models.ShortenedURL) error {
+	pgShortendedURL := mapPGShortenedURLModel(shortenedURL)
+	res, err := conn(ctx, p.DB).NewUpdate().Model(pgShortendedURL).
+		Column("url", "domain", "expires", "redirect_type", "passthrough", "param_template", "rules", "geo", "variants", "password_hash", "max_clicks", "not_before", "windows", "expiry_fallback", "metadata").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return util.PresentStorageErrors(err)
+	}
+
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return util.ErrNotFound
+	}
+	return nil
+}
+
+// ConsumeClick implements storage.URLStorage. The limit is checked in the
+// same statement that increments the counter, so concurrent redirects can
+// not overshoot it.
+func (p *PGShortenedURLStorage) ConsumeClick(ctx context.Context, shortenedURL *models.ShortenedURL) (int64, error) {
+	var clicks int64
+	err := conn(ctx, p.DB).NewUpdate().Model((*PGShortenedURL)(nil)).
+		Set("clicks = clicks + 1").
+		Where("short_url = ?", shortenedURL.ShortURL.String()).
+		Where("max_clicks = 0 OR clicks < max_clicks").
+		Returning("clicks").
+		Scan(ctx, &clicks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, util.ErrExhausted
+	}
+	if err != nil {
+		return 0, util.PresentStorageErrors(err)
+	}
+	return clicks, nil
+}
+
 func (p *PGShortenedURLStorage) ReportTopDomains(ctx context.Context, n int) ([]*models.JSONDomainReport, error) {
 	domains := []*PGShortenedURLDomainReport{}
-	err := p.DB.NewSelect().Model(&domains).
+	err := conn(ctx, p.DB).NewSelect().Model(&domains).
 		Group("domain").OrderExpr("count(1) desc").ColumnExpr("domain, count(1) count").
 		Limit(n).Scan(ctx, &domains)
 	if err != nil {
@@ -73,14 +133,28 @@ func (p *PGShortenedURLStorage) ReportTopDomains(ctx context.Context, n int) ([]
 }
 
 func mapPGShortenedURLModel(in *models.ShortenedURL) *PGShortenedURL {
-	now := time.Now()
-	expires := now.Add(time.Duration(in.TTLInSeconds) * time.Second)
-	fmt.Println(in.URL.Host, in.URL.String())
+	expires := in.Expires
+	if expires.IsZero() {
+		expires = time.Now().Add(time.Duration(in.TTLInSeconds) * time.Second)
+	}
 	return &PGShortenedURL{
-		Domain:   in.URL.Host,
-		URL:      in.URL.String(),
-		ShortURL: in.ShortURL.String(),
-		Expires:  expires,
+		Domain:         in.URL.Host,
+		URL:            in.URL.String(),
+		ShortURL:       in.ShortURL.String(),
+		Expires:        expires,
+		RedirectType:   in.RedirectType,
+		Passthrough:    in.Passthrough,
+		ParamTemplate:  in.ParamTemplate,
+		Rules:          in.Rules,
+		Geo:            in.Geo,
+		Variants:       in.Variants,
+		PasswordHash:   in.PasswordHash,
+		MaxClicks:      in.MaxClicks,
+		Clicks:         in.Clicks,
+		NotBefore:      in.NotBefore,
+		Windows:        in.Windows,
+		ExpiryFallback: in.ExpiryFallback,
+		Metadata:       in.Metadata,
 	}
 }
 
@@ -105,6 +179,22 @@ func presentPGShortenedURLModel(in *PGShortenedURL) (*models.ShortenedURL, error
 		ShortURL:     shortUrl,
 		TTLInSeconds: int64(ttl),
 		CreatedAt:    in.CreatedAt,
+		Expires:      in.Expires,
+		Clicks:       in.Clicks,
+		Metadata:     in.Metadata,
+		LinkSettings: models.LinkSettings{
+			RedirectType:   in.RedirectType,
+			Passthrough:    in.Passthrough,
+			ParamTemplate:  in.ParamTemplate,
+			Rules:          in.Rules,
+			Geo:            in.Geo,
+			Variants:       in.Variants,
+			PasswordHash:   in.PasswordHash,
+			MaxClicks:      in.MaxClicks,
+			NotBefore:      in.NotBefore,
+			Windows:        in.Windows,
+			ExpiryFallback: in.ExpiryFallback,
+		},
 	}, nil
 }
 
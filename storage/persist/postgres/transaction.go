@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/sri-shubham/snipr/util"
+	"github.com/uptrace/bun"
+)
+
+// txKey is the context key of the transaction storage calls join.
+type txKey struct{}
+
+type PGTransactor struct {
+	DB *bun.DB
+}
+
+// RunInTx implements storage.Transactor.
+func (p *PGTransactor) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := conn(ctx, p.DB).RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+	return util.PresentStorageErrors(err)
+}
+
+// conn returns the transaction ctx was given by PGTransactor, or db outside
+// of one.
+func conn(ctx context.Context, db *bun.DB) bun.IDB {
+	if tx, ok := ctx.Value(txKey{}).(bun.Tx); ok {
+		return tx
+	}
+	return db
+}
@@ -0,0 +1,235 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/util"
+	"github.com/uptrace/bun"
+)
+
+type PGWebhookEndpoint struct {
+	bun.BaseModel `bun:"table:webhook_endpoint,alias:we"`
+	ID            int64     `bun:"id,pk,autoincrement"`
+	URL           string    `bun:"url,notnull"`
+	Events        []string  `bun:"events,array"`
+	Secret        string    `bun:"secret,notnull"`
+	CreatedAt     time.Time `bun:"created_at,notnull"`
+}
+
+type PGWebhookDelivery struct {
+	bun.BaseModel  `bun:"table:webhook_delivery,alias:wd"`
+	ID             int64           `bun:"id,pk,autoincrement"`
+	EndpointID     int64           `bun:"endpoint_id,notnull"`
+	Event          string          `bun:"event,notnull"`
+	Payload        json.RawMessage `bun:"payload,type:jsonb"`
+	Status         string          `bun:"status,notnull"`
+	Attempts       int             `bun:"attempts,notnull,default:0"`
+	NextAttemptAt  time.Time       `bun:"next_attempt_at,notnull"`
+	LastStatusCode int             `bun:"last_status_code,notnull,default:0"`
+	LastError      string          `bun:"last_error,notnull,default:''"`
+	CreatedAt      time.Time       `bun:"created_at,notnull"`
+	DeliveredAt    *time.Time      `bun:"delivered_at,nullzero"`
+}
+
+type PGWebhookStorage struct {
+	DB     *bun.DB
+	Logger *slog.Logger
+}
+
+// CreateEndpoint implements storage.WebhookStorage.
+func (p *PGWebhookStorage) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	pgEndpoint := mapPGWebhookEndpointModel(endpoint)
+	_, err := conn(ctx, p.DB).NewInsert().Model(pgEndpoint).Exec(ctx)
+	if err != nil {
+		return util.PresentStorageErrors(err)
+	}
+
+	endpoint.ID = pgEndpoint.ID
+	return nil
+}
+
+// ListEndpoints implements storage.WebhookStorage.
+func (p *PGWebhookStorage) ListEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	endpoints := []*PGWebhookEndpoint{}
+	err := conn(ctx, p.DB).NewSelect().Model(&endpoints).OrderExpr("id").Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.WebhookEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		out = append(out, presentPGWebhookEndpointModel(endpoint))
+	}
+	return out, nil
+}
+
+// GetEndpoint implements storage.WebhookStorage.
+func (p *PGWebhookStorage) GetEndpoint(ctx context.Context, id int64) (*models.WebhookEndpoint, error) {
+	endpoint := &PGWebhookEndpoint{}
+	err := conn(ctx, p.DB).NewSelect().Model(endpoint).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+	return presentPGWebhookEndpointModel(endpoint), nil
+}
+
+// DeleteEndpoint implements storage.WebhookStorage.
+func (p *PGWebhookStorage) DeleteEndpoint(ctx context.Context, id int64) error {
+	err := conn(ctx, p.DB).RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewDelete().Model((*PGWebhookEndpoint)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+			return util.ErrNotFound
+		}
+
+		_, err = tx.NewDelete().Model((*PGWebhookDelivery)(nil)).Where("endpoint_id = ?", id).Exec(ctx)
+		return err
+	})
+	return util.PresentStorageErrors(err)
+}
+
+// EnqueueDeliveries implements storage.WebhookStorage.
+func (p *PGWebhookStorage) EnqueueDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	pgDeliveries := make([]*PGWebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		pgDeliveries = append(pgDeliveries, mapPGWebhookDeliveryModel(delivery))
+	}
+	_, err := conn(ctx, p.DB).NewInsert().Model(&pgDeliveries).Exec(ctx)
+	if err != nil {
+		return util.PresentStorageErrors(err)
+	}
+
+	for i, delivery := range deliveries {
+		delivery.ID = pgDeliveries[i].ID
+	}
+	return nil
+}
+
+// ClaimDeliveries implements storage.WebhookStorage.
+func (p *PGWebhookStorage) ClaimDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	deliveries := []*PGWebhookDelivery{}
+	err := conn(ctx, p.DB).RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().Model(&deliveries).
+			Where("status = ?", models.WebhookDeliveryPending).
+			Where("next_attempt_at <= ?", now).
+			OrderExpr("next_attempt_at").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]int64, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+		_, err = tx.NewUpdate().Model((*PGWebhookDelivery)(nil)).
+			Set("next_attempt_at = ?", now.Add(lease)).
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		out = append(out, presentPGWebhookDeliveryModel(delivery))
+	}
+	return out, nil
+}
+
+// UpdateDelivery implements storage.WebhookStorage.
+func (p *PGWebhookStorage) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := conn(ctx, p.DB).NewUpdate().Model(mapPGWebhookDeliveryModel(delivery)).
+		Column("status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at").
+		WherePK().
+		Exec(ctx)
+	return util.PresentStorageErrors(err)
+}
+
+// ListDeliveries implements storage.WebhookStorage.
+func (p *PGWebhookStorage) ListDeliveries(ctx context.Context, endpointID int64, status string, limit int, offset int) ([]*models.WebhookDelivery, error) {
+	deliveries := []*PGWebhookDelivery{}
+	query := conn(ctx, p.DB).NewSelect().Model(&deliveries).Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.OrderExpr("id DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(ctx)
+	if err != nil {
+		return nil, util.PresentStorageErrors(err)
+	}
+
+	out := make([]*models.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		out = append(out, presentPGWebhookDeliveryModel(delivery))
+	}
+	return out, nil
+}
+
+func mapPGWebhookEndpointModel(in *models.WebhookEndpoint) *PGWebhookEndpoint {
+	return &PGWebhookEndpoint{
+		ID:        in.ID,
+		URL:       in.URL,
+		Events:    in.Events,
+		Secret:    in.Secret,
+		CreatedAt: in.CreatedAt,
+	}
+}
+
+func presentPGWebhookEndpointModel(in *PGWebhookEndpoint) *models.WebhookEndpoint {
+	return &models.WebhookEndpoint{
+		ID:        in.ID,
+		URL:       in.URL,
+		Events:    in.Events,
+		Secret:    in.Secret,
+		CreatedAt: in.CreatedAt,
+	}
+}
+
+func mapPGWebhookDeliveryModel(in *models.WebhookDelivery) *PGWebhookDelivery {
+	return &PGWebhookDelivery{
+		ID:             in.ID,
+		EndpointID:     in.EndpointID,
+		Event:          in.Event,
+		Payload:        in.Payload,
+		Status:         in.Status,
+		Attempts:       in.Attempts,
+		NextAttemptAt:  in.NextAttempt,
+		LastStatusCode: in.LastStatusCode,
+		LastError:      in.LastError,
+		CreatedAt:      in.CreatedAt,
+		DeliveredAt:    in.DeliveredAt,
+	}
+}
+
+func presentPGWebhookDeliveryModel(in *PGWebhookDelivery) *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		ID:             in.ID,
+		EndpointID:     in.EndpointID,
+		Event:          in.Event,
+		Payload:        in.Payload,
+		Status:         in.Status,
+		Attempts:       in.Attempts,
+		NextAttempt:    in.NextAttemptAt,
+		LastStatusCode: in.LastStatusCode,
+		LastError:      in.LastError,
+		CreatedAt:      in.CreatedAt,
+		DeliveredAt:    in.DeliveredAt,
+	}
+}
@@ -0,0 +1,34 @@
+//go:generate mockgen -source=probe.go -destination probe_mock.go -package storage
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/storage/persist/postgres"
+	"github.com/uptrace/bun"
+)
+
+// ProbeStorage keeps the results of checking link destinations.
+type ProbeStorage interface {
+	// ClaimDueLinks returns up to limit unexpired links whose destination
+	// was never checked, changed since, or is due at now, and schedules
+	// their next check interval later. Failures carries over the count
+	// of earlier failed checks of the same destination. It returns
+	// util.ErrLocked while another instance is claiming.
+	ClaimDueLinks(ctx context.Context, now time.Time, limit int, interval time.Duration) ([]*models.LinkProbe, error)
+	// StoreProbe records the result of a check.
+	StoreProbe(ctx context.Context, probe *models.LinkProbe) error
+	// ListBroken returns links whose current destination is broken, most
+	// recently checked first.
+	ListBroken(ctx context.Context, limit int, offset int) ([]*models.LinkProbe, error)
+}
+
+func NewPGProbeStorage(db *bun.DB, logger *slog.Logger) ProbeStorage {
+	return &postgres.PGProbeStorage{
+		DB:     db,
+		Logger: logger,
+	}
+}
@@ -0,0 +1,81 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: probe.go
+
+// Package storage is a generated GoMock package.
+package storage
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sri-shubham/snipr/storage/models"
+)
+
+// MockProbeStorage is a mock of ProbeStorage interface.
+type MockProbeStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockProbeStorageMockRecorder
+}
+
+// MockProbeStorageMockRecorder is the mock recorder for MockProbeStorage.
+type MockProbeStorageMockRecorder struct {
+	mock *MockProbeStorage
+}
+
+// NewMockProbeStorage creates a new mock instance.
+func NewMockProbeStorage(ctrl *gomock.Controller) *MockProbeStorage {
+	mock := &MockProbeStorage{ctrl: ctrl}
+	mock.recorder = &MockProbeStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProbeStorage) EXPECT() *MockProbeStorageMockRecorder {
+	return m.recorder
+}
+
+// ClaimDueLinks mocks base method.
+func (m *MockProbeStorage) ClaimDueLinks(ctx context.Context, now time.Time, limit int, interval time.Duration) ([]*models.LinkProbe, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueLinks", ctx, now, limit, interval)
+	ret0, _ := ret[0].([]*models.LinkProbe)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueLinks indicates an expected call of ClaimDueLinks.
+func (mr *MockProbeStorageMockRecorder) ClaimDueLinks(ctx, now, limit, interval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueLinks", reflect.TypeOf((*MockProbeStorage)(nil).ClaimDueLinks), ctx, now, limit, interval)
+}
+
+// ListBroken mocks base method.
+func (m *MockProbeStorage) ListBroken(ctx context.Context, limit, offset int) ([]*models.LinkProbe, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBroken", ctx, limit, offset)
+	ret0, _ := ret[0].([]*models.LinkProbe)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBroken indicates an expected call of ListBroken.
+func (mr *MockProbeStorageMockRecorder) ListBroken(ctx, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBroken", reflect.TypeOf((*MockProbeStorage)(nil).ListBroken), ctx, limit, offset)
+}
+
+// StoreProbe mocks base method.
+func (m *MockProbeStorage) StoreProbe(ctx context.Context, probe *models.LinkProbe) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreProbe", ctx, probe)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreProbe indicates an expected call of StoreProbe.
+func (mr *MockProbeStorageMockRecorder) StoreProbe(ctx, probe interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreProbe", reflect.TypeOf((*MockProbeStorage)(nil).StoreProbe), ctx, probe)
+}
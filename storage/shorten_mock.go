@@ -35,6 +35,21 @@ func (m *MockURLStorage) EXPECT() *MockURLStorageMockRecorder {
 	return m.recorder
 }
 
+// ConsumeClick mocks base method.
+func (m *MockURLStorage) ConsumeClick(ctx context.Context, shortUrl *models.ShortenedURL) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeClick", ctx, shortUrl)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeClick indicates an expected call of ConsumeClick.
+func (mr *MockURLStorageMockRecorder) ConsumeClick(ctx, shortUrl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeClick", reflect.TypeOf((*MockURLStorage)(nil).ConsumeClick), ctx, shortUrl)
+}
+
 // GetOriginalURL mocks base method.
 func (m *MockURLStorage) GetOriginalURL(ctx context.Context, shortURL string) (*models.ShortenedURL, error) {
 	m.ctrl.T.Helper()
@@ -63,3 +78,17 @@ func (mr *MockURLStorageMockRecorder) StoreShortURL(ctx, shortUrl interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreShortURL", reflect.TypeOf((*MockURLStorage)(nil).StoreShortURL), ctx, shortUrl)
 }
+
+// UpdateShortURL mocks base method.
+func (m *MockURLStorage) UpdateShortURL(ctx context.Context, shortUrl *models.ShortenedURL) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateShortURL", ctx, shortUrl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateShortURL indicates an expected call of UpdateShortURL.
+func (mr *MockURLStorageMockRecorder) UpdateShortURL(ctx, shortUrl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateShortURL", reflect.TypeOf((*MockURLStorage)(nil).UpdateShortURL), ctx, shortUrl)
+}
@@ -3,6 +3,7 @@ package storage
 
 import (
 	"context"
+	"log/slog"
 
 	"github.com/redis/go-redis/v9"
 	rediscache "github.com/sri-shubham/snipr/storage/cache/redisCache"
@@ -14,16 +15,23 @@ import (
 type URLStorage interface {
 	StoreShortURL(ctx context.Context, shortUrl *models.ShortenedURL) error
 	GetOriginalURL(ctx context.Context, shortURL string) (*models.ShortenedURL, error)
+	UpdateShortURL(ctx context.Context, shortUrl *models.ShortenedURL) error
+	// ConsumeClick counts a redirect of a link with MaxClicks and returns
+	// the clicks used so far, or util.ErrExhausted if none are left.
+	// Concurrent calls never let more than MaxClicks redirects through.
+	ConsumeClick(ctx context.Context, shortUrl *models.ShortenedURL) (int64, error)
 }
 
-func NewPGShortenedURLStorage(db *bun.DB) URLStorage {
+func NewPGShortenedURLStorage(db *bun.DB, logger *slog.Logger) URLStorage {
 	return &postgres.PGShortenedURLStorage{
-		DB: db,
+		DB:     db,
+		Logger: logger,
 	}
 }
 
-func NewRedisShortenedURLStorage(db *redis.Client) URLStorage {
+func NewRedisShortenedURLStorage(db *redis.Client, logger *slog.Logger) URLStorage {
 	return rediscache.RedisShortenedURLStorage{
-		Redis: db,
+		Redis:  db,
+		Logger: logger,
 	}
 }
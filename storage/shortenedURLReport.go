@@ -3,6 +3,7 @@ package storage
 
 import (
 	context "context"
+	"log/slog"
 
 	models "github.com/sri-shubham/snipr/storage/models"
 	"github.com/sri-shubham/snipr/storage/persist/postgres"
@@ -13,8 +14,9 @@ type URLReport interface {
 	ReportTopDomains(ctx context.Context, n int) ([]*models.JSONDomainReport, error)
 }
 
-func NewPGURLReport(db *bun.DB) URLReport {
+func NewPGURLReport(db *bun.DB, logger *slog.Logger) URLReport {
 	return &postgres.PGShortenedURLStorage{
-		DB: db,
+		DB:     db,
+		Logger: logger,
 	}
 }
@@ -0,0 +1,23 @@
+//go:generate mockgen -source=transaction.go -destination transaction_mock.go -package storage
+package storage
+
+import (
+	"context"
+
+	"github.com/sri-shubham/snipr/storage/persist/postgres"
+	"github.com/uptrace/bun"
+)
+
+// Transactor groups storage calls into one database transaction.
+type Transactor interface {
+	// RunInTx calls fn in a transaction, committed if fn returns nil and
+	// rolled back otherwise. Postgres storage called with the context fn
+	// gets takes part in the transaction; nested calls use savepoints.
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+func NewPGTransactor(db *bun.DB) Transactor {
+	return &postgres.PGTransactor{
+		DB: db,
+	}
+}
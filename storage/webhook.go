@@ -0,0 +1,44 @@
+//go:generate mockgen -source=webhook.go -destination webhook_mock.go -package storage
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sri-shubham/snipr/storage/models"
+	"github.com/sri-shubham/snipr/storage/persist/postgres"
+	"github.com/uptrace/bun"
+)
+
+// WebhookStorage keeps webhook endpoints and the queue of deliveries to
+// them, which doubles as their delivery log.
+type WebhookStorage interface {
+	// CreateEndpoint stores endpoint, setting its ID.
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	// ListEndpoints returns every endpoint, oldest first.
+	ListEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error)
+	// GetEndpoint returns endpoint id or util.ErrNotFound.
+	GetEndpoint(ctx context.Context, id int64) (*models.WebhookEndpoint, error)
+	// DeleteEndpoint removes endpoint id and its deliveries. It returns
+	// util.ErrNotFound if there is no such endpoint.
+	DeleteEndpoint(ctx context.Context, id int64) error
+	// EnqueueDeliveries stores pending deliveries.
+	EnqueueDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error
+	// ClaimDeliveries returns up to limit pending deliveries due at now and
+	// postpones them by lease, so other instances skip them while they are
+	// attempted.
+	ClaimDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	// UpdateDelivery stores the outcome of an attempt.
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// ListDeliveries returns the deliveries to endpointID, newest first. An
+	// empty status lists deliveries in every state.
+	ListDeliveries(ctx context.Context, endpointID int64, status string, limit int, offset int) ([]*models.WebhookDelivery, error)
+}
+
+func NewPGWebhookStorage(db *bun.DB, logger *slog.Logger) WebhookStorage {
+	return &postgres.PGWebhookStorage{
+		DB:     db,
+		Logger: logger,
+	}
+}
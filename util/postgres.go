@@ -3,7 +3,7 @@ package util
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/sri-shubham/snipr/internal/config"
@@ -12,7 +12,7 @@ import (
 	"github.com/uptrace/bun/driver/pgdriver"
 )
 
-func OpenPostgresConn(conf *config.PostgresConfig) (*bun.DB, error) {
+func OpenPostgresConn(conf *config.PostgresConfig, logger *slog.Logger) (*bun.DB, error) {
 	dsn := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", conf.User, conf.Password, conf.Host, conf.Port, conf.DB)
 
 	var db *bun.DB
@@ -28,7 +28,7 @@ func OpenPostgresConn(conf *config.PostgresConfig) (*bun.DB, error) {
 		} else if err == nil {
 			break
 		}
-		log.Println("Failed try", count)
+		logger.Warn("Failed to connect to postgres", slog.Int("retries_left", count), slog.Any("error", err))
 		time.Sleep(time.Second * 5)
 	}
 
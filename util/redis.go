@@ -3,14 +3,14 @@ package util
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sri-shubham/snipr/internal/config"
 )
 
-func OpenRedisConn(conf *config.RedisConfig) (*redis.Client, error) {
+func OpenRedisConn(conf *config.RedisConfig, logger *slog.Logger) (*redis.Client, error) {
 	url := fmt.Sprintf("redis://%s:%s@%s:%d/%d", conf.User, conf.Password, conf.Host, conf.Port, conf.DB)
 	var rdb *redis.Client
 	count := 3
@@ -28,7 +28,7 @@ func OpenRedisConn(conf *config.RedisConfig) (*redis.Client, error) {
 		} else if err == nil {
 			break
 		}
-		log.Println("Failed try", count)
+		logger.Warn("Failed to connect to redis", slog.Int("retries_left", count), slog.Any("error", err))
 		time.Sleep(5 * time.Second)
 	}
 
@@ -3,13 +3,23 @@ package util
 import (
 	"database/sql"
 	"errors"
+
+	"github.com/redis/go-redis/v9"
 )
 
 var ErrNotFound = errors.New("Not Found")
 
+// ErrExhausted is returned once a link has been clicked as often as it
+// allows.
+var ErrExhausted = errors.New("Click limit reached")
+
+// ErrLocked is returned when another instance holds a lock needed for the
+// operation.
+var ErrLocked = errors.New("Locked by another instance")
+
 func PresentStorageErrors(err error) error {
 	switch {
-	case errors.Is(err, sql.ErrNoRows):
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, redis.Nil):
 		return ErrNotFound
 	default:
 		return err